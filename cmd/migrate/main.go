@@ -0,0 +1,105 @@
+// cmd/migrate/main.go
+//
+// migrate applies the SQL migrations embedded in the binary.
+//
+//	migrate up          apply all pending migrations
+//	migrate down [N]    revert the latest N migrations (default 1)
+//	migrate status      list migrations and when they were applied
+//	migrate redo        revert and re-apply the latest migration
+//
+// DATABASE_URL must point at the target database.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/migrate"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/postgres"
+	"github.com/felixojiambo/go-graphql-order-service/migrations"
+)
+
+func main() {
+	ctx := context.Background()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL env var is required")
+	}
+	pgDB, err := postgres.Connect(dbURL)
+	if err != nil {
+		log.Fatalf("failed to connect to Postgres: %v", err)
+	}
+	defer pgDB.Close()
+
+	m, err := migrate.New(pgDB.DB, migrations.FS)
+	if err != nil {
+		log.Fatalf("cannot load migrations: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "up":
+		done, err := m.Up(ctx)
+		report("applied", done)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			if steps, err = strconv.Atoi(os.Args[2]); err != nil || steps < 1 {
+				log.Fatalf("invalid step count %q", os.Args[2])
+			}
+		}
+		done, err := m.Down(ctx, steps)
+		report("reverted", done)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "redo":
+		mig, err := m.Redo(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("redone %03d_%s\n", mig.Version, mig.Name)
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%03d_%-40s %s\n", st.Version, st.Name, applied)
+		}
+
+	default:
+		usage()
+	}
+}
+
+func report(verb string, migs []migrate.Migration) {
+	if len(migs) == 0 {
+		fmt.Printf("nothing %s\n", verb)
+	}
+	for _, mig := range migs {
+		fmt.Printf("%s %03d_%s\n", verb, mig.Version, mig.Name)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate up | down [N] | status | redo")
+	os.Exit(2)
+}
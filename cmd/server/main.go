@@ -3,9 +3,13 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
@@ -15,19 +19,24 @@ import (
 	"github.com/felixojiambo/go-graphql-order-service/internal/db/postgres"
 	"github.com/felixojiambo/go-graphql-order-service/internal/graphql"
 	"github.com/felixojiambo/go-graphql-order-service/internal/notification"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification/templates"
+	"github.com/felixojiambo/go-graphql-order-service/internal/outbox"
+	"github.com/felixojiambo/go-graphql-order-service/internal/webhook"
 )
 
 func main() {
 	ctx := context.Background()
 
 	// ──────────────────────────────────────────────────────────────────────
-	// 1) Initialize Firebase Auth client
-	//    Set FIREBASE_SERVICE_ACCOUNT_PATH to the path of your service account JSON,
-	//    or leave it empty and rely on GOOGLE_APPLICATION_CREDENTIALS env var.
-	serviceAccountPath := os.Getenv("FIREBASE_SERVICE_ACCOUNT_PATH")
-	authClient, err := auth.InitializeFirebaseAuthClient(ctx, serviceAccountPath)
+	// 1) Initialize the token verifier
+	//    AUTH_MODE=firebase (default) verifies Firebase ID tokens; set
+	//    FIREBASE_SERVICE_ACCOUNT_PATH or rely on GOOGLE_APPLICATION_CREDENTIALS.
+	//    AUTH_MODE=jwks verifies RS256/ES256 JWTs against JWKS_URL or JWKS_FILE,
+	//    requiring the JWT_ISSUER issuer and JWT_AUDIENCE audience (both must
+	//    be set) and reading roles from JWT_ROLES_CLAIM (default "roles").
+	verifier, err := newVerifier(ctx)
 	if err != nil {
-		log.Fatalf("cannot initialize Firebase Auth client: %v", err)
+		log.Fatalf("cannot initialize token verifier: %v", err)
 	}
 	// ──────────────────────────────────────────────────────────────────────
 
@@ -47,37 +56,84 @@ func main() {
 	categoryRepo := postgres.NewCategoryRepository(pgDB)
 	productRepo := postgres.NewProductRepository(pgDB)
 	orderRepo := postgres.NewOrderRepository(pgDB)
-	// If you have a CustomerRepository, initialize it here as well:
-	// customerRepo := postgres.NewCustomerRepository(pgDB)
+	customerRepo := postgres.NewCustomerRepository(pgDB)
+	inventoryRepo := postgres.NewInventoryRepository(pgDB)
+	outboxRepo := postgres.NewOutboxRepository(pgDB)
+	webhookRepo := postgres.NewWebhookRepository(pgDB)
+	prefsRepo := postgres.NewNotificationPreferenceRepository(pgDB)
 	// ──────────────────────────────────────────────────────────────────────
 
 	// ──────────────────────────────────────────────────────────────────────
 	// 3) Initialize NotificationService (noop by default)
-	//    You can swap in a real SMS/email implementation later.
-	noopSvc := &notification.NoopNotificationService{}
+	//    SMTP_HOST enables email over SMTP, configured by SMTP_PORT,
+	//    SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM and SMTP_TLS
+	//    (starttls by default, implicit or none). No service sends SMS
+	//    yet, so SMS notifications are suppressed.
+	//    Resolvers only enqueue notifications in the outbox; the dispatcher
+	//    delivers them in the background, retrying failures with backoff.
+	//    Each message is routed through the customer's notification
+	//    preferences first; suppressed ones are logged with the reason.
+	notifier, err := newNotifier()
+	if err != nil {
+		log.Fatalf("cannot initialize notifications: %v", err)
+	}
+	go outbox.NewDispatcher(outboxRepo, notification.NewRouter(prefsRepo, notifier)).Run(ctx)
+	//    Order events are pushed to webhook subscriptions the same way.
+	go webhook.NewDispatcher(webhookRepo).Run(ctx)
 
-	// 4) Construct GraphQL resolver, injecting repos + notification svc
+	// 4) Construct GraphQL resolver, injecting repos + notification outbox
 	resolver := graphql.NewResolver(
 		categoryRepo,
 		productRepo,
 		orderRepo,
-		noopSvc,
+		customerRepo,
+		inventoryRepo,
+		outboxRepo,
+		webhookRepo,
+		prefsRepo,
 	)
+	//    IDEMPOTENCY_TTL (e.g. "24h") controls how long placeOrder keys are kept.
+	if v := os.Getenv("IDEMPOTENCY_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			log.Fatalf("invalid IDEMPOTENCY_TTL %q", v)
+		}
+		resolver.IdempotencyTTL = ttl
+	}
+	//    NOTIFICATION_TEMPLATES_DIR overrides or adds notification templates;
+	//    it is laid out like internal/notification/templates/defaults.
+	if dir := os.Getenv("NOTIFICATION_TEMPLATES_DIR"); dir != "" {
+		reg, err := templates.Load(templates.Embedded, os.DirFS(dir))
+		if err != nil {
+			log.Fatalf("load notification templates: %v", err)
+		}
+		resolver.Templates = reg
+	}
 	// ──────────────────────────────────────────────────────────────────────
 
 	// ──────────────────────────────────────────────────────────────────────
-	// 5) Create a new gqlgen server pointing to your generated schema
-	srv := handler.NewDefaultServer(
-		graphql.NewExecutableSchema(graphql.Config{Resolvers: resolver}),
-	)
+	// 5) Create a new gqlgen server pointing to your generated schema,
+	//    refusing to start if any mutation lacks an @auth/@hasRole directive
+	schema := graphql.NewExecutableSchema(graphql.NewConfig(resolver))
+	if err := graphql.CheckMutationAuth(schema.Schema()); err != nil {
+		log.Fatalf("schema check failed: %v", err)
+	}
+	srv := handler.NewDefaultServer(schema)
+	srv.SetErrorPresenter(graphql.ErrorPresenter)
 	// ──────────────────────────────────────────────────────────────────────
 
 	// ──────────────────────────────────────────────────────────────────────
-	// 6) Set up Gorilla/Mux router and attach Firebase auth middleware
+	// 6) Set up Gorilla/Mux router and attach the auth middleware
 	r := mux.NewRouter()
 
-	// Protect the /query endpoint with FirebaseAuthMiddleware
-	r.Handle("/query", auth.FirebaseAuthMiddleware(authClient)(srv))
+	// Protect the /query endpoint with FirebaseAuthMiddleware; each request
+	// gets its own batching loaders. LOG_LEVEL=debug logs the number of
+	// database queries per request.
+	var query http.Handler = resolver.LoaderMiddleware(graphql.IdempotencyKeyMiddleware(srv))
+	if os.Getenv("LOG_LEVEL") == "debug" {
+		query = graphql.QueryLogMiddleware(query)
+	}
+	r.Handle("/query", auth.FirebaseAuthMiddleware(verifier)(query))
 
 	// Expose Playground (no auth) on /playground
 	r.Handle("/playground", playground.Handler("GraphQL Playground", "/query"))
@@ -92,3 +148,49 @@ func main() {
 	}
 	// ──────────────────────────────────────────────────────────────────────
 }
+
+// newVerifier builds the token verifier selected by AUTH_MODE.
+func newVerifier(ctx context.Context) (auth.AuthVerifier, error) {
+	switch mode := os.Getenv("AUTH_MODE"); mode {
+	case "", "firebase":
+		return auth.InitializeFirebaseAuthClient(ctx, os.Getenv("FIREBASE_SERVICE_ACCOUNT_PATH"))
+	case "jwks":
+		issuer, audience := os.Getenv("JWT_ISSUER"), os.Getenv("JWT_AUDIENCE")
+		if issuer == "" || audience == "" {
+			return nil, errors.New("AUTH_MODE=jwks requires JWT_ISSUER and JWT_AUDIENCE")
+		}
+		return auth.NewJWKSVerifier(ctx, auth.JWKSConfig{
+			URL:        os.Getenv("JWKS_URL"),
+			File:       os.Getenv("JWKS_FILE"),
+			Issuer:     issuer,
+			Audience:   audience,
+			RolesClaim: os.Getenv("JWT_ROLES_CLAIM"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown AUTH_MODE %q (want firebase or jwks)", mode)
+	}
+}
+
+// newNotifier builds the SMTP notification service when SMTP_HOST is set and
+// the no-op service otherwise.
+func newNotifier() (notification.NotificationService, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return &notification.NoopNotificationService{}, nil
+	}
+	cfg := notification.SMTPConfig{
+		Host:     host,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		TLS:      notification.TLSMode(os.Getenv("SMTP_TLS")),
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q", v)
+		}
+		cfg.Port = port
+	}
+	return notification.NewSMTPService(cfg)
+}
@@ -0,0 +1,193 @@
+// Package apperror defines the errors the API reports to clients. Each error
+// carries a Code that the GraphQL error presenter exposes as
+// extensions.code. Anything that is not an *Error is classified by From;
+// what cannot be classified is INTERNAL, and its details are logged
+// server-side instead of being shown.
+package apperror
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/domain"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+)
+
+// Code classifies an error for clients.
+type Code string
+
+const (
+	CodeUnauthenticated   Code = "UNAUTHENTICATED"    // no or invalid credentials
+	CodeForbidden         Code = "FORBIDDEN"          // authenticated but not allowed
+	CodeNotFound          Code = "NOT_FOUND"          // the addressed record does not exist
+	CodeValidationFailed  Code = "VALIDATION_FAILED"  // the input is malformed or breaks a rule
+	CodeConflict          Code = "CONFLICT"           // the request clashes with current state
+	CodeInsufficientStock Code = "INSUFFICIENT_STOCK" // products in extensions.productIDs are short
+	CodeInternal          Code = "INTERNAL"           // anything else; details are hidden
+)
+
+// internalMessage is all clients see of an INTERNAL error.
+const internalMessage = "internal server error"
+
+// Error is an error with a code and a message that is safe to show clients.
+type Error struct {
+	Code    Code
+	Message string
+
+	// Fields are extra extensions shown next to code, e.g. productIDs.
+	Fields map[string]interface{}
+
+	// Err is the underlying cause. It is logged, never shown.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil && e.Err.Error() != e.Message {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Extensions returns the GraphQL extensions for e: its Fields plus code.
+func (e *Error) Extensions() map[string]interface{} {
+	ext := make(map[string]interface{}, len(e.Fields)+1)
+	for k, v := range e.Fields {
+		ext[k] = v
+	}
+	ext["code"] = string(e.Code)
+	return ext
+}
+
+// New returns an error with code and a formatted message.
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an error with code and message caused by err.
+func Wrap(code Code, err error, message string) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Unauthenticated reports a request without valid credentials.
+func Unauthenticated() *Error {
+	return New(CodeUnauthenticated, "unauthenticated")
+}
+
+// Forbidden reports that the caller may not perform the operation.
+func Forbidden(format string, args ...interface{}) *Error {
+	return New(CodeForbidden, "forbidden: "+format, args...)
+}
+
+// NotFound reports a missing record.
+func NotFound(format string, args ...interface{}) *Error {
+	return New(CodeNotFound, format, args...)
+}
+
+// Validation reports unusable input.
+func Validation(format string, args ...interface{}) *Error {
+	return New(CodeValidationFailed, format, args...)
+}
+
+// Conflict reports a request that clashes with the current state.
+func Conflict(format string, args ...interface{}) *Error {
+	return New(CodeConflict, format, args...)
+}
+
+// Internal wraps an unexpected error.
+func Internal(err error) *Error {
+	return Wrap(CodeInternal, err, internalMessage)
+}
+
+// InsufficientStock reports the products that cannot cover a request.
+func InsufficientStock(productIDs []uuid.UUID) *Error {
+	ids := make([]string, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = id.String()
+	}
+	return &Error{
+		Code:    CodeInsufficientStock,
+		Message: "insufficient stock for products: " + strings.Join(ids, ", "),
+		Fields:  map[string]interface{}{"productIDs": ids},
+	}
+}
+
+// Postgres SQLSTATE codes mapped by From.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgNotNullViolation    = "23502"
+)
+
+// From classifies err. An *Error anywhere in the chain is returned as is;
+// repository, domain and Postgres errors get their matching code; anything
+// else becomes INTERNAL. From(nil) is nil.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var stockErr *db.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		e := InsufficientStock(stockErr.ProductIDs)
+		e.Err = err
+		return e
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Wrap(CodeNotFound, err, "not found")
+	case errors.Is(err, db.ErrStatusConflict):
+		return Wrap(CodeConflict, err, db.ErrStatusConflict.Error())
+	case errors.Is(err, db.ErrProductInUse):
+		return Wrap(CodeConflict, err, db.ErrProductInUse.Error())
+	case errors.Is(err, db.ErrCategoryInUse):
+		return Wrap(CodeConflict, err, db.ErrCategoryInUse.Error())
+	case errors.Is(err, db.ErrCategoryCycle):
+		return Wrap(CodeValidationFailed, err, db.ErrCategoryCycle.Error())
+	case errors.Is(err, db.ErrCancelViaUpdateStatus):
+		return Wrap(CodeValidationFailed, err, db.ErrCancelViaUpdateStatus.Error())
+	case errors.Is(err, domain.ErrInvalidTransition),
+		errors.Is(err, domain.ErrInvalidCancellation),
+		errors.Is(err, money.ErrInvalidAmount),
+		errors.Is(err, money.ErrOverflow),
+		errors.Is(err, money.ErrCurrencyMismatch):
+		// these messages are written for clients
+		return Wrap(CodeValidationFailed, err, err.Error())
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pgUniqueViolation:
+			return Wrap(CodeConflict, err, "a record with the same unique value already exists")
+		case pgForeignKeyViolation:
+			if strings.HasPrefix(pqErr.Message, "update or delete") {
+				return Wrap(CodeConflict, err, "the record is still referenced by other records")
+			}
+			return Wrap(CodeValidationFailed, err, "a referenced record does not exist")
+		case pgCheckViolation, pgNotNullViolation:
+			return Wrap(CodeValidationFailed, err, "a value is out of range or missing")
+		}
+	}
+	return Internal(err)
+}
+
+// CodeOf returns the code From assigns to err, or "" for nil.
+func CodeOf(err error) Code {
+	if e := From(err); e != nil {
+		return e.Code
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package apperror
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/domain"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+)
+
+func TestFrom(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("update order: %w", err) }
+	pqErr := func(code, msg string) error { return wrap(&pq.Error{Code: pq.ErrorCode(code), Message: msg}) }
+	notFound := NotFound("order %s not found", "o-1")
+	productID := uuid.New()
+
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    Code
+		wantMessage string
+	}{
+		{"app error", wrap(notFound), CodeNotFound, "order o-1 not found"},
+		{"no rows", wrap(sql.ErrNoRows), CodeNotFound, "not found"},
+		{"insufficient stock", wrap(&db.InsufficientStockError{ProductIDs: []uuid.UUID{productID}}),
+			CodeInsufficientStock, "insufficient stock for products: " + productID.String()},
+
+		{"status conflict", wrap(db.ErrStatusConflict), CodeConflict, db.ErrStatusConflict.Error()},
+		{"product in use", wrap(db.ErrProductInUse), CodeConflict, db.ErrProductInUse.Error()},
+		{"category in use", wrap(db.ErrCategoryInUse), CodeConflict, db.ErrCategoryInUse.Error()},
+		{"category cycle", wrap(db.ErrCategoryCycle), CodeValidationFailed, db.ErrCategoryCycle.Error()},
+		{"cancel via update", wrap(db.ErrCancelViaUpdateStatus), CodeValidationFailed, db.ErrCancelViaUpdateStatus.Error()},
+
+		{"invalid transition", fmt.Errorf("%w: pending -> shipped", domain.ErrInvalidTransition),
+			CodeValidationFailed, "invalid order status transition: pending -> shipped"},
+		{"invalid cancellation", fmt.Errorf("%w: a note is required", domain.ErrInvalidCancellation),
+			CodeValidationFailed, "invalid cancellation: a note is required"},
+		{"invalid amount", money.ErrInvalidAmount, CodeValidationFailed, money.ErrInvalidAmount.Error()},
+		{"overflow", money.ErrOverflow, CodeValidationFailed, money.ErrOverflow.Error()},
+		{"currency mismatch", money.ErrCurrencyMismatch, CodeValidationFailed, money.ErrCurrencyMismatch.Error()},
+
+		{"unique violation", pqErr("23505", `duplicate key value violates unique constraint "customers_email_key"`),
+			CodeConflict, "a record with the same unique value already exists"},
+		{"foreign key on insert", pqErr("23503", `insert or update on table "products" violates foreign key constraint "products_category_id_fkey"`),
+			CodeValidationFailed, "a referenced record does not exist"},
+		{"foreign key on delete", pqErr("23503", `update or delete on table "products" violates foreign key constraint "order_items_product_id_fkey" on table "order_items"`),
+			CodeConflict, "the record is still referenced by other records"},
+		{"check violation", pqErr("23514", `new row for relation "products" violates check constraint "products_stock_check"`),
+			CodeValidationFailed, "a value is out of range or missing"},
+		{"not null violation", pqErr("23502", `null value in column "name" violates not-null constraint`),
+			CodeValidationFailed, "a value is out of range or missing"},
+		{"other postgres error", pqErr("40001", "could not serialize access"), CodeInternal, internalMessage},
+
+		{"unknown", errors.New("connection refused to 10.0.0.5:5432"), CodeInternal, internalMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := From(tt.err)
+			if got.Code != tt.wantCode || got.Message != tt.wantMessage {
+				t.Errorf("From(%v) = %s %q, want %s %q", tt.err, got.Code, got.Message, tt.wantCode, tt.wantMessage)
+			}
+			if !errors.Is(got, tt.err) && !errors.Is(tt.err, got) {
+				t.Errorf("From(%v) = %v, which does not wrap it", tt.err, got)
+			}
+		})
+	}
+
+	if From(nil) != nil {
+		t.Error("From(nil) is not nil")
+	}
+	if got := From(wrap(notFound)); got != notFound {
+		t.Errorf("From(wrapped *Error) = %p, want the *Error itself", got)
+	}
+}
+
+func TestInsufficientStockExtensions(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	want := map[string]interface{}{
+		"code":       string(CodeInsufficientStock),
+		"productIDs": []string{ids[0].String(), ids[1].String()},
+	}
+	if got := InsufficientStock(ids).Extensions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Extensions = %v, want %v", got, want)
+	}
+}
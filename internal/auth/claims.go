@@ -12,9 +12,10 @@ const (
 
 // Claims represents the pieces of information we care about from Firebase’s ID token.
 type Claims struct {
-	UID   string   // Firebase UID (unique user ID)
-	Email string   // The user's email, if present
-	Roles []string // Custom "roles" array from token (e.g. ["admin","user"])
+	UID           string   // Firebase UID (unique user ID)
+	Email         string   // The user's email, if present
+	EmailVerified bool     // Whether the identity provider verified Email
+	Roles         []string // Custom "roles" array from token (e.g. ["admin","user"])
 	// You can extend this struct with any other custom claim fields you need.
 }
 
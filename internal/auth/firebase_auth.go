@@ -54,6 +54,9 @@ func FirebaseAuthMiddleware(verifier AuthVerifier) func(next http.Handler) http.
 			if email, ok := decodedToken.Claims["email"].(string); ok {
 				c.Email = email
 			}
+			if verified, ok := decodedToken.Claims["email_verified"].(bool); ok {
+				c.EmailVerified = verified
+			}
 			if rolesIface, ok := decodedToken.Claims["roles"].([]interface{}); ok {
 				for _, ri := range rolesIface {
 					if rs, ok := ri.(string); ok {
@@ -0,0 +1,410 @@
+// internal/auth/jwks.go
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// DefaultRolesClaim is the token claim read for Claims.Roles.
+const DefaultRolesClaim = "roles"
+
+// JWKSConfig configures a JWKSVerifier. Exactly one of URL and File must be
+// set, and both Issuer and Audience.
+type JWKSConfig struct {
+	URL  string // JWKS endpoint, re-fetched every RefreshInterval
+	File string // JWKS document on disk, read once
+
+	Issuer   string // required "iss"
+	Audience string // required entry in "aud"
+
+	// RolesClaim names the claim holding the caller's roles, e.g.
+	// "permissions" or "realm_access.roles" for a nested claim. Defaults to
+	// DefaultRolesClaim.
+	RolesClaim string
+
+	RefreshInterval time.Duration // defaults to 1h
+	Leeway          time.Duration // clock skew allowed on exp/nbf/iat; defaults to 1m
+	HTTPClient      *http.Client  // defaults to a client with a 10s timeout
+}
+
+// JWKSVerifier validates RS256 and ES256 JWTs against a JSON Web Key Set.
+// It implements AuthVerifier, returning tokens shaped like Firebase ID
+// tokens so FirebaseAuthMiddleware maps them into Claims unchanged.
+type JWKSVerifier struct {
+	cfg JWKSConfig
+	now func() time.Time
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey // by kid
+	fetchedAt   time.Time                   // last successful load
+	attemptedAt time.Time                   // last load attempt
+}
+
+// minRefetch bounds how often the JWKS URL is fetched, whether for an
+// unknown kid or after a failed refresh.
+const minRefetch = 30 * time.Second
+
+// NewJWKSVerifier loads the key set described by cfg.
+func NewJWKSVerifier(ctx context.Context, cfg JWKSConfig) (*JWKSVerifier, error) {
+	if (cfg.URL == "") == (cfg.File == "") {
+		return nil, errors.New("jwks: exactly one of URL and File must be set")
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		// without them a token minted for any other service would pass
+		return nil, errors.New("jwks: Issuer and Audience must be set")
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = DefaultRolesClaim
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = time.Hour
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = time.Minute
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	v := &JWKSVerifier{cfg: cfg, now: time.Now}
+	v.attemptedAt = v.now()
+	if err := v.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// VerifyIDToken checks the token's signature, expiry, issuer and audience.
+// The returned token's UID is the "sub" claim, and the configured roles claim
+// is copied to Claims["roles"].
+func (v *JWKSVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("jwt: header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("jwt: signature: %w", err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, digest[:], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("jwt: claims: %w", err)
+	}
+	return v.validate(claims)
+}
+
+func (v *JWKSVerifier) validate(claims map[string]interface{}) (*auth.Token, error) {
+	now := v.now()
+	leeway := int64(v.cfg.Leeway / time.Second)
+
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return nil, errors.New("jwt: missing exp")
+	}
+	if now.Unix() > exp+leeway {
+		return nil, errors.New("jwt: token expired")
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Unix() < nbf-leeway {
+		return nil, errors.New("jwt: token not valid yet")
+	}
+	iat, _ := numericClaim(claims, "iat")
+	if iat > now.Unix()+leeway {
+		return nil, errors.New("jwt: token issued in the future")
+	}
+
+	iss, _ := claims["iss"].(string)
+	if iss != v.cfg.Issuer {
+		return nil, fmt.Errorf("jwt: unexpected issuer %q", iss)
+	}
+	if aud := audiences(claims["aud"]); !contains(aud, v.cfg.Audience) {
+		return nil, fmt.Errorf("jwt: audience %v does not include %q", aud, v.cfg.Audience)
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, errors.New("jwt: missing sub")
+	}
+
+	if v.cfg.RolesClaim != DefaultRolesClaim {
+		if roles, ok := lookupClaim(claims, v.cfg.RolesClaim); ok {
+			claims[DefaultRolesClaim] = roles
+		} else {
+			delete(claims, DefaultRolesClaim)
+		}
+	}
+	if s, ok := claims[DefaultRolesClaim].(string); ok {
+		// a single role may be sent as a bare string
+		claims[DefaultRolesClaim] = []interface{}{s}
+	}
+
+	tok := &auth.Token{
+		Issuer:   iss,
+		Expires:  exp,
+		IssuedAt: iat,
+		Subject:  sub,
+		UID:      sub,
+		Claims:   claims,
+	}
+	tok.Audience = v.cfg.Audience
+	if at, ok := numericClaim(claims, "auth_time"); ok {
+		tok.AuthTime = at
+	}
+	return tok, nil
+}
+
+// key returns the verification key for kid, refreshing a URL-backed key set
+// when it is stale or does not know kid. An empty kid matches the only key
+// of a single-key set.
+func (v *JWKSVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	k, ok := v.lookup(kid)
+	stale := v.now().Sub(v.fetchedAt) > v.cfg.RefreshInterval
+	v.mu.RUnlock()
+
+	if v.cfg.URL != "" && (stale || !ok) && v.claimRefetch() {
+		if err := v.refresh(ctx); err != nil && !ok {
+			return nil, err
+		}
+		v.mu.RLock()
+		k, ok = v.lookup(kid)
+		v.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("jwt: unknown signing key %q", kid)
+	}
+	return k, nil
+}
+
+// lookup must be called with v.mu held.
+func (v *JWKSVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+// claimRefetch reports whether the caller may fetch the key set now, at most
+// once per minRefetch across all goroutines.
+func (v *JWKSVerifier) claimRefetch() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.now().Sub(v.attemptedAt) < minRefetch {
+		return false
+	}
+	v.attemptedAt = v.now()
+	return true
+}
+
+func (v *JWKSVerifier) refresh(ctx context.Context) error {
+	var body []byte
+	var err error
+	if v.cfg.File != "" {
+		body, err = os.ReadFile(v.cfg.File)
+	} else {
+		body, err = v.fetch(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	keys, err := ParseJWKS(body)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = v.now()
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *JWKSVerifier) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", v.cfg.URL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// ParseJWKS decodes a JSON Web Key Set into public keys by kid. RSA keys and
+// P-256 EC keys meant for signatures are kept; any other key is skipped.
+func ParseJWKS(doc []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(doc, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA":
+			n, err1 := decodeBigInt(k.N)
+			e, err2 := decodeBigInt(k.E)
+			if err := errors.Join(err1, err2); err != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("jwks: RSA key %q: invalid modulus or exponent", k.Kid)
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, err1 := decodeBigInt(k.X)
+			y, err2 := decodeBigInt(k.Y)
+			if err := errors.Join(err1, err2); err != nil || !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("jwks: EC key %q: invalid point", k.Kid)
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks: no usable RS256 or ES256 keys")
+	}
+	return keys, nil
+}
+
+// verifySignature checks sig over digest for alg. The key type must match
+// alg, so an RSA key can never verify an ES256 token or the reverse.
+func verifySignature(alg string, key crypto.PublicKey, digest, sig []byte) error {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("jwt: RS256 token signed with a non-RSA key")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig); err != nil {
+			return errors.New("jwt: invalid signature")
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("jwt: ES256 token signed with a non-EC key")
+		}
+		if len(sig) != 64 {
+			return errors.New("jwt: invalid signature")
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("jwt: invalid signature")
+		}
+	default:
+		return fmt.Errorf("jwt: unsupported alg %q", alg)
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func numericClaim(claims map[string]interface{}, name string) (int64, bool) {
+	f, ok := claims[name].(float64)
+	return int64(f), ok
+}
+
+// lookupClaim resolves a dot-separated path through nested claim objects.
+func lookupClaim(claims map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func audiences(raw interface{}) []string {
+	switch a := raw.(type) {
+	case string:
+		return []string{a}
+	case []interface{}:
+		var out []string
+		for _, v := range a {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/auth/authtest"
+)
+
+const (
+	testIssuer   = "https://issuer.test"
+	testAudience = "orders-api"
+)
+
+func newTestIssuer(t *testing.T, alg string) *authtest.Issuer {
+	t.Helper()
+	iss, err := authtest.NewIssuer(alg, testIssuer, testAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iss
+}
+
+// jwksFile writes a key set holding the keys of every issuer.
+func jwksFile(t *testing.T, issuers ...*authtest.Issuer) string {
+	t.Helper()
+	var keys []json.RawMessage
+	for _, iss := range issuers {
+		var set struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		if err := json.Unmarshal(iss.JWKS(), &set); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, set.Keys...)
+	}
+	doc, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, doc, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newFileVerifier(t *testing.T, cfg JWKSConfig, issuers ...*authtest.Issuer) *JWKSVerifier {
+	t.Helper()
+	cfg.File = jwksFile(t, issuers...)
+	if cfg.Issuer == "" {
+		cfg.Issuer = testIssuer
+	}
+	if cfg.Audience == "" {
+		cfg.Audience = testAudience
+	}
+	v, err := NewJWKSVerifier(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestJWKSVerifierAlgorithms(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256"} {
+		t.Run(alg, func(t *testing.T) {
+			iss := newTestIssuer(t, alg)
+			v := newFileVerifier(t, JWKSConfig{}, iss)
+
+			raw, err := iss.Token("uid-1", "alice@example.com", "admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tok, err := v.VerifyIDToken(context.Background(), raw)
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if tok.UID != "uid-1" || tok.Issuer != testIssuer || tok.Audience != testAudience {
+				t.Errorf("token = uid %q iss %q aud %q", tok.UID, tok.Issuer, tok.Audience)
+			}
+			if roles := tok.Claims["roles"]; !reflect.DeepEqual(roles, []interface{}{"admin"}) {
+				t.Errorf("roles = %v, want [admin]", roles)
+			}
+
+			// flipping a signature byte must fail
+			parts := strings.Split(raw, ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sig[0] ^= 0xff
+			forged := parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(sig)
+			if _, err := v.VerifyIDToken(context.Background(), forged); err == nil {
+				t.Error("VerifyIDToken accepted a forged signature")
+			}
+		})
+	}
+}
+
+// withHeader replaces the JOSE header of a signed token.
+func withHeader(t *testing.T, token string, header map[string]string) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rest, _ := strings.Cut(token, ".")
+	return base64.RawURLEncoding.EncodeToString(h) + "." + rest
+}
+
+func TestJWKSVerifierAlgKeyMismatch(t *testing.T) {
+	rsaIss, ecIss := newTestIssuer(t, "RS256"), newTestIssuer(t, "ES256")
+	v := newFileVerifier(t, JWKSConfig{}, rsaIss, ecIss)
+	ecTok, err := ecIss.Token("uid-1", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaTok, err := rsaIss.Token("uid-1", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, token, want string
+	}{
+		{"RS256 with EC key", withHeader(t, ecTok, map[string]string{"alg": "RS256", "kid": ecIss.KeyID}), "non-RSA key"},
+		{"ES256 with RSA key", withHeader(t, rsaTok, map[string]string{"alg": "ES256", "kid": rsaIss.KeyID}), "non-EC key"},
+		{"RS256 signature under EC kid", withHeader(t, rsaTok, map[string]string{"alg": "RS256", "kid": ecIss.KeyID}), "non-RSA key"},
+		{"none", withHeader(t, rsaTok, map[string]string{"alg": "none", "kid": rsaIss.KeyID}), "unsupported alg"},
+		{"HS256", withHeader(t, rsaTok, map[string]string{"alg": "HS256", "kid": rsaIss.KeyID}), "unsupported alg"},
+		{"unknown kid", withHeader(t, rsaTok, map[string]string{"alg": "RS256", "kid": "nope"}), "unknown signing key"},
+		{"missing kid with two keys", withHeader(t, rsaTok, map[string]string{"alg": "RS256"}), "unknown signing key"},
+	}
+	for _, tt := range tests {
+		_, err := v.VerifyIDToken(context.Background(), tt.token)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestJWKSVerifierClaims(t *testing.T) {
+	iss := newTestIssuer(t, "RS256")
+	v := newFileVerifier(t, JWKSConfig{}, iss)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   string // "" when the token is valid
+	}{
+		{"valid", map[string]interface{}{}, ""},
+		{"expired", map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()}, "expired"},
+		{"expired within leeway", map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}, ""},
+		{"missing exp", map[string]interface{}{"exp": nil}, "missing exp"},
+		{"not yet valid", map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()}, "not valid yet"},
+		{"nbf within leeway", map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()}, ""},
+		{"issued in the future", map[string]interface{}{"iat": now.Add(2 * time.Minute).Unix()}, "future"},
+		{"wrong issuer", map[string]interface{}{"iss": "https://evil.test"}, "unexpected issuer"},
+		{"missing issuer", map[string]interface{}{"iss": nil}, "unexpected issuer"},
+		{"wrong audience", map[string]interface{}{"aud": "other-api"}, "audience"},
+		{"audience list", map[string]interface{}{"aud": []string{"other-api", testAudience}}, ""},
+		{"audience list without ours", map[string]interface{}{"aud": []string{"other-api"}}, "audience"},
+		{"missing sub", map[string]interface{}{"sub": ""}, "missing sub"},
+	}
+	for _, tt := range tests {
+		claims := map[string]interface{}{"sub": "uid-1"}
+		for k, c := range tt.claims {
+			claims[k] = c
+		}
+		raw, err := iss.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = v.VerifyIDToken(context.Background(), raw)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: error = %v, want nil", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestJWKSVerifierRolesClaim(t *testing.T) {
+	iss := newTestIssuer(t, "ES256")
+	v := newFileVerifier(t, JWKSConfig{RolesClaim: "realm_access.roles"}, iss)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   interface{}
+	}{
+		{"nested", map[string]interface{}{
+			"realm_access": map[string]interface{}{"roles": []string{"admin", "customer"}},
+		}, []interface{}{"admin", "customer"}},
+		{"single string", map[string]interface{}{
+			"realm_access": map[string]interface{}{"roles": "customer"},
+		}, []interface{}{"customer"}},
+		// a top-level "roles" must not leak through when the configured path is absent
+		{"missing path", map[string]interface{}{"roles": []string{"admin"}}, nil},
+		{"path through a non-object", map[string]interface{}{"realm_access": "admin"}, nil},
+	}
+	for _, tt := range tests {
+		claims := map[string]interface{}{"sub": "uid-1"}
+		for k, c := range tt.claims {
+			claims[k] = c
+		}
+		raw, err := iss.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok, err := v.VerifyIDToken(context.Background(), raw)
+		if err != nil {
+			t.Fatalf("%s: VerifyIDToken: %v", tt.name, err)
+		}
+		if got := tok.Claims["roles"]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: roles = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// rotatingJWKS serves the key set of the current issuer and counts fetches.
+type rotatingJWKS struct {
+	mu      sync.Mutex
+	current *authtest.Issuer
+	fetches atomic.Int32
+}
+
+func (s *rotatingJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	iss := s.current
+	s.mu.Unlock()
+	iss.ServeHTTP(w, r)
+}
+
+func (s *rotatingJWKS) rotate(iss *authtest.Issuer) {
+	s.mu.Lock()
+	s.current = iss
+	s.mu.Unlock()
+}
+
+func TestJWKSVerifierRefreshesOnUnknownKid(t *testing.T) {
+	oldIss, newIss := newTestIssuer(t, "RS256"), newTestIssuer(t, "ES256")
+	keys := &rotatingJWKS{current: oldIss}
+	srv := httptest.NewServer(keys)
+	defer srv.Close()
+
+	v, err := NewJWKSVerifier(context.Background(), JWKSConfig{
+		URL: srv.URL, Issuer: testIssuer, Audience: testAudience,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Now()
+	v.now = func() time.Time { return clock }
+	if n := keys.fetches.Load(); n != 1 {
+		t.Fatalf("%d fetches after NewJWKSVerifier, want 1", n)
+	}
+
+	keys.rotate(newIss)
+	raw, err := newIss.Token("uid-1", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an unknown kid right after a fetch is not refetched yet
+	if _, err := v.VerifyIDToken(context.Background(), raw); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Errorf("VerifyIDToken within minRefetch error = %v, want unknown signing key", err)
+	}
+	if n := keys.fetches.Load(); n != 1 {
+		t.Errorf("%d fetches within minRefetch, want 1", n)
+	}
+
+	clock = clock.Add(minRefetch)
+	if _, err := v.VerifyIDToken(context.Background(), raw); err != nil {
+		t.Fatalf("VerifyIDToken after rotation: %v", err)
+	}
+	if n := keys.fetches.Load(); n != 2 {
+		t.Errorf("%d fetches after rotation, want 2", n)
+	}
+
+	// the rotated-out key is gone, and known kids do not trigger fetches
+	clock = clock.Add(minRefetch)
+	if _, err := v.VerifyIDToken(context.Background(), raw); err != nil {
+		t.Errorf("VerifyIDToken with known kid: %v", err)
+	}
+	if n := keys.fetches.Load(); n != 2 {
+		t.Errorf("%d fetches for a known kid, want 2", n)
+	}
+	oldTok, err := oldIss.Token("uid-1", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.VerifyIDToken(context.Background(), oldTok); err == nil {
+		t.Error("VerifyIDToken accepted a token signed with a rotated-out key")
+	}
+}
+
+func TestNewJWKSVerifierRequiresIssuerAndAudience(t *testing.T) {
+	file := jwksFile(t, newTestIssuer(t, "RS256"))
+	for _, cfg := range []JWKSConfig{
+		{File: file, Audience: testAudience},
+		{File: file, Issuer: testIssuer},
+		{File: file},
+	} {
+		if _, err := NewJWKSVerifier(context.Background(), cfg); err == nil {
+			t.Errorf("NewJWKSVerifier(issuer %q, audience %q) succeeded", cfg.Issuer, cfg.Audience)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package dataloader batches and caches lookups by key. Loads issued within
+// a short window are collected and fetched together with one call, so
+// resolving a field over a list of parents costs one query instead of one per
+// parent. A Loader caches every result and is meant to live for a single
+// request.
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults for New.
+const (
+	DefaultWait     = 2 * time.Millisecond
+	DefaultMaxBatch = 100
+)
+
+// FetchFunc loads the values for keys, which are distinct. Keys missing from
+// the returned map resolve to the zero value of V. An error fails every key
+// of the batch.
+type FetchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader batches Load calls for keys of type K.
+type Loader[K comparable, V any] struct {
+	fetch    FetchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+	batch *batch[K, V]
+}
+
+type result[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+type batch[K comparable, V any] struct {
+	ctx     context.Context
+	keys    []K
+	results []*result[V]
+}
+
+// New returns a Loader that waits DefaultWait after the first Load of a batch
+// and fetches at most DefaultMaxBatch keys at once.
+func New[K comparable, V any](fetch FetchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     DefaultWait,
+		maxBatch: DefaultMaxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+}
+
+// Load returns the value for key, fetching it with whatever other keys are
+// requested before the batch is dispatched. The fetch runs with the context
+// of the Load that opened the batch.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r, ok := l.cache[key]
+	if !ok {
+		r = &result[V]{done: make(chan struct{})}
+		l.cache[key] = r
+		l.enqueue(ctx, key, r)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.val, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// enqueue adds key to the open batch, opening one if needed, and dispatches
+// the batch once it is full. Callers must hold l.mu.
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K, r *result[V]) {
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{ctx: ctx}
+		l.batch = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	if len(b.keys) >= l.maxBatch {
+		l.batch = nil
+		go l.run(b)
+	}
+}
+
+// dispatch runs b if it is still the open batch; a full batch has already
+// been started by enqueue.
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.batch != b {
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+	l.run(b)
+}
+
+func (l *Loader[K, V]) run(b *batch[K, V]) {
+	vals, err := l.fetch(b.ctx, b.keys)
+	if err != nil {
+		// failures are not cached, so a later Load retries
+		l.mu.Lock()
+		for _, k := range b.keys {
+			delete(l.cache, k)
+		}
+		l.mu.Unlock()
+	}
+	for i, k := range b.keys {
+		r := b.results[i]
+		r.val, r.err = vals[k], err
+		close(r.done)
+	}
+}
@@ -0,0 +1,132 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a FetchFunc that records the keys of every call and answers
+// "v<key>", or err while it is set.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+}
+
+func (r *recorder) fetch(ctx context.Context, keys []int) (map[int]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sorted := append([]int(nil), keys...)
+	sort.Ints(sorted)
+	r.batches = append(r.batches, sorted)
+	if r.err != nil {
+		return nil, r.err
+	}
+	vals := make(map[int]string, len(keys))
+	for _, k := range keys {
+		if k >= 0 { // negative keys do not exist
+			vals[k] = fmt.Sprintf("v%d", k)
+		}
+	}
+	return vals, nil
+}
+
+// calls returns the batches fetched so far, each sorted, in fetch order.
+func (r *recorder) calls() [][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]int(nil), r.batches...)
+}
+
+func newLoader(wait time.Duration, maxBatch int) (*Loader[int, string], *recorder) {
+	rec := &recorder{}
+	l := New(rec.fetch)
+	l.wait, l.maxBatch = wait, maxBatch
+	return l, rec
+}
+
+// loadAll loads keys concurrently and fails t if any value is wrong.
+func loadAll(t *testing.T, l *Loader[int, string], keys ...int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for _, k := range keys {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			if v, err := l.Load(context.Background(), k); err != nil || v != fmt.Sprintf("v%d", k) {
+				t.Errorf("Load(%d) = %q, %v", k, v, err)
+			}
+		}(k)
+	}
+	wg.Wait()
+}
+
+func TestLoaderBatchesWithinWait(t *testing.T) {
+	l, rec := newLoader(50*time.Millisecond, DefaultMaxBatch)
+	loadAll(t, l, 3, 1, 2)
+	if got, want := rec.calls(), [][]int{{1, 2, 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %v, want %v", got, want)
+	}
+}
+
+func TestLoaderDispatchesFullBatch(t *testing.T) {
+	// the wait never ends, so only full batches are fetched
+	l, rec := newLoader(time.Hour, 2)
+	loadAll(t, l, 1, 2, 3, 4)
+	got := rec.calls()
+	if len(got) != 2 || len(got[0]) != 2 || len(got[1]) != 2 {
+		t.Fatalf("fetched %v, want two batches of 2", got)
+	}
+	all := append(append([]int(nil), got[0]...), got[1]...)
+	sort.Ints(all)
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(all, want) {
+		t.Errorf("fetched keys %v, want %v", all, want)
+	}
+}
+
+func TestLoaderDeduplicatesKeys(t *testing.T) {
+	l, rec := newLoader(50*time.Millisecond, DefaultMaxBatch)
+	loadAll(t, l, 1, 2, 1, 1, 2)
+	if got, want := rec.calls(), [][]int{{1, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %v, want %v", got, want)
+	}
+}
+
+func TestLoaderCachesResults(t *testing.T) {
+	l, rec := newLoader(time.Millisecond, DefaultMaxBatch)
+	ctx := context.Background()
+	loadAll(t, l, 1)
+	if v, err := l.Load(ctx, -1); err != nil || v != "" {
+		t.Errorf("Load(missing) = %q, %v; want the zero value", v, err)
+	}
+	loadAll(t, l, 1)
+	if v, err := l.Load(ctx, -1); err != nil || v != "" {
+		t.Errorf("cached Load(missing) = %q, %v; want the zero value", v, err)
+	}
+	if got, want := rec.calls(), [][]int{{1}, {-1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %v, want %v", got, want)
+	}
+}
+
+func TestLoaderDoesNotCacheFailures(t *testing.T) {
+	l, rec := newLoader(time.Millisecond, DefaultMaxBatch)
+	boom := errors.New("boom")
+	rec.err = boom
+	if _, err := l.Load(context.Background(), 1); !errors.Is(err, boom) {
+		t.Fatalf("Load during failure error = %v, want %v", err, boom)
+	}
+
+	rec.mu.Lock()
+	rec.err = nil
+	rec.mu.Unlock()
+	loadAll(t, l, 1)
+	if got, want := rec.calls(), [][]int{{1}, {1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %v, want the failed key fetched again", got)
+	}
+}
@@ -0,0 +1,111 @@
+// Package dbtest is a conformance suite for implementations of the db
+// repository interfaces. Running the same suite against internal/db/memory
+// and internal/db/postgres keeps the two backends from drifting apart:
+//
+//	func TestMemory(t *testing.T)   { dbtest.Run(t, dbtest.Memory) }
+//	func TestPostgres(t *testing.T) { dbtest.Run(t, dbtest.Postgres) }
+//
+// Postgres skips unless TEST_DATABASE_URL points at a disposable database;
+// it migrates that database and empties every table before each case.
+package dbtest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/memory"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/migrate"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/postgres"
+	"github.com/felixojiambo/go-graphql-order-service/migrations"
+)
+
+// Repositories is one backend under test. All repositories must share the
+// same underlying database.
+type Repositories struct {
+	Categories  db.CategoryRepository
+	Products    db.ProductRepository
+	Orders      db.OrderRepository
+	Customers   db.CustomerRepository
+	Inventory   db.InventoryRepository
+	Outbox      db.OutboxRepository
+	Preferences db.NotificationPreferenceRepository
+	Webhooks    db.WebhookRepository
+}
+
+// Factory returns repositories over an empty database. It is called once
+// per test case.
+type Factory func(t *testing.T) Repositories
+
+// Memory is a Factory for the in-memory backend.
+func Memory(t *testing.T) Repositories {
+	s := memory.NewStore()
+	return Repositories{
+		Categories:  memory.NewCategoryRepository(s),
+		Products:    memory.NewProductRepository(s),
+		Orders:      memory.NewOrderRepository(s),
+		Customers:   memory.NewCustomerRepository(s),
+		Inventory:   memory.NewInventoryRepository(s),
+		Outbox:      memory.NewOutboxRepository(s),
+		Preferences: memory.NewNotificationPreferenceRepository(s),
+		Webhooks:    memory.NewWebhookRepository(s),
+	}
+}
+
+// Postgres is a Factory for the Postgres backend at TEST_DATABASE_URL. Every
+// table is truncated, so never point it at a database you care about.
+func Postgres(t *testing.T) Repositories {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	pgDB, err := postgres.Connect(url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { pgDB.Close() })
+
+	ctx := context.Background()
+	m, err := migrate.New(pgDB.DB, migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	const truncate = `
+		TRUNCATE webhook_attempts, webhook_deliveries, webhook_subscriptions,
+		         notification_outbox, notification_preferences,
+		         stock_adjustments, idempotency_keys,
+		         order_status_history, order_items, orders, products,
+		         categories, customers
+		CASCADE
+	`
+	if _, err := pgDB.ExecContext(ctx, truncate); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	return Repositories{
+		Categories:  postgres.NewCategoryRepository(pgDB),
+		Products:    postgres.NewProductRepository(pgDB),
+		Orders:      postgres.NewOrderRepository(pgDB),
+		Customers:   postgres.NewCustomerRepository(pgDB),
+		Inventory:   postgres.NewInventoryRepository(pgDB),
+		Outbox:      postgres.NewOutboxRepository(pgDB),
+		Preferences: postgres.NewNotificationPreferenceRepository(pgDB),
+		Webhooks:    postgres.NewWebhookRepository(pgDB),
+	}
+}
+
+// Run runs every conformance case as a subtest of t, each against a fresh
+// backend from newRepos.
+func Run(t *testing.T, newRepos Factory) {
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, newRepos(t))
+		})
+	}
+}
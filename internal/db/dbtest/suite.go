@@ -0,0 +1,1571 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+)
+
+var cases = []struct {
+	name string
+	run  func(t *testing.T, r Repositories)
+}{
+	{"CategoryTree", testCategoryTree},
+	{"CategoryBulkLookups", testCategoryBulkLookups},
+	{"CategoryRenameMoveDelete", testCategoryRenameMoveDelete},
+	{"CategoryTreeAndAncestors", testCategoryTreeAndAncestors},
+	{"ProductsBySubtree", testProductsBySubtree},
+	{"ProductBulkLookup", testProductBulkLookup},
+	{"ProductUpdateArchiveDelete", testProductUpdateArchiveDelete},
+	{"AveragePriceBySubtree", testAveragePriceBySubtree},
+	{"PriceStats", testPriceStats},
+	{"Customers", testCustomers},
+	{"CustomerFirebaseLink", testCustomerFirebaseLink},
+	{"PlaceOrderReservesStock", testPlaceOrderReservesStock},
+	{"PlaceOrderInsufficientStock", testPlaceOrderInsufficientStock},
+	{"PlaceOrderIdempotency", testPlaceOrderIdempotency},
+	{"OrderStatusTransitions", testOrderStatusTransitions},
+	{"CancelOrderReleasesStock", testCancelOrderReleasesStock},
+	{"UpdateStatusCannotCancel", testUpdateStatusCannotCancel},
+	{"NotificationOutbox", testNotificationOutbox},
+	{"OutboxRelease", testOutboxRelease},
+	{"NotificationPreferences", testNotificationPreferences},
+	{"WebhookDeliveries", testWebhookDeliveries},
+	{"WebhookRelease", testWebhookRelease},
+	{"AdjustStock", testAdjustStock},
+	{"StockLevelsFullProducts", testStockLevelsFullProducts},
+	{"ConcurrentReservations", testConcurrentReservations},
+	{"KeysetPagination", testKeysetPagination},
+}
+
+// firstPage is large enough to hold every row a case creates.
+var firstPage = db.Page{Limit: 1000}
+
+func testCategoryTree(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	a := mkCategory(t, r, "a", &root.ID)
+	b := mkCategory(t, r, "b", &root.ID)
+	mkCategory(t, r, "a1", &a.ID)
+
+	got, err := r.Categories.GetByID(ctx, a.ID)
+	must(t, err)
+	if got.Name != "a" || got.ParentID == nil || *got.ParentID != root.ID {
+		t.Errorf("GetByID = %+v, want a under root", got)
+	}
+
+	roots, err := r.Categories.ListChildren(ctx, nil, firstPage)
+	must(t, err)
+	wantIDs(t, "root categories", categoryIDs(roots), root.ID)
+
+	children, err := r.Categories.ListChildren(ctx, &root.ID, firstPage)
+	must(t, err)
+	wantIDs(t, "children of root", categoryIDs(children), a.ID, b.ID)
+
+	if _, err := r.Categories.GetByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+	unknown := uuid.New()
+	if err := r.Categories.Create(ctx, &db.Category{ID: uuid.New(), Name: "orphan", ParentID: &unknown}); apperror.CodeOf(err) != apperror.CodeValidationFailed {
+		t.Errorf("Create with unknown parent error = %v, want %s", err, apperror.CodeValidationFailed)
+	}
+}
+
+func testCategoryBulkLookups(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	other := mkCategory(t, r, "other", nil)
+	a := mkCategory(t, r, "a", &root.ID)
+	b := mkCategory(t, r, "b", &root.ID)
+	c := mkCategory(t, r, "c", &other.ID)
+	mkCategory(t, r, "a1", &a.ID)
+
+	got, err := r.Categories.GetByIDs(ctx, []uuid.UUID{a.ID, c.ID, uuid.New()})
+	must(t, err)
+	wantIDs(t, "GetByIDs", categoryIDs(got), a.ID, c.ID)
+
+	children, err := r.Categories.ListChildrenOf(ctx, []uuid.UUID{root.ID, other.ID, b.ID})
+	must(t, err)
+	wantIDs(t, "ListChildrenOf", categoryIDs(children), a.ID, b.ID, c.ID)
+	if len(children) == 3 && (children[0].ID != a.ID || children[1].ID != b.ID) {
+		t.Errorf("ListChildrenOf order = %v, want oldest first", categoryIDs(children))
+	}
+
+	// every lookup returns the same full rows as ListChildren
+	listed, err := r.Categories.ListChildren(ctx, &root.ID, firstPage)
+	must(t, err)
+	one, err := r.Categories.GetByID(ctx, a.ID)
+	must(t, err)
+	for name, got := range map[string][]*db.Category{"GetByID": {one}, "GetByIDs": got, "ListChildrenOf": children} {
+		for _, c := range got {
+			if c.CreatedAt.IsZero() || c.UpdatedAt.IsZero() {
+				t.Errorf("%s returned %+v without its timestamps", name, c)
+			}
+			if c.ID == a.ID && (!c.CreatedAt.Equal(listed[0].CreatedAt) || !c.UpdatedAt.Equal(listed[0].UpdatedAt)) {
+				t.Errorf("%s = %+v, want the row ListChildren returns: %+v", name, c, listed[0])
+			}
+		}
+	}
+}
+
+func testCategoryRenameMoveDelete(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	a := mkCategory(t, r, "a", &root.ID)
+	a1 := mkCategory(t, r, "a1", &a.ID)
+	a2 := mkCategory(t, r, "a2", &a1.ID)
+	b := mkCategory(t, r, "b", &root.ID)
+
+	renamed, err := r.Categories.Rename(ctx, a.ID, "renamed")
+	must(t, err)
+	if renamed.Name != "renamed" || renamed.ParentID == nil || *renamed.ParentID != root.ID {
+		t.Errorf("Rename = %+v, want renamed under root", renamed)
+	}
+	if _, err := r.Categories.Rename(ctx, uuid.New(), "x"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Rename(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// a category cannot move under itself or any descendant
+	for _, target := range []uuid.UUID{a.ID, a1.ID, a2.ID} {
+		if _, err := r.Categories.Move(ctx, a.ID, &target); !errors.Is(err, db.ErrCategoryCycle) {
+			t.Errorf("Move(a under %s) error = %v, want db.ErrCategoryCycle", target, err)
+		}
+	}
+	unknown := uuid.New()
+	if _, err := r.Categories.Move(ctx, a.ID, &unknown); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Move under unknown parent error = %v, want sql.ErrNoRows", err)
+	}
+	moved, err := r.Categories.Move(ctx, a1.ID, &b.ID)
+	must(t, err)
+	if moved.ParentID == nil || *moved.ParentID != b.ID {
+		t.Errorf("Move(a1 under b) = %+v, want parent b", moved)
+	}
+	moved, err = r.Categories.Move(ctx, b.ID, nil)
+	must(t, err)
+	if moved.ParentID != nil {
+		t.Errorf("Move(b to root) = %+v, want no parent", moved)
+	}
+	roots, err := r.Categories.ListChildren(ctx, nil, firstPage)
+	must(t, err)
+	wantIDs(t, "roots after Move", categoryIDs(roots), root.ID, b.ID)
+
+	// tree is now root > a and b > a1 > a2
+	pA := mkProduct(t, r, a.ID, "1.00", 1)
+	pA1 := mkProduct(t, r, a1.ID, "1.00", 1)
+	pA2 := mkProduct(t, r, a2.ID, "1.00", 1)
+
+	if err := r.Categories.Delete(ctx, a.ID, db.CategoryDeleteRestrict); !errors.Is(err, db.ErrCategoryInUse) {
+		t.Errorf("Delete(a, restrict) with products error = %v, want db.ErrCategoryInUse", err)
+	}
+	if err := r.Categories.Delete(ctx, b.ID, db.CategoryDeleteRestrict); !errors.Is(err, db.ErrCategoryInUse) {
+		t.Errorf("Delete(b, restrict) with children error = %v, want db.ErrCategoryInUse", err)
+	}
+	if err := r.Categories.Delete(ctx, uuid.New(), db.CategoryDeleteRestrict); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Delete(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// reparenting a1 hands a2 and its products to b
+	must(t, r.Categories.Delete(ctx, a1.ID, db.CategoryDeleteReparent))
+	got, err := r.Categories.GetByID(ctx, a2.ID)
+	must(t, err)
+	if got.ParentID == nil || *got.ParentID != b.ID {
+		t.Errorf("a2 after reparenting delete = %+v, want parent b", got)
+	}
+	if got, err := r.Products.GetByID(ctx, pA1.ID); err != nil || got.CategoryID != b.ID {
+		t.Errorf("product of a1 after reparenting delete = %+v, %v, want category b", got, err)
+	}
+	// b is a root, so its products have nowhere to go
+	if err := r.Categories.Delete(ctx, b.ID, db.CategoryDeleteReparent); !errors.Is(err, db.ErrCategoryInUse) {
+		t.Errorf("Delete(root with products, reparent) error = %v, want db.ErrCategoryInUse", err)
+	}
+
+	// an ordered product blocks a cascade, and nothing is deleted
+	cust := mkCustomer(t, r, "buyer@example.com")
+	o, items := newOrder(cust.ID, itemFor(pA2, 1))
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, nil))
+	if err := r.Categories.Delete(ctx, b.ID, db.CategoryDeleteCascade); !errors.Is(err, db.ErrProductInUse) {
+		t.Errorf("Delete(b, cascade) with ordered product error = %v, want db.ErrProductInUse", err)
+	}
+	if _, err := r.Products.GetByID(ctx, pA1.ID); err != nil {
+		t.Errorf("product after failed cascade: %v", err)
+	}
+
+	must(t, r.Categories.Delete(ctx, root.ID, db.CategoryDeleteCascade))
+	for _, id := range []uuid.UUID{root.ID, a.ID} {
+		if _, err := r.Categories.GetByID(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByID(%s) after cascade error = %v, want sql.ErrNoRows", id, err)
+		}
+	}
+	if _, err := r.Products.GetByID(ctx, pA.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("product after cascade error = %v, want sql.ErrNoRows", err)
+	}
+	roots, err = r.Categories.ListChildren(ctx, nil, firstPage)
+	must(t, err)
+	wantIDs(t, "roots after cascade", categoryIDs(roots), b.ID)
+}
+
+func testCategoryTreeAndAncestors(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	a := mkCategory(t, r, "a", &root.ID)
+	a1 := mkCategory(t, r, "a1", &a.ID)
+	b := mkCategory(t, r, "b", &root.ID)
+	a2 := mkCategory(t, r, "a2", &a.ID)
+	other := mkCategory(t, r, "other", nil)
+
+	tree, err := r.Categories.Tree(ctx, nil, -1)
+	must(t, err)
+	want := []struct {
+		id    uuid.UUID
+		depth int
+		path  []uuid.UUID
+	}{
+		{root.ID, 0, []uuid.UUID{root.ID}},
+		{a.ID, 1, []uuid.UUID{root.ID, a.ID}},
+		{a1.ID, 2, []uuid.UUID{root.ID, a.ID, a1.ID}},
+		{a2.ID, 2, []uuid.UUID{root.ID, a.ID, a2.ID}},
+		{b.ID, 1, []uuid.UUID{root.ID, b.ID}},
+		{other.ID, 0, []uuid.UUID{other.ID}},
+	}
+	if len(tree) != len(want) {
+		t.Fatalf("Tree returned %d nodes, want %d", len(tree), len(want))
+	}
+	for i, w := range want {
+		n := tree[i]
+		if n.ID != w.id || n.Depth != w.depth || fmt.Sprint(n.Path) != fmt.Sprint(w.path) {
+			t.Errorf("Tree[%d] = %s depth %d path %v, want %s depth %d path %v", i, n.ID, n.Depth, n.Path, w.id, w.depth, w.path)
+		}
+	}
+
+	sub, err := r.Categories.Tree(ctx, &a.ID, 0)
+	must(t, err)
+	if len(sub) != 1 || sub[0].ID != a.ID || sub[0].Depth != 0 || len(sub[0].Path) != 1 {
+		t.Errorf("Tree(a, depth 0) = %+v, want only a at depth 0", sub)
+	}
+	shallow, err := r.Categories.Tree(ctx, &root.ID, 1)
+	must(t, err)
+	wantIDs(t, "Tree(root, depth 1)", treeIDs(shallow), root.ID, a.ID, b.ID)
+	unknown := uuid.New()
+	none, err := r.Categories.Tree(ctx, &unknown, -1)
+	must(t, err)
+	if len(none) != 0 {
+		t.Errorf("Tree(unknown) = %d nodes, want none", len(none))
+	}
+
+	anc, err := r.Categories.AncestorsOf(ctx, []uuid.UUID{a1.ID, b.ID, root.ID, uuid.New()})
+	must(t, err)
+	if got := categoryIDs(anc[a1.ID]); fmt.Sprint(got) != fmt.Sprint([]uuid.UUID{root.ID, a.ID}) {
+		t.Errorf("AncestorsOf(a1) = %v, want [root a]", got)
+	}
+	if got := categoryIDs(anc[b.ID]); fmt.Sprint(got) != fmt.Sprint([]uuid.UUID{root.ID}) {
+		t.Errorf("AncestorsOf(b) = %v, want [root]", got)
+	}
+	if len(anc) != 2 {
+		t.Errorf("AncestorsOf returned %d entries, want 2", len(anc))
+	}
+
+	mkProduct(t, r, a1.ID, "1.00", 1)
+	mkProduct(t, r, a2.ID, "1.00", 1)
+	mkProduct(t, r, b.ID, "1.00", 1)
+	archived := mkProduct(t, r, a.ID, "1.00", 1)
+	_, err = r.Products.Archive(ctx, archived.ID)
+	must(t, err)
+	counts, err := r.Products.CountByCategories(ctx, []uuid.UUID{root.ID, a.ID, a1.ID, other.ID, uuid.New()})
+	must(t, err)
+	wantCounts := map[uuid.UUID]int{root.ID: 3, a.ID: 2, a1.ID: 1, other.ID: 0}
+	if fmt.Sprint(counts) != fmt.Sprint(wantCounts) {
+		t.Errorf("CountByCategories = %v, want %v", counts, wantCounts)
+	}
+}
+
+func testProductBulkLookup(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cat := mkCategory(t, r, "cat", nil)
+	p1 := mkProduct(t, r, cat.ID, "1.00", 1)
+	mkProduct(t, r, cat.ID, "2.00", 2)
+	p3 := mkProduct(t, r, cat.ID, "3.00", 3)
+
+	got, err := r.Products.GetByIDs(ctx, []uuid.UUID{p1.ID, p3.ID, uuid.New()})
+	must(t, err)
+	wantIDs(t, "GetByIDs", productIDs(got), p1.ID, p3.ID)
+	for _, p := range got {
+		if p.ID == p3.ID && (p.Stock != 3 || p.Price.String() != p3.Price.String()) {
+			t.Errorf("GetByIDs returned %+v, want %+v", p, p3)
+		}
+	}
+}
+
+func testProductUpdateArchiveDelete(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cat := mkCategory(t, r, "cat", nil)
+	other := mkCategory(t, r, "other", nil)
+	p := mkProduct(t, r, cat.ID, "2.00", 5)
+	kept := mkProduct(t, r, cat.ID, "4.00", 5)
+
+	before, err := r.Products.GetByID(ctx, p.ID)
+	must(t, err)
+	upd := *before
+	upd.Name, upd.Price, upd.CategoryID = "renamed", money.MustParse("3.00", money.DefaultCurrency), other.ID
+	must(t, r.Products.Update(ctx, &upd))
+	got, err := r.Products.GetByID(ctx, p.ID)
+	must(t, err)
+	if got.Name != "renamed" || got.Price != upd.Price || got.CategoryID != other.ID || got.Stock != 5 {
+		t.Errorf("after Update = %+v, want %+v", got, upd)
+	}
+	if !got.UpdatedAt.After(before.UpdatedAt) {
+		t.Errorf("Update did not bump UpdatedAt: %v -> %v", before.UpdatedAt, got.UpdatedAt)
+	}
+	upd.CategoryID = uuid.New()
+	if err := r.Products.Update(ctx, &upd); apperror.CodeOf(err) != apperror.CodeValidationFailed {
+		t.Errorf("Update with unknown category error = %v, want %s", err, apperror.CodeValidationFailed)
+	}
+	missing := upd
+	missing.ID, missing.CategoryID = uuid.New(), cat.ID
+	if err := r.Products.Update(ctx, &missing); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Update(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// archiving hides a product from listings but not from GetByID
+	upd.CategoryID = cat.ID
+	must(t, r.Products.Update(ctx, &upd))
+	archived, err := r.Products.Archive(ctx, p.ID)
+	must(t, err)
+	if archived.ArchivedAt == nil {
+		t.Fatal("Archive did not set ArchivedAt")
+	}
+	again, err := r.Products.Archive(ctx, p.ID)
+	must(t, err)
+	if again.ArchivedAt == nil || !again.ArchivedAt.Equal(*archived.ArchivedAt) {
+		t.Errorf("second Archive moved ArchivedAt from %v to %v", archived.ArchivedAt, again.ArchivedAt)
+	}
+	listed, err := r.Products.ListByCategory(ctx, cat.ID, firstPage)
+	must(t, err)
+	wantIDs(t, "ListByCategory after Archive", productIDs(listed), kept.ID)
+	if n, err := r.Products.CountByCategory(ctx, cat.ID); err != nil || n != 1 {
+		t.Errorf("CountByCategory after Archive = %d, %v, want 1", n, err)
+	}
+	if avg, err := r.Products.AveragePriceByCategory(ctx, cat.ID); err != nil || avg != kept.Price {
+		t.Errorf("AveragePriceByCategory after Archive = %v, %v, want %v", avg, err, kept.Price)
+	}
+	if got, err := r.Products.GetByID(ctx, p.ID); err != nil || got.ArchivedAt == nil {
+		t.Errorf("GetByID(archived) = %+v, %v, want the archived product", got, err)
+	}
+
+	// a product on an order cannot be deleted; one with only stock history can
+	cust := mkCustomer(t, r, "buyer@example.com")
+	o, items := newOrder(cust.ID, itemFor(kept, 1))
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, nil))
+	if err := r.Products.Delete(ctx, kept.ID); !errors.Is(err, db.ErrProductInUse) {
+		t.Errorf("Delete(ordered) error = %v, want db.ErrProductInUse", err)
+	}
+	_, err = r.Inventory.AdjustStock(ctx, p.ID, 1, "recount", "admin-1")
+	must(t, err)
+	must(t, r.Products.Delete(ctx, p.ID))
+	if _, err := r.Products.GetByID(ctx, p.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID(deleted) error = %v, want sql.ErrNoRows", err)
+	}
+	if err := r.Products.Delete(ctx, p.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second Delete error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testProductsBySubtree(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	mid := mkCategory(t, r, "mid", &root.ID)
+	leaf := mkCategory(t, r, "leaf", &mid.ID)
+	other := mkCategory(t, r, "other", nil)
+
+	pRoot := mkProduct(t, r, root.ID, "1.00", 0)
+	pLeaf := mkProduct(t, r, leaf.ID, "2.00", 0)
+	pOther := mkProduct(t, r, other.ID, "3.00", 0)
+
+	for _, tc := range []struct {
+		name     string
+		category uuid.UUID
+		want     []uuid.UUID
+	}{
+		{"root", root.ID, []uuid.UUID{pRoot.ID, pLeaf.ID}},
+		{"mid", mid.ID, []uuid.UUID{pLeaf.ID}},
+		{"other", other.ID, []uuid.UUID{pOther.ID}},
+		{"unknown", uuid.New(), nil},
+	} {
+		got, err := r.Products.ListByCategory(ctx, tc.category, firstPage)
+		must(t, err)
+		wantIDs(t, "ListByCategory("+tc.name+")", productIDs(got), tc.want...)
+	}
+
+	got, err := r.Products.GetByID(ctx, pLeaf.ID)
+	must(t, err)
+	if got.Price != pLeaf.Price || got.CategoryID != leaf.ID {
+		t.Errorf("GetByID = %+v, want %+v", got, pLeaf)
+	}
+	if _, err := r.Products.GetByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+	if err := r.Products.Create(ctx, &db.Product{
+		ID: uuid.New(), Name: "stray", Price: money.MustParse("1", money.DefaultCurrency), CategoryID: uuid.New(),
+	}); apperror.CodeOf(err) != apperror.CodeValidationFailed {
+		t.Errorf("Create with unknown category error = %v, want %s", err, apperror.CodeValidationFailed)
+	}
+}
+
+func testAveragePriceBySubtree(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	child := mkCategory(t, r, "child", &root.ID)
+	half := mkCategory(t, r, "half", nil)
+	empty := mkCategory(t, r, "empty", nil)
+
+	mkProduct(t, r, root.ID, "1.00", 0)
+	mkProduct(t, r, child.ID, "2.00", 0)
+	mkProduct(t, r, child.ID, "2.01", 0)
+	// 0.015 rounds away from zero
+	mkProduct(t, r, half.ID, "0.01", 0)
+	mkProduct(t, r, half.ID, "0.02", 0)
+
+	for _, tc := range []struct {
+		name     string
+		category uuid.UUID
+		want     string
+	}{
+		{"root", root.ID, "1.67"},
+		{"child", child.ID, "2.01"},
+		{"half", half.ID, "0.02"},
+		{"empty", empty.ID, "0.00"},
+		{"unknown", uuid.New(), "0.00"},
+	} {
+		got, err := r.Products.AveragePriceByCategory(ctx, tc.category)
+		must(t, err)
+		if want := money.MustParse(tc.want, money.DefaultCurrency); got != want {
+			t.Errorf("AveragePriceByCategory(%s) = %v, want %v", tc.name, got, want)
+		}
+	}
+}
+
+func testPriceStats(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	child := mkCategory(t, r, "child", &root.ID)
+	narrow := mkCategory(t, r, "narrow", nil)
+	empty := mkCategory(t, r, "empty", nil)
+
+	mkProduct(t, r, root.ID, "2.00", 0)
+	mkProduct(t, r, root.ID, "1.00", 0)
+	mkProduct(t, r, root.ID, "4.00", 0)
+	mkProduct(t, r, child.ID, "10.00", 0)
+	mkProduct(t, r, child.ID, "0.01", 0)
+	archived := mkProduct(t, r, child.ID, "100.00", 0)
+	_, err := r.Products.Archive(ctx, archived.ID)
+	must(t, err)
+	mkProduct(t, r, narrow.ID, "1.00", 0)
+	mkProduct(t, r, narrow.ID, "1.01", 0)
+
+	for _, tc := range []struct {
+		name     string
+		category uuid.UUID
+		subtree  bool
+		buckets  int
+		want     string
+	}{
+		// p90 interpolates 80% of the way from 4.00 to 10.00
+		{"subtree", root.ID, true, 4, "n=5 min=0.01 max=10.00 mean=3.40 median=2.00 p90=7.60 sd=3.55 " +
+			"[0.01,2.50):3 [2.50,5.00):1 [5.00,7.50):0 [7.50,10.00]:1"},
+		{"direct", root.ID, false, 2, "n=3 min=1.00 max=4.00 mean=2.33 median=2.00 p90=3.60 sd=1.25 " +
+			"[1.00,2.50):2 [2.50,4.00]:1"},
+		// a one-cent range cannot be split into three buckets
+		{"narrow", narrow.ID, true, 3, "n=2 min=1.00 max=1.01 mean=1.01 median=1.01 p90=1.01 sd=0.01 " +
+			"[1.00,1.01]:2"},
+		{"empty", empty.ID, true, 10, "n=0 min=null max=null mean=null median=null p90=null sd=null"},
+	} {
+		got, err := r.Products.PriceStatsByCategory(ctx, tc.category, tc.subtree, tc.buckets)
+		must(t, err)
+		if s := describePriceStats(got); s != tc.want {
+			t.Errorf("PriceStatsByCategory(%s) =\n  %s\nwant\n  %s", tc.name, s, tc.want)
+		}
+	}
+	if _, err := r.Products.PriceStatsByCategory(ctx, uuid.New(), true, 10); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("PriceStatsByCategory(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func describePriceStats(st *db.PriceStats) string {
+	amount := func(m *money.Money) string {
+		if m == nil {
+			return "null"
+		}
+		return m.Decimal()
+	}
+	out := fmt.Sprintf("n=%d min=%s max=%s mean=%s median=%s p90=%s sd=%s",
+		st.Count, amount(st.Min), amount(st.Max), amount(st.Mean), amount(st.Median), amount(st.P90), amount(st.StdDev))
+	for i, b := range st.Histogram {
+		end := ")"
+		if i == len(st.Histogram)-1 {
+			end = "]"
+		}
+		out += fmt.Sprintf(" [%s,%s%s:%d", b.Min.Decimal(), b.Max.Decimal(), end, b.Count)
+	}
+	return out
+}
+
+func testCustomers(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	var all []*db.Customer
+	for i := 0; i < 3; i++ {
+		all = append(all, mkCustomer(t, r, fmt.Sprintf("c%d@example.com", i)))
+	}
+
+	got, err := r.Customers.GetByEmail(ctx, "C1@Example.com")
+	must(t, err)
+	if got.ID != all[1].ID {
+		t.Errorf("GetByEmail ignoring case = %v, want %v", got.ID, all[1].ID)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Error("Create did not fill CreatedAt")
+	}
+	if got.Locale != "en" || got.Phone != nil {
+		t.Errorf("Create defaults = locale %q, phone %v; want en and none", got.Locale, got.Phone)
+	}
+
+	dup := &db.Customer{ID: uuid.New(), Name: "dup", Email: all[0].Email}
+	if err := r.Customers.Create(ctx, dup); apperror.CodeOf(err) != apperror.CodeConflict {
+		t.Errorf("Create with duplicate email error = %v, want %s", err, apperror.CodeConflict)
+	}
+
+	page, err := r.Customers.List(ctx, db.Page{Limit: 2, After: &db.Cursor{CreatedAt: all[0].CreatedAt, ID: all[0].ID}})
+	must(t, err)
+	if len(page) != 2 || page[0].ID != all[1].ID || page[1].ID != all[2].ID {
+		t.Errorf("List after customer 0 = %v, want customers 1 and 2 in creation order", customerIDs(page))
+	}
+
+	upd := *all[0]
+	phone := "+4915112345678"
+	upd.Name, upd.Phone, upd.Locale = "renamed", &phone, "de-at"
+	must(t, r.Customers.Update(ctx, &upd))
+	got, err = r.Customers.GetByID(ctx, upd.ID)
+	must(t, err)
+	if got.Name != "renamed" || got.Phone == nil || *got.Phone != phone || got.Locale != "de-at" {
+		t.Errorf("after Update = %+v, want name, phone and locale changed", got)
+	}
+
+	missing := &db.Customer{ID: uuid.New(), Name: "x", Email: "missing@example.com"}
+	if err := r.Customers.Update(ctx, missing); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Update(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := r.Customers.GetByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testCustomerFirebaseLink(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	c := mkCustomer(t, r, "link@example.com")
+	other := mkCustomer(t, r, "other@example.com")
+
+	if _, err := r.Customers.GetByFirebaseUID(ctx, "uid-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByFirebaseUID before link error = %v, want sql.ErrNoRows", err)
+	}
+	must(t, r.Customers.LinkFirebaseUID(ctx, c.ID, "uid-1"))
+
+	got, err := r.Customers.GetByFirebaseUID(ctx, "uid-1")
+	must(t, err)
+	if got.ID != c.ID || got.FirebaseUID == nil || *got.FirebaseUID != "uid-1" {
+		t.Errorf("GetByFirebaseUID = %+v, want customer %v", got, c.ID)
+	}
+
+	if err := r.Customers.LinkFirebaseUID(ctx, c.ID, "uid-2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("relinking error = %v, want sql.ErrNoRows", err)
+	}
+	if err := r.Customers.LinkFirebaseUID(ctx, other.ID, "uid-1"); apperror.CodeOf(err) != apperror.CodeConflict {
+		t.Errorf("linking a UID already in use error = %v, want %s", err, apperror.CodeConflict)
+	}
+}
+
+func testPlaceOrderReservesStock(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "2.50", 10)
+
+	o, items := newOrder(cust.ID, itemFor(p, 3), itemFor(p, 2))
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, nil))
+
+	got, gotItems, err := r.Orders.GetByID(ctx, o.ID)
+	must(t, err)
+	if got.CustomerID != cust.ID || got.Status != "pending" || got.Total != o.Total {
+		t.Errorf("GetByID = %+v, want %+v", got, o)
+	}
+	if len(gotItems) != 2 {
+		t.Errorf("GetByID returned %d items, want 2", len(gotItems))
+	}
+	wantStock(t, r, p.ID, 5)
+
+	adjs, err := r.Inventory.ListAdjustments(ctx, p.ID, 10)
+	must(t, err)
+	if len(adjs) != 1 || adjs[0].Delta != -5 || adjs[0].QuantityAfter != 5 ||
+		adjs[0].Reason != db.StockReasonOrderPlaced || adjs[0].OrderID == nil || *adjs[0].OrderID != o.ID {
+		t.Errorf("adjustments after order = %+v, want one reservation of 5", adjs)
+	}
+
+	list, err := r.Orders.ListByCustomer(ctx, cust.ID, firstPage)
+	must(t, err)
+	wantIDs(t, "ListByCustomer", orderIDs(list), o.ID)
+
+	if _, _, err := r.Orders.GetByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testPlaceOrderInsufficientStock(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	ok := mkProduct(t, r, cat.ID, "1.00", 5)
+	shortA := mkProduct(t, r, cat.ID, "1.00", 1)
+	shortB := mkProduct(t, r, cat.ID, "1.00", 0)
+
+	o, items := newOrder(cust.ID, itemFor(ok, 1), itemFor(shortA, 2), itemFor(shortB, 1))
+	err := r.Orders.CreateOrder(ctx, o, items, nil, nil, nil)
+	var stockErr *db.InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("CreateOrder error = %v, want *db.InsufficientStockError", err)
+	}
+	wantIDs(t, "short products", stockErr.ProductIDs, shortA.ID, shortB.ID)
+
+	if _, _, err := r.Orders.GetByID(ctx, o.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("failed order was stored: GetByID error = %v", err)
+	}
+	wantStock(t, r, ok.ID, 5)
+	wantStock(t, r, shortA.ID, 1)
+}
+
+func testPlaceOrderIdempotency(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 10)
+
+	key := func(hash string, ttl time.Duration) *db.IdempotencyKey {
+		return &db.IdempotencyKey{
+			CustomerID:  cust.ID,
+			Key:         "retry-me",
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+	}
+
+	first, items := newOrder(cust.ID, itemFor(p, 1))
+	must(t, r.Orders.CreateOrder(ctx, first, items, key("h1", time.Hour), nil, nil))
+
+	retry, items := newOrder(cust.ID, itemFor(p, 1))
+	err := r.Orders.CreateOrder(ctx, retry, items, key("h2", time.Hour), nil, nil)
+	var replay *db.IdempotencyReplayError
+	if !errors.As(err, &replay) {
+		t.Fatalf("CreateOrder with used key error = %v, want *db.IdempotencyReplayError", err)
+	}
+	if replay.OrderID != first.ID || replay.RequestHash != "h1" {
+		t.Errorf("replay = %+v, want order %v with hash h1", replay, first.ID)
+	}
+	if _, _, err := r.Orders.GetByID(ctx, retry.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("replayed order was stored: GetByID error = %v", err)
+	}
+	wantStock(t, r, p.ID, 9)
+	held, err := r.Orders.GetIdempotencyKey(ctx, cust.ID, "retry-me")
+	must(t, err)
+	if held.OrderID != first.ID || held.RequestHash != "h1" {
+		t.Errorf("GetIdempotencyKey = %+v, want order %v with hash h1", held, first.ID)
+	}
+
+	// an expired key is replaced rather than replayed
+	expired := &db.IdempotencyKey{CustomerID: cust.ID, Key: "stale", RequestHash: "h", ExpiresAt: time.Now().Add(-time.Hour)}
+	old, items := newOrder(cust.ID, itemFor(p, 1))
+	must(t, r.Orders.CreateOrder(ctx, old, items, expired, nil, nil))
+	if _, err := r.Orders.GetIdempotencyKey(ctx, cust.ID, "stale"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetIdempotencyKey(expired) error = %v, want sql.ErrNoRows", err)
+	}
+	fresh, items := newOrder(cust.ID, itemFor(p, 1))
+	must(t, r.Orders.CreateOrder(ctx, fresh, items, &db.IdempotencyKey{
+		CustomerID: cust.ID, Key: "stale", RequestHash: "h", ExpiresAt: time.Now().Add(time.Hour),
+	}, nil, nil))
+	wantStock(t, r, p.ID, 7)
+
+	// the replaced key now replays the fresh order
+	again, items := newOrder(cust.ID, itemFor(p, 1))
+	err = r.Orders.CreateOrder(ctx, again, items, &db.IdempotencyKey{
+		CustomerID: cust.ID, Key: "stale", RequestHash: "h", ExpiresAt: time.Now().Add(time.Hour),
+	}, nil, nil)
+	if !errors.As(err, &replay) || replay.OrderID != fresh.ID {
+		t.Errorf("CreateOrder with replaced key error = %v, want a replay of order %v", err, fresh.ID)
+	}
+
+	// keys are scoped to the customer
+	other := mkCustomer(t, r, "other@example.com")
+	theirs, items := newOrder(other.ID, itemFor(p, 1))
+	must(t, r.Orders.CreateOrder(ctx, theirs, items, &db.IdempotencyKey{
+		CustomerID: other.ID, Key: "retry-me", RequestHash: "h1", ExpiresAt: time.Now().Add(time.Hour),
+	}, nil, nil))
+	wantStock(t, r, p.ID, 6)
+	if _, err := r.Orders.GetIdempotencyKey(ctx, other.ID, "stale"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetIdempotencyKey(other customer) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testOrderStatusTransitions(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	o := mkOrder(t, r)
+
+	must(t, r.Orders.UpdateStatus(ctx, o.ID, "pending", "paid", "admin-1", nil))
+	if err := r.Orders.UpdateStatus(ctx, o.ID, "pending", "shipped", "admin-2", nil); !errors.Is(err, db.ErrStatusConflict) {
+		t.Errorf("stale UpdateStatus error = %v, want db.ErrStatusConflict", err)
+	}
+	must(t, r.Orders.UpdateStatus(ctx, o.ID, "paid", "shipped", "admin-1", nil))
+
+	got, _, err := r.Orders.GetByID(ctx, o.ID)
+	must(t, err)
+	if got.Status != "shipped" {
+		t.Errorf("Status = %q, want shipped", got.Status)
+	}
+
+	hist, err := r.Orders.ListStatusHistory(ctx, o.ID)
+	must(t, err)
+	var steps []string
+	for _, h := range hist {
+		steps = append(steps, h.FromStatus+">"+h.ToStatus+"@"+h.ActorUID)
+	}
+	if want := "pending>paid@admin-1 paid>shipped@admin-1"; strings.Join(steps, " ") != want {
+		t.Errorf("history = %v, want %s", steps, want)
+	}
+
+	if err := r.Orders.UpdateStatus(ctx, uuid.New(), "pending", "paid", "admin-1", nil); !errors.Is(err, db.ErrStatusConflict) {
+		t.Errorf("UpdateStatus(unknown) error = %v, want db.ErrStatusConflict", err)
+	}
+}
+
+func testCancelOrderReleasesStock(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 4)
+
+	o, items := newOrder(cust.ID, itemFor(p, 3))
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, nil))
+	wantStock(t, r, p.ID, 1)
+
+	note := "changed my mind"
+	must(t, r.Orders.CancelOrder(ctx, o.ID, "pending", "uid-1", "other", &note, nil, nil))
+	if err := r.Orders.CancelOrder(ctx, o.ID, "pending", "uid-1", "other", &note, nil, nil); !errors.Is(err, db.ErrStatusConflict) {
+		t.Errorf("second CancelOrder error = %v, want db.ErrStatusConflict", err)
+	}
+	wantStock(t, r, p.ID, 4)
+
+	got, _, err := r.Orders.GetByID(ctx, o.ID)
+	must(t, err)
+	if got.Status != "cancelled" || got.CancelReason == nil || *got.CancelReason != "other" ||
+		got.CancelNote == nil || *got.CancelNote != note || got.CancelledAt == nil {
+		t.Errorf("cancelled order = %+v", got)
+	}
+
+	adjs, err := r.Inventory.ListAdjustments(ctx, p.ID, 10)
+	must(t, err)
+	if len(adjs) != 2 || adjs[0].Reason != db.StockReasonOrderCancelled || adjs[0].Delta != 3 || adjs[0].QuantityAfter != 4 {
+		t.Errorf("adjustments after cancel = %+v, want release of 3 first", adjs)
+	}
+}
+
+// testUpdateStatusCannotCancel checks that a cancellation cannot bypass
+// CancelOrder and keep the order's stock reserved.
+func testUpdateStatusCannotCancel(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 4)
+
+	o, items := newOrder(cust.ID, itemFor(p, 3))
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, nil))
+	if err := r.Orders.UpdateStatus(ctx, o.ID, "pending", "cancelled", "admin-1", nil); !errors.Is(err, db.ErrCancelViaUpdateStatus) {
+		t.Errorf("UpdateStatus to cancelled error = %v, want db.ErrCancelViaUpdateStatus", err)
+	}
+	got, _, err := r.Orders.GetByID(ctx, o.ID)
+	must(t, err)
+	if got.Status != "pending" {
+		t.Errorf("Status = %q, want pending", got.Status)
+	}
+	wantStock(t, r, p.ID, 1)
+
+	must(t, r.Orders.CancelOrder(ctx, o.ID, "pending", "admin-1", "other", nil, nil, nil))
+	wantStock(t, r, p.ID, 4)
+}
+
+func testNotificationOutbox(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 1)
+	email := func(orderID uuid.UUID, subject string) *db.OutboxMessage {
+		return &db.OutboxMessage{
+			OrderID: &orderID, Channel: db.ChannelEmail,
+			Recipient: cust.Email, Subject: subject, Body: "body",
+		}
+	}
+
+	// messages are stored with the order, and not at all when it fails
+	o, items := newOrder(cust.ID, itemFor(p, 1))
+	placed := email(o.ID, "placed")
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, []*db.OutboxMessage{placed}, nil))
+	if placed.Status != db.OutboxPending || placed.Attempts != 0 || placed.CreatedAt.IsZero() {
+		t.Errorf("enqueued message = %+v, want pending with no attempts", placed)
+	}
+	short, items := newOrder(cust.ID, itemFor(p, 1))
+	var stockErr *db.InsufficientStockError
+	if err := r.Orders.CreateOrder(ctx, short, items, nil, []*db.OutboxMessage{email(short.ID, "lost")}, nil); !errors.As(err, &stockErr) {
+		t.Fatalf("CreateOrder error = %v, want *db.InsufficientStockError", err)
+	}
+	if _, err := r.Outbox.Claim(ctx, 10, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if msgs, err := r.Outbox.ListByOrder(ctx, short.ID); err != nil || len(msgs) != 0 {
+		t.Errorf("ListByOrder(failed order) = %d messages, %v; want none", len(msgs), err)
+	}
+
+	// a claimed message is leased until marked or the lease ends
+	must(t, r.Outbox.Enqueue(ctx, email(o.ID, "second")))
+	got, err := r.Outbox.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(got) != 1 || got[0].Subject != "second" || got[0].Attempts != 1 || got[0].LockedUntil == nil {
+		t.Fatalf("Claim = %+v, want only the unleased message with 1 attempt", got)
+	}
+	if again, err := r.Outbox.Claim(ctx, 10, time.Hour); err != nil || len(again) != 0 {
+		t.Errorf("Claim while leased = %d messages, %v; want none", len(again), err)
+	}
+
+	// a failure with retryAt reschedules; without it the message is dead
+	retryAt := time.Now().Add(-time.Second)
+	must(t, r.Outbox.MarkFailed(ctx, placed.ID, "timeout", &retryAt))
+	must(t, r.Outbox.MarkFailed(ctx, got[0].ID, "mailbox unavailable", nil))
+	if err := r.Outbox.MarkSent(ctx, got[0].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("MarkSent(dead) error = %v, want sql.ErrNoRows", err)
+	}
+	retried, err := r.Outbox.Claim(ctx, 10, 0)
+	must(t, err)
+	if len(retried) != 1 || retried[0].ID != placed.ID || retried[0].Attempts != 2 ||
+		retried[0].LastError == nil || *retried[0].LastError != "timeout" {
+		t.Fatalf("Claim after retry = %+v, want placed on its 2nd attempt", retried)
+	}
+
+	// an expired lease makes the message claimable again
+	expired, err := r.Outbox.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(expired) != 1 || expired[0].ID != placed.ID || expired[0].Attempts != 3 {
+		t.Fatalf("Claim after lease expiry = %+v, want placed on its 3rd attempt", expired)
+	}
+	must(t, r.Outbox.MarkSent(ctx, placed.ID))
+	if err := r.Outbox.MarkFailed(ctx, placed.ID, "late", nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("MarkFailed(sent) error = %v, want sql.ErrNoRows", err)
+	}
+
+	msgs, err := r.Outbox.ListByOrder(ctx, o.ID)
+	must(t, err)
+	if len(msgs) != 2 || msgs[0].Status != db.OutboxSent || msgs[0].SentAt == nil ||
+		msgs[1].Status != db.OutboxDead || msgs[1].LastError == nil {
+		t.Errorf("ListByOrder = %+v, want placed sent then second dead", msgs)
+	}
+
+	// dead letters can be listed and requeued
+	dead, err := r.Outbox.ListByStatus(ctx, db.OutboxDead, 10)
+	must(t, err)
+	if len(dead) != 1 || dead[0].ID != got[0].ID {
+		t.Fatalf("ListByStatus(dead) = %+v, want the second message", dead)
+	}
+	requeued, err := r.Outbox.Requeue(ctx, dead[0].ID)
+	must(t, err)
+	if requeued.Status != db.OutboxPending || requeued.Attempts != 0 {
+		t.Errorf("Requeue = %+v, want pending with no attempts", requeued)
+	}
+	if _, err := r.Outbox.Requeue(ctx, dead[0].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Requeue(pending) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// cancelling enqueues in the same transaction; a conflict enqueues nothing
+	must(t, r.Orders.CancelOrder(ctx, o.ID, "pending", "uid-1", "other", nil, []*db.OutboxMessage{email(o.ID, "cancelled")}, nil))
+	err = r.Orders.CancelOrder(ctx, o.ID, "pending", "uid-1", "other", nil, []*db.OutboxMessage{email(o.ID, "twice")}, nil)
+	if !errors.Is(err, db.ErrStatusConflict) {
+		t.Errorf("second CancelOrder error = %v, want db.ErrStatusConflict", err)
+	}
+	msgs, err = r.Outbox.ListByOrder(ctx, o.ID)
+	must(t, err)
+	if len(msgs) != 3 || msgs[2].Subject != "cancelled" {
+		t.Errorf("ListByOrder after cancel = %d messages, want 3 ending with the cancellation", len(msgs))
+	}
+	if err := r.Outbox.Enqueue(ctx, email(uuid.New(), "orphan")); err == nil {
+		t.Error("Enqueue for unknown order succeeded")
+	}
+}
+
+func testOutboxRelease(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 1)
+	o, items := newOrder(cust.ID, itemFor(p, 1))
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, nil))
+	enqueue := func(subject string) *db.OutboxMessage {
+		m := &db.OutboxMessage{
+			OrderID: &o.ID, Channel: db.ChannelEmail,
+			Recipient: cust.Email, Subject: subject, Body: "body",
+		}
+		must(t, r.Outbox.Enqueue(ctx, m))
+		return m
+	}
+
+	// a released message is claimable again without the attempt counting
+	enqueue("first")
+	got, err := r.Outbox.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(got) != 1 || got[0].LockedUntil == nil {
+		t.Fatalf("Claim = %+v, want one leased message", got)
+	}
+	if err := r.Outbox.Release(ctx, got[0].ID, got[0].LockedUntil.Add(time.Second)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Release with another lease error = %v, want sql.ErrNoRows", err)
+	}
+	must(t, r.Outbox.Release(ctx, got[0].ID, *got[0].LockedUntil))
+	if err := r.Outbox.Release(ctx, got[0].ID, *got[0].LockedUntil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second Release error = %v, want sql.ErrNoRows", err)
+	}
+	again, err := r.Outbox.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(again) != 1 || again[0].ID != got[0].ID || again[0].Attempts != 1 {
+		t.Fatalf("Claim after Release = %+v, want the message on its 1st attempt", again)
+	}
+	must(t, r.Outbox.MarkSent(ctx, again[0].ID))
+
+	// once another caller has claimed the message, a stale release is a no-op
+	enqueue("second")
+	stale, err := r.Outbox.Claim(ctx, 10, 0)
+	must(t, err)
+	if len(stale) != 1 {
+		t.Fatalf("Claim = %d messages, want 1", len(stale))
+	}
+	current, err := r.Outbox.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(current) != 1 || current[0].Attempts != 2 {
+		t.Fatalf("Claim after lease expiry = %+v, want the message on its 2nd attempt", current)
+	}
+	if err := r.Outbox.Release(ctx, stale[0].ID, *stale[0].LockedUntil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Release of an expired lease error = %v, want sql.ErrNoRows", err)
+	}
+	if leased, err := r.Outbox.Claim(ctx, 10, time.Hour); err != nil || len(leased) != 0 {
+		t.Errorf("Claim after stale Release = %d messages, %v; want none", len(leased), err)
+	}
+	msgs, err := r.Outbox.ListByOrder(ctx, o.ID)
+	must(t, err)
+	if len(msgs) != 2 || msgs[1].Attempts != 2 || msgs[1].LockedUntil == nil {
+		t.Errorf("after stale Release = %+v, want the second message still leased on its 2nd attempt", msgs)
+	}
+}
+
+func testNotificationPreferences(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+
+	// customers start with every channel, no opt-outs and no quiet hours
+	p, err := r.Preferences.Get(ctx, cust.ID)
+	must(t, err)
+	if len(p.Channels) != 2 || len(p.OptedOutEvents) != 0 || p.QuietStart != nil || p.UpdatedAt != nil {
+		t.Errorf("default preferences = %+v, want every channel and nothing else", p)
+	}
+	if _, err := r.Preferences.Get(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get(unknown customer) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// Save replaces everything, quiet hours included
+	start, end := 22*60, 7*60
+	p = &db.NotificationPreferences{
+		CustomerID: cust.ID, Channels: []string{db.ChannelEmail}, OptedOutEvents: []string{"order_cancelled"},
+		QuietStart: &start, QuietEnd: &end, TimeZone: "Europe/Berlin",
+	}
+	must(t, r.Preferences.Save(ctx, p))
+	if p.UpdatedAt == nil {
+		t.Error("Save did not set UpdatedAt")
+	}
+	got, err := r.Preferences.Get(ctx, cust.ID)
+	must(t, err)
+	if len(got.Channels) != 1 || got.Channels[0] != db.ChannelEmail || len(got.OptedOutEvents) != 1 ||
+		got.QuietStart == nil || *got.QuietStart != start || *got.QuietEnd != end || got.TimeZone != "Europe/Berlin" {
+		t.Errorf("saved preferences = %+v, want email only, one opt-out and 22:00-07:00 in Berlin", got)
+	}
+	must(t, r.Preferences.Save(ctx, &db.NotificationPreferences{CustomerID: cust.ID, TimeZone: "UTC"}))
+	got, err = r.Preferences.Get(ctx, cust.ID)
+	must(t, err)
+	if len(got.Channels) != 0 || len(got.OptedOutEvents) != 0 || got.QuietStart != nil {
+		t.Errorf("replaced preferences = %+v, want no channels and no quiet hours", got)
+	}
+
+	for name, bad := range map[string]*db.NotificationPreferences{
+		"unknown customer": {CustomerID: uuid.New(), TimeZone: "UTC"},
+		"unknown channel":  {CustomerID: cust.ID, Channels: []string{"pigeon"}, TimeZone: "UTC"},
+		"half quiet hours": {CustomerID: cust.ID, QuietStart: &start, TimeZone: "UTC"},
+		"empty window":     {CustomerID: cust.ID, QuietStart: &start, QuietEnd: &start, TimeZone: "UTC"},
+	} {
+		if err := r.Preferences.Save(ctx, bad); err == nil {
+			t.Errorf("Save(%s) succeeded", name)
+		}
+	}
+
+	// the outbox keeps whose message it is, and records suppressions and
+	// postponements
+	msg := func() *db.OutboxMessage {
+		return &db.OutboxMessage{
+			CustomerID: &cust.ID, Event: "order_placed", Channel: db.ChannelSMS,
+			Recipient: "+15550100", Body: "placed",
+		}
+	}
+	held, dropped := msg(), msg()
+	must(t, r.Outbox.Enqueue(ctx, held, dropped))
+	claimed, err := r.Outbox.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(claimed) != 2 || claimed[0].CustomerID == nil || *claimed[0].CustomerID != cust.ID || claimed[0].Event != "order_placed" {
+		t.Fatalf("Claim = %+v, want both messages with customer and event", claimed)
+	}
+	must(t, r.Outbox.MarkSuppressed(ctx, dropped.ID, "customer disabled sms"))
+	until := time.Now().Add(-time.Second)
+	must(t, r.Outbox.Postpone(ctx, held.ID, until))
+	if err := r.Outbox.Postpone(ctx, dropped.ID, until); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Postpone(suppressed) error = %v, want sql.ErrNoRows", err)
+	}
+	again, err := r.Outbox.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(again) != 1 || again[0].ID != held.ID || again[0].Attempts != 1 {
+		t.Errorf("Claim after Postpone = %+v, want the held message on its 1st attempt again", again)
+	}
+	suppressed, err := r.Outbox.ListByStatus(ctx, db.OutboxSuppressed, 10)
+	must(t, err)
+	if len(suppressed) != 1 || suppressed[0].ID != dropped.ID || suppressed[0].SuppressedReason == nil ||
+		*suppressed[0].SuppressedReason != "customer disabled sms" {
+		t.Errorf("ListByStatus(suppressed) = %+v, want the dropped message with its reason", suppressed)
+	}
+	orphan := msg()
+	unknown := uuid.New()
+	orphan.CustomerID = &unknown
+	if err := r.Outbox.Enqueue(ctx, orphan); err == nil {
+		t.Error("Enqueue for unknown customer succeeded")
+	}
+}
+
+func testWebhookDeliveries(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 1)
+	event := func(eventType string, orderID uuid.UUID) *db.WebhookEvent {
+		return &db.WebhookEvent{
+			ID: uuid.New(), Type: eventType, OrderID: &orderID,
+			Payload: []byte(`{"type":"` + eventType + `"}`),
+		}
+	}
+	subscribe := func(url string, active bool, types ...string) *db.WebhookSubscription {
+		s := &db.WebhookSubscription{ID: uuid.New(), URL: url, Secret: "0123456789abcdef", EventTypes: types, Active: active}
+		must(t, r.Webhooks.CreateSubscription(ctx, s))
+		return s
+	}
+	all := subscribe("https://all.example.com", true, db.WebhookEventTypes...)
+	placedOnly := subscribe("https://placed.example.com", true, db.WebhookOrderPlaced)
+	off := subscribe("https://off.example.com", false, db.WebhookEventTypes...)
+
+	if err := r.Webhooks.CreateSubscription(ctx, &db.WebhookSubscription{ID: uuid.New(), URL: "https://x.example.com", Secret: "s"}); err == nil {
+		t.Error("CreateSubscription without event types succeeded")
+	}
+	subs, err := r.Webhooks.ListSubscriptions(ctx)
+	must(t, err)
+	if len(subs) != 3 || subs[0].ID != all.ID || subs[2].ID != off.ID || len(subs[0].EventTypes) != 3 {
+		t.Errorf("ListSubscriptions = %+v, want the three in creation order", subs)
+	}
+
+	// an order event reaches each active subscription of its type, with the
+	// order or not at all
+	o, items := newOrder(cust.ID, itemFor(p, 1))
+	must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, []*db.WebhookEvent{event(db.WebhookOrderPlaced, o.ID)}))
+	short, items := newOrder(cust.ID, itemFor(p, 1))
+	if err := r.Orders.CreateOrder(ctx, short, items, nil, nil, []*db.WebhookEvent{event(db.WebhookOrderPlaced, short.ID)}); err == nil {
+		t.Fatal("CreateOrder beyond stock succeeded")
+	}
+	must(t, r.Orders.UpdateStatus(ctx, o.ID, "pending", "paid", "admin-1", []*db.WebhookEvent{event(db.WebhookOrderStatusChanged, o.ID)}))
+	if err := r.Orders.UpdateStatus(ctx, o.ID, "pending", "paid", "admin-1", []*db.WebhookEvent{event(db.WebhookOrderStatusChanged, o.ID)}); !errors.Is(err, db.ErrStatusConflict) {
+		t.Fatalf("stale UpdateStatus error = %v, want db.ErrStatusConflict", err)
+	}
+	must(t, r.Orders.CancelOrder(ctx, o.ID, "paid", "admin-1", "other", nil, nil, []*db.WebhookEvent{event(db.WebhookOrderCancelled, o.ID)}))
+
+	count := func(sub *db.WebhookSubscription) int {
+		ds, err := r.Webhooks.ListDeliveries(ctx, sub.ID, nil, 50)
+		must(t, err)
+		return len(ds)
+	}
+	if n, m, k := count(all), count(placedOnly), count(off); n != 3 || m != 1 || k != 0 {
+		t.Fatalf("deliveries per subscription = %d, %d, %d; want 3, 1, 0", n, m, k)
+	}
+	newest, err := r.Webhooks.ListDeliveries(ctx, all.ID, nil, 1)
+	must(t, err)
+	if len(newest) != 1 || newest[0].EventType != db.WebhookOrderCancelled || newest[0].Status != db.DeliveryPending ||
+		newest[0].OrderID == nil || *newest[0].OrderID != o.ID || !strings.Contains(string(newest[0].Payload), db.WebhookOrderCancelled) {
+		t.Errorf("newest delivery = %+v, want the pending cancellation", newest)
+	}
+
+	// claimed deliveries are leased; each outcome is logged as an attempt
+	claimed, err := r.Webhooks.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(claimed) != 4 || claimed[0].Attempts != 1 || claimed[0].LockedUntil == nil {
+		t.Fatalf("Claim = %d deliveries, want all 4 on their first attempt", len(claimed))
+	}
+	if again, err := r.Webhooks.Claim(ctx, 10, time.Hour); err != nil || len(again) != 0 {
+		t.Errorf("Claim while leased = %d deliveries, %v; want none", len(again), err)
+	}
+	code, msg := 503, "503 Service Unavailable"
+	retryAt := time.Now().Add(-time.Second)
+	ok := 200
+	must(t, r.Webhooks.MarkFailed(ctx, &db.WebhookAttempt{DeliveryID: claimed[0].ID, StatusCode: &code, Error: &msg, DurationMS: 12}, &retryAt))
+	must(t, r.Webhooks.MarkFailed(ctx, &db.WebhookAttempt{DeliveryID: claimed[1].ID, Error: &msg}, nil))
+	must(t, r.Webhooks.MarkDelivered(ctx, &db.WebhookAttempt{DeliveryID: claimed[2].ID, StatusCode: &ok}))
+	if err := r.Webhooks.MarkDelivered(ctx, &db.WebhookAttempt{DeliveryID: claimed[1].ID, StatusCode: &ok}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("MarkDelivered(dead) error = %v, want sql.ErrNoRows", err)
+	}
+
+	retried, err := r.Webhooks.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(retried) != 1 || retried[0].ID != claimed[0].ID || retried[0].Attempts != 2 ||
+		retried[0].LastError == nil || *retried[0].LastError != msg {
+		t.Fatalf("Claim after retry = %+v, want the failed delivery on its 2nd attempt", retried)
+	}
+	must(t, r.Webhooks.MarkDelivered(ctx, &db.WebhookAttempt{DeliveryID: retried[0].ID, StatusCode: &ok, DurationMS: 3}))
+	attempts, err := r.Webhooks.ListAttempts(ctx, retried[0].ID)
+	must(t, err)
+	if len(attempts) != 2 || attempts[0].StatusCode == nil || *attempts[0].StatusCode != 503 || attempts[0].Error == nil ||
+		attempts[1].Error != nil || attempts[0].CreatedAt.After(attempts[1].CreatedAt) {
+		t.Errorf("ListAttempts = %+v, want the 503 then the success", attempts)
+	}
+	got, err := r.Webhooks.GetDelivery(ctx, retried[0].ID)
+	must(t, err)
+	if got.Status != db.DeliveryDelivered || got.DeliveredAt == nil {
+		t.Errorf("delivery after success = %+v, want delivered", got)
+	}
+
+	// dead deliveries can be filtered and redelivered
+	dead := db.DeliveryDead
+	var deadOf []*db.WebhookDelivery
+	for _, sub := range []*db.WebhookSubscription{all, placedOnly} {
+		ds, err := r.Webhooks.ListDeliveries(ctx, sub.ID, &dead, 50)
+		must(t, err)
+		deadOf = append(deadOf, ds...)
+	}
+	if len(deadOf) != 1 || deadOf[0].ID != claimed[1].ID {
+		t.Fatalf("dead deliveries = %+v, want the one marked dead", deadOf)
+	}
+	redelivered, err := r.Webhooks.Redeliver(ctx, deadOf[0].ID)
+	must(t, err)
+	if redelivered.Status != db.DeliveryPending || redelivered.Attempts != 0 {
+		t.Errorf("Redeliver = %+v, want pending with no attempts", redelivered)
+	}
+	if _, err := r.Webhooks.Redeliver(ctx, deadOf[0].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Redeliver(pending) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// updating a subscription changes what it receives from now on
+	placedOnly.EventTypes, placedOnly.Active = []string{db.WebhookOrderCancelled}, false
+	must(t, r.Webhooks.UpdateSubscription(ctx, placedOnly))
+	stored, err := r.Webhooks.GetSubscription(ctx, placedOnly.ID)
+	must(t, err)
+	if stored.Active || len(stored.EventTypes) != 1 || stored.EventTypes[0] != db.WebhookOrderCancelled {
+		t.Errorf("subscription after update = %+v", stored)
+	}
+	must(t, r.Webhooks.DeactivateSubscription(ctx, all.ID))
+	if stored, err := r.Webhooks.GetSubscription(ctx, all.ID); err != nil || stored.Active || stored.URL != all.URL || len(stored.EventTypes) != 3 {
+		t.Errorf("subscription after DeactivateSubscription = %+v, %v; want only inactive", stored, err)
+	}
+
+	// deleting a subscription deletes its deliveries and their attempts
+	must(t, r.Webhooks.DeleteSubscription(ctx, all.ID))
+	if _, err := r.Webhooks.GetDelivery(ctx, newest[0].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetDelivery after subscription delete error = %v, want sql.ErrNoRows", err)
+	}
+	if err := r.Webhooks.DeleteSubscription(ctx, all.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second DeleteSubscription error = %v, want sql.ErrNoRows", err)
+	}
+	if err := r.Webhooks.UpdateSubscription(ctx, all); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateSubscription(deleted) error = %v, want sql.ErrNoRows", err)
+	}
+	if err := r.Webhooks.DeactivateSubscription(ctx, all.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeactivateSubscription(deleted) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testWebhookRelease(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 2)
+	sub := &db.WebhookSubscription{
+		ID: uuid.New(), URL: "https://hooks.example.com", Secret: "0123456789abcdef",
+		EventTypes: db.WebhookEventTypes, Active: true,
+	}
+	must(t, r.Webhooks.CreateSubscription(ctx, sub))
+	place := func() {
+		o, items := newOrder(cust.ID, itemFor(p, 1))
+		e := &db.WebhookEvent{ID: uuid.New(), Type: db.WebhookOrderPlaced, OrderID: &o.ID, Payload: []byte(`{}`)}
+		must(t, r.Orders.CreateOrder(ctx, o, items, nil, nil, []*db.WebhookEvent{e}))
+	}
+
+	// a released delivery is claimable again without the attempt counting
+	place()
+	got, err := r.Webhooks.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(got) != 1 || got[0].LockedUntil == nil {
+		t.Fatalf("Claim = %+v, want one leased delivery", got)
+	}
+	if err := r.Webhooks.Release(ctx, got[0].ID, got[0].LockedUntil.Add(time.Second)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Release with another lease error = %v, want sql.ErrNoRows", err)
+	}
+	must(t, r.Webhooks.Release(ctx, got[0].ID, *got[0].LockedUntil))
+	if err := r.Webhooks.Release(ctx, got[0].ID, *got[0].LockedUntil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second Release error = %v, want sql.ErrNoRows", err)
+	}
+	again, err := r.Webhooks.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(again) != 1 || again[0].ID != got[0].ID || again[0].Attempts != 1 {
+		t.Fatalf("Claim after Release = %+v, want the delivery on its 1st attempt", again)
+	}
+	if attempts, err := r.Webhooks.ListAttempts(ctx, got[0].ID); err != nil || len(attempts) != 0 {
+		t.Errorf("ListAttempts after Release = %d attempts, %v; want none logged", len(attempts), err)
+	}
+	ok := 200
+	must(t, r.Webhooks.MarkDelivered(ctx, &db.WebhookAttempt{DeliveryID: again[0].ID, StatusCode: &ok}))
+
+	// once another caller has claimed the delivery, a stale release is a no-op
+	place()
+	stale, err := r.Webhooks.Claim(ctx, 10, 0)
+	must(t, err)
+	if len(stale) != 1 {
+		t.Fatalf("Claim = %d deliveries, want 1", len(stale))
+	}
+	current, err := r.Webhooks.Claim(ctx, 10, time.Hour)
+	must(t, err)
+	if len(current) != 1 || current[0].Attempts != 2 {
+		t.Fatalf("Claim after lease expiry = %+v, want the delivery on its 2nd attempt", current)
+	}
+	if err := r.Webhooks.Release(ctx, stale[0].ID, *stale[0].LockedUntil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Release of an expired lease error = %v, want sql.ErrNoRows", err)
+	}
+	del, err := r.Webhooks.GetDelivery(ctx, current[0].ID)
+	must(t, err)
+	if del.Attempts != 2 || del.LockedUntil == nil {
+		t.Errorf("after stale Release = %+v, want the delivery still leased on its 2nd attempt", del)
+	}
+}
+
+func testAdjustStock(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cat := mkCategory(t, r, "cat", nil)
+	a := mkProduct(t, r, cat.ID, "1.00", 0)
+	b := mkProduct(t, r, cat.ID, "1.00", 0)
+
+	adj, err := r.Inventory.AdjustStock(ctx, a.ID, 7, "restock", "admin-1")
+	must(t, err)
+	if adj.QuantityAfter != 7 || adj.ActorUID == nil || *adj.ActorUID != "admin-1" || adj.OrderID != nil {
+		t.Errorf("AdjustStock = %+v", adj)
+	}
+	_, err = r.Inventory.AdjustStock(ctx, a.ID, -2, "damaged", "admin-1")
+	must(t, err)
+	_, err = r.Inventory.AdjustStock(ctx, b.ID, 3, "restock", "admin-1")
+	must(t, err)
+
+	var stockErr *db.InsufficientStockError
+	if _, err := r.Inventory.AdjustStock(ctx, b.ID, -4, "damaged", "admin-1"); !errors.As(err, &stockErr) {
+		t.Errorf("AdjustStock below zero error = %v, want *db.InsufficientStockError", err)
+	}
+	if _, err := r.Inventory.AdjustStock(ctx, uuid.New(), 1, "restock", "admin-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("AdjustStock(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+
+	levels, err := r.Inventory.StockLevels(ctx, nil)
+	must(t, err)
+	if len(levels) != 2 || levels[0].ID != b.ID || levels[0].Stock != 3 || levels[1].Stock != 5 {
+		t.Errorf("StockLevels = %v, want b(3) then a(5)", productIDs(levels))
+	}
+	levels, err = r.Inventory.StockLevels(ctx, []uuid.UUID{a.ID})
+	must(t, err)
+	wantIDs(t, "StockLevels(a)", productIDs(levels), a.ID)
+
+	adjs, err := r.Inventory.ListAdjustments(ctx, a.ID, 1)
+	must(t, err)
+	if len(adjs) != 1 || adjs[0].Delta != -2 || adjs[0].Reason != "damaged" {
+		t.Errorf("ListAdjustments(limit 1) = %+v, want the latest adjustment", adjs)
+	}
+}
+
+// testStockLevelsFullProducts checks that StockLevels returns the same
+// product rows as GetByID, archival and timestamps included.
+func testStockLevelsFullProducts(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cat := mkCategory(t, r, "cat", nil)
+	live := mkProduct(t, r, cat.ID, "1.00", 2)
+	gone := mkProduct(t, r, cat.ID, "2.00", 1)
+	_, err := r.Products.Archive(ctx, gone.ID)
+	must(t, err)
+
+	for _, ids := range [][]uuid.UUID{nil, {live.ID, gone.ID}} {
+		levels, err := r.Inventory.StockLevels(ctx, ids)
+		must(t, err)
+		wantIDs(t, "StockLevels", productIDs(levels), gone.ID, live.ID)
+		for _, got := range levels {
+			want, err := r.Products.GetByID(ctx, got.ID)
+			must(t, err)
+			if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) || got.CreatedAt.IsZero() {
+				t.Errorf("StockLevels(%v) product %v created/updated = %v/%v, want %v/%v",
+					ids, got.ID, got.CreatedAt, got.UpdatedAt, want.CreatedAt, want.UpdatedAt)
+			}
+			switch {
+			case (got.ArchivedAt == nil) != (want.ArchivedAt == nil):
+				t.Errorf("StockLevels(%v) product %v archivedAt = %v, want %v", ids, got.ID, got.ArchivedAt, want.ArchivedAt)
+			case got.ArchivedAt != nil && !got.ArchivedAt.Equal(*want.ArchivedAt):
+				t.Errorf("StockLevels(%v) product %v archivedAt = %v, want %v", ids, got.ID, *got.ArchivedAt, *want.ArchivedAt)
+			}
+		}
+	}
+}
+
+func testConcurrentReservations(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	cust := mkCustomer(t, r, "buyer@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 5)
+
+	const buyers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	placed := 0
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o, items := newOrder(cust.ID, itemFor(p, 1))
+			err := r.Orders.CreateOrder(ctx, o, items, nil, nil, nil)
+			var stockErr *db.InsufficientStockError
+			switch {
+			case err == nil:
+				mu.Lock()
+				placed++
+				mu.Unlock()
+			case !errors.As(err, &stockErr):
+				t.Errorf("CreateOrder: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if placed != 5 {
+		t.Errorf("%d orders placed against stock of 5", placed)
+	}
+	wantStock(t, r, p.ID, 0)
+}
+
+// ----------------------------------------------------------------------
+// fixtures and assertions
+// ----------------------------------------------------------------------
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testKeysetPagination(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	root := mkCategory(t, r, "root", nil)
+	leaf := mkCategory(t, r, "leaf", &root.ID)
+	var prods []uuid.UUID
+	for i := 0; i < 5; i++ {
+		prods = append(prods, mkProduct(t, r, []uuid.UUID{root.ID, leaf.ID}[i%2], "1.00", 10).ID)
+	}
+
+	// walk the subtree two at a time; a product added mid-walk sorts last
+	var seen []uuid.UUID
+	page := db.Page{Limit: 2}
+	for i := 0; ; i++ {
+		got, err := r.Products.ListByCategory(ctx, root.ID, page)
+		must(t, err)
+		seen = append(seen, productIDs(got)...)
+		if i == 0 {
+			prods = append(prods, mkProduct(t, r, leaf.ID, "1.00", 10).ID)
+		}
+		if len(got) < page.Limit {
+			break
+		}
+		last := got[len(got)-1]
+		page.After = &db.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	if fmt.Sprint(seen) != fmt.Sprint(prods) {
+		t.Errorf("paged products = %v, want %v in creation order", seen, prods)
+	}
+	if n, err := r.Products.CountByCategory(ctx, root.ID); err != nil || n != len(prods) {
+		t.Errorf("CountByCategory = %d, %v, want %d", n, err, len(prods))
+	}
+	if n, err := r.Categories.CountChildren(ctx, nil); err != nil || n != 1 {
+		t.Errorf("CountChildren(nil) = %d, %v, want 1", n, err)
+	}
+	if n, err := r.Categories.CountChildren(ctx, &root.ID); err != nil || n != 1 {
+		t.Errorf("CountChildren(root) = %d, %v, want 1", n, err)
+	}
+
+	// orders page newest first
+	o1 := mkOrder(t, r)
+	cust := o1.CustomerID
+	o2, _ := newOrder(cust)
+	must(t, r.Orders.CreateOrder(ctx, o2, nil, nil, nil, nil))
+	o3, _ := newOrder(cust)
+	must(t, r.Orders.CreateOrder(ctx, o3, nil, nil, nil, nil))
+
+	first, err := r.Orders.ListByCustomer(ctx, cust, db.Page{Limit: 2})
+	must(t, err)
+	if fmt.Sprint(orderIDs(first)) != fmt.Sprint([]uuid.UUID{o3.ID, o2.ID}) {
+		t.Errorf("first page of orders = %v, want [%v %v]", orderIDs(first), o3.ID, o2.ID)
+	}
+	rest, err := r.Orders.ListByCustomer(ctx, cust, db.Page{Limit: 2, After: &db.Cursor{CreatedAt: first[1].CreatedAt, ID: first[1].ID}})
+	must(t, err)
+	if fmt.Sprint(orderIDs(rest)) != fmt.Sprint([]uuid.UUID{o1.ID}) {
+		t.Errorf("second page of orders = %v, want [%v]", orderIDs(rest), o1.ID)
+	}
+	if n, err := r.Orders.CountByCustomer(ctx, cust); err != nil || n != 3 {
+		t.Errorf("CountByCustomer = %d, %v, want 3", n, err)
+	}
+	if n, err := r.Customers.Count(ctx); err != nil || n != 1 {
+		t.Errorf("Customers.Count = %d, %v, want 1", n, err)
+	}
+}
+
+func mkCategory(t *testing.T, r Repositories, name string, parent *uuid.UUID) *db.Category {
+	t.Helper()
+	c := &db.Category{ID: uuid.New(), Name: name, ParentID: parent}
+	must(t, r.Categories.Create(context.Background(), c))
+	return c
+}
+
+func mkProduct(t *testing.T, r Repositories, categoryID uuid.UUID, price string, stock int) *db.Product {
+	t.Helper()
+	p := &db.Product{
+		ID:         uuid.New(),
+		Name:       "product " + price,
+		Price:      money.MustParse(price, money.DefaultCurrency),
+		CategoryID: categoryID,
+		Stock:      stock,
+	}
+	must(t, r.Products.Create(context.Background(), p))
+	return p
+}
+
+func mkCustomer(t *testing.T, r Repositories, email string) *db.Customer {
+	t.Helper()
+	name, _, _ := strings.Cut(email, "@")
+	c := &db.Customer{ID: uuid.New(), Name: name, Email: email}
+	must(t, r.Customers.Create(context.Background(), c))
+	return c
+}
+
+// mkOrder places a one-item order for a new customer and product.
+func mkOrder(t *testing.T, r Repositories) *db.Order {
+	t.Helper()
+	cust := mkCustomer(t, r, uuid.NewString()+"@example.com")
+	cat := mkCategory(t, r, "cat", nil)
+	p := mkProduct(t, r, cat.ID, "1.00", 1)
+	o, items := newOrder(cust.ID, itemFor(p, 1))
+	must(t, r.Orders.CreateOrder(context.Background(), o, items, nil, nil, nil))
+	return o
+}
+
+func itemFor(p *db.Product, qty int) *db.OrderItem {
+	return &db.OrderItem{ID: uuid.New(), ProductID: p.ID, Quantity: qty, UnitPrice: p.Price}
+}
+
+func newOrder(customerID uuid.UUID, items ...*db.OrderItem) (*db.Order, []*db.OrderItem) {
+	o := &db.Order{ID: uuid.New(), CustomerID: customerID, Status: "pending", Total: money.Zero(money.DefaultCurrency)}
+	for _, it := range items {
+		it.OrderID = o.ID
+		sub, _ := it.UnitPrice.Mul(it.Quantity)
+		o.Total, _ = o.Total.Add(sub)
+	}
+	return o, items
+}
+
+func wantStock(t *testing.T, r Repositories, productID uuid.UUID, want int) {
+	t.Helper()
+	p, err := r.Products.GetByID(context.Background(), productID)
+	must(t, err)
+	if p.Stock != want {
+		t.Errorf("stock of %v = %d, want %d", productID, p.Stock, want)
+	}
+}
+
+// wantIDs compares got and want as sets.
+func wantIDs(t *testing.T, what string, got []uuid.UUID, want ...uuid.UUID) {
+	t.Helper()
+	key := func(ids []uuid.UUID) string {
+		s := make([]string, len(ids))
+		for i, id := range ids {
+			s[i] = id.String()
+		}
+		sort.Strings(s)
+		return strings.Join(s, ",")
+	}
+	if key(got) != key(want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func categoryIDs(cs []*db.Category) []uuid.UUID {
+	ids := make([]uuid.UUID, len(cs))
+	for i, c := range cs {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func treeIDs(ns []*db.CategoryTreeNode) []uuid.UUID {
+	ids := make([]uuid.UUID, len(ns))
+	for i, n := range ns {
+		ids[i] = n.ID
+	}
+	return ids
+}
+
+func productIDs(ps []*db.Product) []uuid.UUID {
+	ids := make([]uuid.UUID, len(ps))
+	for i, p := range ps {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func customerIDs(cs []*db.Customer) []uuid.UUID {
+	ids := make([]uuid.UUID, len(cs))
+	for i, c := range cs {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func orderIDs(os []*db.Order) []uuid.UUID {
+	ids := make([]uuid.UUID, len(os))
+	for i, o := range os {
+		ids[i] = o.ID
+	}
+	return ids
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrStatusConflict is returned when an order's status changed between
+// reading it and writing the transition.
+var ErrStatusConflict = errors.New("order status was changed concurrently")
+
+// ErrCancelViaUpdateStatus is returned when UpdateStatus is asked to move an
+// order to 'cancelled'. Cancellation goes through CancelOrder, which also
+// releases stock and stores the reason.
+var ErrCancelViaUpdateStatus = errors.New("orders must be cancelled with cancelOrder")
+
+// ErrProductInUse is returned when deleting a product that order items
+// still reference.
+var ErrProductInUse = errors.New("product is referenced by orders")
+
+// ErrCategoryCycle is returned when a category would be moved under itself
+// or one of its descendants.
+var ErrCategoryCycle = errors.New("category cannot be moved under itself or its descendants")
+
+// ErrCategoryInUse is returned when deleting a category that still has
+// subcategories or products the chosen strategy cannot handle.
+var ErrCategoryInUse = errors.New("category still has subcategories or products")
+
+// InsufficientStockError is returned when stock cannot cover a reservation
+// or adjustment. ProductIDs lists every product that is short.
+type InsufficientStockError struct {
+	ProductIDs []uuid.UUID
+}
+
+func (e *InsufficientStockError) Error() string {
+	ids := make([]string, len(e.ProductIDs))
+	for i, id := range e.ProductIDs {
+		ids[i] = id.String()
+	}
+	return fmt.Sprintf("insufficient stock for products: %s", strings.Join(ids, ", "))
+}
+
+// IdempotencyReplayError is returned by OrderRepository.CreateOrder when the
+// idempotency key is already held by an unexpired earlier order. Callers
+// compare RequestHash to tell a retry from a conflicting reuse of the key.
+type IdempotencyReplayError struct {
+	OrderID     uuid.UUID
+	RequestHash string
+}
+
+func (e *IdempotencyReplayError) Error() string {
+	return fmt.Sprintf("idempotency key already used by order %s", e.OrderID)
+}
@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type customerRepo struct {
+	s *Store
+}
+
+// NewCustomerRepository returns a db.CustomerRepository backed by s.
+func NewCustomerRepository(s *Store) db.CustomerRepository {
+	return &customerRepo{s: s}
+}
+
+// Create inserts a new customer and fills in its timestamps.
+func (r *customerRepo) Create(ctx context.Context, c *db.Customer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, dup := r.s.customers[c.ID]; dup {
+		return uniqueViolation("customer %s already exists", c.ID)
+	}
+	if err := r.s.checkCustomerUnique(c.ID, c.Email, c.FirebaseUID); err != nil {
+		return err
+	}
+	if c.Locale == "" {
+		c.Locale = "en"
+	}
+	c.CreatedAt = r.s.now()
+	c.UpdatedAt = c.CreatedAt
+	r.s.customers[c.ID] = copyCustomer(*c)
+	return nil
+}
+
+// GetByID fetches one customer by its UUID.
+func (r *customerRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Customer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	c, ok := r.s.customers[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	out := copyCustomer(c)
+	return &out, nil
+}
+
+// GetByEmail fetches one customer by email, ignoring case.
+func (r *customerRepo) GetByEmail(ctx context.Context, email string) (*db.Customer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, c := range r.s.customers {
+		if strings.EqualFold(c.Email, email) {
+			out := copyCustomer(c)
+			return &out, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetByFirebaseUID fetches the customer linked to a Firebase identity.
+func (r *customerRepo) GetByFirebaseUID(ctx context.Context, uid string) (*db.Customer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, c := range r.s.customers {
+		if c.FirebaseUID != nil && *c.FirebaseUID == uid {
+			out := copyCustomer(c)
+			return &out, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// List returns one page of customers ordered by creation time.
+func (r *customerRepo) List(ctx context.Context, page db.Page) ([]*db.Customer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	all := make([]*db.Customer, 0, len(r.s.customers))
+	for _, c := range r.s.customers {
+		cp := copyCustomer(c)
+		all = append(all, &cp)
+	}
+	return keysetPage(all, customerKey, page, false), nil
+}
+
+// Count returns the number of customers.
+func (r *customerRepo) Count(ctx context.Context) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return len(r.s.customers), nil
+}
+
+// Update writes name, email, phone and locale and bumps updated_at.
+// It returns an error wrapping sql.ErrNoRows if the customer does not exist.
+func (r *customerRepo) Update(ctx context.Context, c *db.Customer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cur, ok := r.s.customers[c.ID]
+	if !ok {
+		return fmt.Errorf("update customer: %w", sql.ErrNoRows)
+	}
+	if err := r.s.checkCustomerUnique(c.ID, c.Email, nil); err != nil {
+		return err
+	}
+	cur.Name, cur.Email, cur.Locale = c.Name, c.Email, c.Locale
+	cur.Phone = nil
+	if c.Phone != nil {
+		phone := *c.Phone
+		cur.Phone = &phone
+	}
+	cur.UpdatedAt = r.s.now()
+	r.s.customers[c.ID] = cur
+	c.UpdatedAt = cur.UpdatedAt
+	return nil
+}
+
+// LinkFirebaseUID sets firebase_uid on a customer that has none yet.
+// It returns an error wrapping sql.ErrNoRows if the customer does not exist
+// or is already linked.
+func (r *customerRepo) LinkFirebaseUID(ctx context.Context, id uuid.UUID, uid string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cur, ok := r.s.customers[id]
+	if !ok || cur.FirebaseUID != nil {
+		return fmt.Errorf("link firebase uid: %w", sql.ErrNoRows)
+	}
+	if err := r.s.checkCustomerUnique(id, "", &uid); err != nil {
+		return err
+	}
+	cur.FirebaseUID = &uid
+	cur.UpdatedAt = r.s.now()
+	r.s.customers[id] = cur
+	return nil
+}
+
+// checkCustomerUnique enforces the unique email and firebase_uid columns for
+// a write to customer id. Empty email and nil uid are not checked. Callers
+// must hold s.mu.
+func (s *Store) checkCustomerUnique(id uuid.UUID, email string, uid *string) error {
+	for _, c := range s.customers {
+		if c.ID == id {
+			continue
+		}
+		if email != "" && c.Email == email {
+			return uniqueViolation("customer email %s already exists", email)
+		}
+		if uid != nil && c.FirebaseUID != nil && *c.FirebaseUID == *uid {
+			return uniqueViolation("firebase uid %s already linked", *uid)
+		}
+	}
+	return nil
+}
+
+func customerKey(c *db.Customer) db.Cursor {
+	return db.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+func copyCustomer(c db.Customer) db.Customer {
+	if c.FirebaseUID != nil {
+		uid := *c.FirebaseUID
+		c.FirebaseUID = &uid
+	}
+	if c.Phone != nil {
+		phone := *c.Phone
+		c.Phone = &phone
+	}
+	return c
+}
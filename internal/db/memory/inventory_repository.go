@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type inventoryRepo struct {
+	s *Store
+}
+
+// NewInventoryRepository returns a db.InventoryRepository backed by s.
+func NewInventoryRepository(s *Store) db.InventoryRepository {
+	return &inventoryRepo{s: s}
+}
+
+// AdjustStock applies delta to a product's stock and records the audit row.
+func (r *inventoryRepo) AdjustStock(ctx context.Context, productID uuid.UUID, delta int, reason, actorUID string) (*db.StockAdjustment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.products[productID]
+	if !ok {
+		return nil, fmt.Errorf("select product stock: %w", sql.ErrNoRows)
+	}
+	if p.Stock+delta < 0 {
+		return nil, &db.InsufficientStockError{ProductIDs: []uuid.UUID{productID}}
+	}
+	adj := r.s.moveStock(productID, delta, reason, nil, &actorUID)
+	return &adj, nil
+}
+
+// StockLevels returns products with their current stock, lowest first.
+func (r *inventoryRepo) StockLevels(ctx context.Context, productIDs []uuid.UUID) ([]*db.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	want := make(map[uuid.UUID]bool, len(productIDs))
+	for _, id := range productIDs {
+		want[id] = true
+	}
+	var out []*db.Product
+	for _, p := range r.s.products {
+		if len(want) == 0 || want[p.ID] {
+			cp := copyProduct(p)
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Stock != out[j].Stock {
+			return out[i].Stock < out[j].Stock
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// ListAdjustments returns up to limit adjustments of a product, newest first.
+func (r *inventoryRepo) ListAdjustments(ctx context.Context, productID uuid.UUID, limit int) ([]*db.StockAdjustment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.StockAdjustment
+	for i := len(r.s.adjustments) - 1; i >= 0 && len(out) < limit; i-- {
+		if a := r.s.adjustments[i]; a.ProductID == productID {
+			out = append(out, &a)
+		}
+	}
+	return out, nil
+}
+
+// moveStock adds delta to a product's stock and appends the audit row,
+// which it returns. The caller has already checked that the product exists
+// and the result is not negative. Callers must hold s.mu for writing.
+func (s *Store) moveStock(productID uuid.UUID, delta int, reason string, orderID *uuid.UUID, actorUID *string) db.StockAdjustment {
+	now := s.now()
+	p := s.products[productID]
+	p.Stock += delta
+	p.UpdatedAt = now
+	s.products[productID] = p
+
+	if orderID != nil {
+		id := *orderID
+		orderID = &id
+	}
+	if actorUID != nil {
+		uid := *actorUID
+		actorUID = &uid
+	}
+	adj := db.StockAdjustment{
+		ID:            uuid.New(),
+		ProductID:     productID,
+		Delta:         delta,
+		QuantityAfter: p.Stock,
+		Reason:        reason,
+		OrderID:       orderID,
+		ActorUID:      actorUID,
+		CreatedAt:     now,
+	}
+	s.adjustments = append(s.adjustments, adj)
+	return adj
+}
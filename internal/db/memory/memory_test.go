@@ -0,0 +1,9 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/dbtest"
+)
+
+func TestMemory(t *testing.T) { dbtest.Run(t, dbtest.Memory) }
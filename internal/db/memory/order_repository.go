@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type orderRepo struct {
+	s *Store
+}
+
+// NewOrderRepository returns a db.OrderRepository backed by s.
+func NewOrderRepository(s *Store) db.OrderRepository {
+	return &orderRepo{s: s}
+}
+
+// CreateOrder stores an Order and its OrderItems, reserves their stock,
+// enqueues outbox and fans out events. Everything is checked before anything is written, so a
+// failure leaves the store untouched.
+func (r *orderRepo) CreateOrder(ctx context.Context, o *db.Order, items []*db.OrderItem, key *db.IdempotencyKey, outbox []*db.OutboxMessage, events []*db.WebhookEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, dup := r.s.orders[o.ID]; dup {
+		return uniqueViolation("order %s already exists", o.ID)
+	}
+	if _, ok := r.s.customers[o.CustomerID]; !ok {
+		return foreignKeyViolation("customer %s does not exist", o.CustomerID)
+	}
+	if o.Total.IsNegative() {
+		return checkViolation("order total must not be negative")
+	}
+
+	now := r.s.now()
+
+	// claim the idempotency key before reserving anything
+	var keyID idempotencyID
+	if key != nil {
+		keyID = idempotencyID{customerID: key.CustomerID, key: key.Key}
+		if held, ok := r.s.idempotency[keyID]; ok {
+			if held.ExpiresAt.After(now) {
+				return &db.IdempotencyReplayError{OrderID: held.OrderID, RequestHash: held.RequestHash}
+			}
+			delete(r.s.idempotency, keyID)
+		}
+	}
+
+	qty := make(map[uuid.UUID]int)
+	var ids []uuid.UUID
+	for _, it := range items {
+		if _, ok := r.s.products[it.ProductID]; !ok {
+			return fmt.Errorf("insert order_item %s: %w", it.ID, foreignKeyViolation("product %s does not exist", it.ProductID))
+		}
+		if it.Quantity <= 0 || it.UnitPrice.IsNegative() {
+			return fmt.Errorf("insert order_item %s: %w", it.ID, checkViolation("quantity must be positive and unit price not negative"))
+		}
+		if _, seen := qty[it.ProductID]; !seen {
+			ids = append(ids, it.ProductID)
+		}
+		qty[it.ProductID] += it.Quantity
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	short := &db.InsufficientStockError{}
+	for _, pid := range ids {
+		if r.s.products[pid].Stock < qty[pid] {
+			short.ProductIDs = append(short.ProductIDs, pid)
+		}
+	}
+	if len(short.ProductIDs) > 0 {
+		return short
+	}
+	if err := r.s.checkOutbox(outbox, o.ID); err != nil {
+		return err
+	}
+	if err := r.s.checkWebhookEvents(events, o.ID); err != nil {
+		return err
+	}
+
+	// all checks passed; write
+	o.CreatedAt, o.UpdatedAt = now, now
+	r.s.orders[o.ID] = copyOrder(*o)
+
+	stored := make([]db.OrderItem, 0, len(items))
+	for _, it := range items {
+		it.OrderID = o.ID
+		it.CreatedAt, it.UpdatedAt = now, now
+		stored = append(stored, *it)
+	}
+	r.s.orderItems[o.ID] = stored
+
+	for _, pid := range ids {
+		r.s.moveStock(pid, -qty[pid], db.StockReasonOrderPlaced, &o.ID, nil)
+	}
+
+	if key != nil {
+		key.OrderID = o.ID
+		key.CreatedAt = now
+		r.s.idempotency[keyID] = *key
+	}
+	r.s.enqueue(outbox)
+	r.s.fanOut(events)
+	return nil
+}
+
+// GetIdempotencyKey fetches a live idempotency key.
+func (r *orderRepo) GetIdempotencyKey(ctx context.Context, customerID uuid.UUID, key string) (*db.IdempotencyKey, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	held, ok := r.s.idempotency[idempotencyID{customerID: customerID, key: key}]
+	if !ok || !held.ExpiresAt.After(r.s.now()) {
+		return nil, fmt.Errorf("select idempotency key: %w", sql.ErrNoRows)
+	}
+	return &held, nil
+}
+
+// GetByID fetches one Order together with its items.
+func (r *orderRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Order, []*db.OrderItem, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	o, ok := r.s.orders[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("select order: %w", sql.ErrNoRows)
+	}
+	out := copyOrder(o)
+
+	var items []*db.OrderItem
+	for _, it := range r.s.orderItems[id] {
+		it := it
+		items = append(items, &it)
+	}
+	return &out, items, nil
+}
+
+// ListByCustomer returns one page of a customer's Orders, newest first.
+func (r *orderRepo) ListByCustomer(ctx context.Context, customerID uuid.UUID, page db.Page) ([]*db.Order, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.Order
+	for _, o := range r.s.orders {
+		if o.CustomerID == customerID {
+			cp := copyOrder(o)
+			out = append(out, &cp)
+		}
+	}
+	return keysetPage(out, orderKey, page, true), nil
+}
+
+// CountByCustomer returns the number of Orders of a customer.
+func (r *orderRepo) CountByCustomer(ctx context.Context, customerID uuid.UUID) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	n := 0
+	for _, o := range r.s.orders {
+		if o.CustomerID == customerID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// UpdateStatus changes an order's status, appends a history row and fans
+// out events. The update only applies while the order is still in from.
+func (r *orderRepo) UpdateStatus(ctx context.Context, id uuid.UUID, from, to, actorUID string, events []*db.WebhookEvent) error {
+	if to == "cancelled" {
+		return db.ErrCancelViaUpdateStatus
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkWebhookEvents(events, uuid.Nil); err != nil {
+		return err
+	}
+	o, err := r.s.transition(id, from, to, actorUID)
+	if err != nil {
+		return err
+	}
+	r.s.orders[id] = o
+	r.s.fanOut(events)
+	return nil
+}
+
+// CancelOrder marks an order cancelled with its reason, records the
+// transition, releases its stock, enqueues outbox and fans out events, under
+// the same compare-and-set rule as UpdateStatus.
+func (r *orderRepo) CancelOrder(ctx context.Context, id uuid.UUID, from, actorUID, reason string, note *string, outbox []*db.OutboxMessage, events []*db.WebhookEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkOutbox(outbox, uuid.Nil); err != nil {
+		return err
+	}
+	if err := r.s.checkWebhookEvents(events, uuid.Nil); err != nil {
+		return err
+	}
+	o, err := r.s.transition(id, from, "cancelled", actorUID)
+	if err != nil {
+		return err
+	}
+	if note != nil {
+		n := *note
+		note = &n
+	}
+	o.CancelReason = &reason
+	o.CancelNote = note
+	o.CancelledAt = &o.UpdatedAt
+	r.s.orders[id] = o
+
+	qty := make(map[uuid.UUID]int)
+	var ids []uuid.UUID
+	for _, it := range r.s.orderItems[id] {
+		if _, seen := qty[it.ProductID]; !seen {
+			ids = append(ids, it.ProductID)
+		}
+		qty[it.ProductID] += it.Quantity
+	}
+	for _, pid := range ids {
+		r.s.moveStock(pid, qty[pid], db.StockReasonOrderCancelled, &id, nil)
+	}
+	r.s.enqueue(outbox)
+	r.s.fanOut(events)
+	return nil
+}
+
+// transition checks that order id is in from, appends the history row and
+// returns the order moved to to, for the caller to amend and store. It
+// returns db.ErrStatusConflict when the order is missing or not in from.
+// Callers must hold s.mu for writing.
+func (s *Store) transition(id uuid.UUID, from, to, actorUID string) (db.Order, error) {
+	o, ok := s.orders[id]
+	if !ok || o.Status != from {
+		return db.Order{}, db.ErrStatusConflict
+	}
+	o = copyOrder(o)
+	o.Status = to
+	o.UpdatedAt = s.now()
+
+	s.statusHistory[id] = append(s.statusHistory[id], db.OrderStatusChange{
+		ID:         uuid.New(),
+		OrderID:    id,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorUID:   actorUID,
+		CreatedAt:  o.UpdatedAt,
+	})
+	return o, nil
+}
+
+// ListStatusHistory returns an order's transitions, oldest first.
+func (r *orderRepo) ListStatusHistory(ctx context.Context, orderID uuid.UUID) ([]*db.OrderStatusChange, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.OrderStatusChange
+	for _, ch := range r.s.statusHistory[orderID] {
+		ch := ch
+		out = append(out, &ch)
+	}
+	return out, nil
+}
+
+func orderKey(o *db.Order) db.Cursor {
+	return db.Cursor{CreatedAt: o.CreatedAt, ID: o.ID}
+}
+
+func copyOrder(o db.Order) db.Order {
+	if o.CancelReason != nil {
+		v := *o.CancelReason
+		o.CancelReason = &v
+	}
+	if o.CancelNote != nil {
+		v := *o.CancelNote
+		o.CancelNote = &v
+	}
+	if o.CancelledAt != nil {
+		v := *o.CancelledAt
+		o.CancelledAt = &v
+	}
+	return o
+}
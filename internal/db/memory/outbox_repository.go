@@ -0,0 +1,297 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type outboxRepo struct {
+	s *Store
+}
+
+// NewOutboxRepository returns a db.OutboxRepository backed by s.
+func NewOutboxRepository(s *Store) db.OutboxRepository {
+	return &outboxRepo{s: s}
+}
+
+// Enqueue stores msgs, all or none.
+func (r *outboxRepo) Enqueue(ctx context.Context, msgs ...*db.OutboxMessage) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkOutbox(msgs, uuid.Nil); err != nil {
+		return err
+	}
+	r.s.enqueue(msgs)
+	return nil
+}
+
+// checkOutbox applies the constraints of notification_outbox to msgs.
+// newOrderID names an order being stored in the same write, which msgs may
+// reference before it exists. Callers must hold s.mu.
+func (s *Store) checkOutbox(msgs []*db.OutboxMessage, newOrderID uuid.UUID) error {
+	for _, m := range msgs {
+		if _, dup := s.outbox[m.ID]; dup {
+			return uniqueViolation("notification %s already exists", m.ID)
+		}
+		if m.Channel != db.ChannelEmail && m.Channel != db.ChannelSMS {
+			return fmt.Errorf("insert notification_outbox: %w", checkViolation("unknown channel %q", m.Channel))
+		}
+		if m.OrderID != nil && *m.OrderID != newOrderID {
+			if _, ok := s.orders[*m.OrderID]; !ok {
+				return fmt.Errorf("insert notification_outbox: %w", foreignKeyViolation("order %s does not exist", *m.OrderID))
+			}
+		}
+		if m.CustomerID != nil {
+			if _, ok := s.customers[*m.CustomerID]; !ok {
+				return fmt.Errorf("insert notification_outbox: %w", foreignKeyViolation("customer %s does not exist", *m.CustomerID))
+			}
+		}
+	}
+	return nil
+}
+
+// enqueue stores msgs that passed checkOutbox and fills in their stored
+// state. Callers must hold s.mu for writing.
+func (s *Store) enqueue(msgs []*db.OutboxMessage) {
+	now := s.now()
+	for _, m := range msgs {
+		if m.ID == uuid.Nil {
+			m.ID = uuid.New()
+		}
+		m.Status, m.Attempts = db.OutboxPending, 0
+		m.LastError, m.LockedUntil, m.SentAt, m.SuppressedReason = nil, nil, nil, nil
+		if m.NextAttemptAt.IsZero() {
+			m.NextAttemptAt = now
+		}
+		m.CreatedAt, m.UpdatedAt = now, now
+		s.outbox[m.ID] = copyOutboxMessage(*m)
+	}
+}
+
+// Claim leases due messages that no other claim holds.
+func (r *outboxRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]*db.OutboxMessage, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	var due []db.OutboxMessage
+	for _, m := range r.s.outbox {
+		if m.Status == db.OutboxPending && !m.NextAttemptAt.After(now) &&
+			(m.LockedUntil == nil || !m.LockedUntil.After(now)) {
+			due = append(due, m)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID.String() < due[j].ID.String()
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	until := now.Add(lease)
+	out := make([]*db.OutboxMessage, len(due))
+	for i, m := range due {
+		m.Attempts++
+		m.LockedUntil = &until
+		m.UpdatedAt = now
+		r.s.outbox[m.ID] = copyOutboxMessage(m)
+		cp := copyOutboxMessage(m)
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+// MarkSent records a delivery and releases the lease.
+func (r *outboxRepo) MarkSent(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.outbox[id]
+	if !ok || m.Status != db.OutboxPending {
+		return fmt.Errorf("mark notification sent: %w", sql.ErrNoRows)
+	}
+	now := r.s.now()
+	m.Status = db.OutboxSent
+	m.SentAt, m.LockedUntil = &now, nil
+	m.UpdatedAt = now
+	r.s.outbox[id] = m
+	return nil
+}
+
+// MarkFailed stores errMsg and either schedules the next attempt or moves
+// the message to dead.
+func (r *outboxRepo) MarkFailed(ctx context.Context, id uuid.UUID, errMsg string, retryAt *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.outbox[id]
+	if !ok || m.Status != db.OutboxPending {
+		return fmt.Errorf("mark notification failed: %w", sql.ErrNoRows)
+	}
+	if retryAt == nil {
+		m.Status = db.OutboxDead
+	} else {
+		m.NextAttemptAt = *retryAt
+	}
+	m.LastError, m.LockedUntil = &errMsg, nil
+	m.UpdatedAt = r.s.now()
+	r.s.outbox[id] = m
+	return nil
+}
+
+// MarkSuppressed moves the message to suppressed with reason and releases
+// the lease.
+func (r *outboxRepo) MarkSuppressed(ctx context.Context, id uuid.UUID, reason string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.outbox[id]
+	if !ok || m.Status != db.OutboxPending {
+		return fmt.Errorf("mark notification suppressed: %w", sql.ErrNoRows)
+	}
+	m.Status = db.OutboxSuppressed
+	m.SuppressedReason, m.LockedUntil = &reason, nil
+	m.UpdatedAt = r.s.now()
+	r.s.outbox[id] = m
+	return nil
+}
+
+// Postpone takes back the attempt Claim counted and makes the message due
+// at until.
+func (r *outboxRepo) Postpone(ctx context.Context, id uuid.UUID, until time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.outbox[id]
+	if !ok || m.Status != db.OutboxPending {
+		return fmt.Errorf("postpone notification: %w", sql.ErrNoRows)
+	}
+	if m.Attempts > 0 {
+		m.Attempts--
+	}
+	m.NextAttemptAt, m.LockedUntil = until, nil
+	m.UpdatedAt = r.s.now()
+	r.s.outbox[id] = m
+	return nil
+}
+
+// Release takes back the attempt Claim counted and ends the lease, unless
+// the message has been claimed again since.
+func (r *outboxRepo) Release(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.outbox[id]
+	if !ok || m.Status != db.OutboxPending || m.LockedUntil == nil || !m.LockedUntil.Equal(lockedUntil) {
+		return fmt.Errorf("release notification: %w", sql.ErrNoRows)
+	}
+	if m.Attempts > 0 {
+		m.Attempts--
+	}
+	m.LockedUntil = nil
+	m.UpdatedAt = r.s.now()
+	r.s.outbox[id] = m
+	return nil
+}
+
+// Requeue resets a dead message so it is claimed again right away.
+func (r *outboxRepo) Requeue(ctx context.Context, id uuid.UUID) (*db.OutboxMessage, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.outbox[id]
+	if !ok || m.Status != db.OutboxDead {
+		return nil, fmt.Errorf("requeue notification: %w", sql.ErrNoRows)
+	}
+	now := r.s.now()
+	m.Status, m.Attempts = db.OutboxPending, 0
+	m.NextAttemptAt, m.LockedUntil = now, nil
+	m.UpdatedAt = now
+	r.s.outbox[id] = m
+	out := copyOutboxMessage(m)
+	return &out, nil
+}
+
+// ListByOrder returns the messages of an order, oldest first.
+func (r *outboxRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]*db.OutboxMessage, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.OutboxMessage
+	for _, m := range r.s.outbox {
+		if m.OrderID != nil && *m.OrderID == orderID {
+			cp := copyOutboxMessage(m)
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out, nil
+}
+
+// ListByStatus returns messages in status, most recently updated first.
+func (r *outboxRepo) ListByStatus(ctx context.Context, status string, limit int) ([]*db.OutboxMessage, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.OutboxMessage
+	for _, m := range r.s.outbox {
+		if m.Status == status {
+			cp := copyOutboxMessage(m)
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].UpdatedAt.Equal(out[j].UpdatedAt) {
+			return out[i].UpdatedAt.After(out[j].UpdatedAt)
+		}
+		return out[i].ID.String() > out[j].ID.String()
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func copyOutboxMessage(m db.OutboxMessage) db.OutboxMessage {
+	if m.OrderID != nil {
+		v := *m.OrderID
+		m.OrderID = &v
+	}
+	if m.CustomerID != nil {
+		v := *m.CustomerID
+		m.CustomerID = &v
+	}
+	if m.LastError != nil {
+		v := *m.LastError
+		m.LastError = &v
+	}
+	if m.LockedUntil != nil {
+		v := *m.LockedUntil
+		m.LockedUntil = &v
+	}
+	if m.SentAt != nil {
+		v := *m.SentAt
+		m.SentAt = &v
+	}
+	if m.SuppressedReason != nil {
+		v := *m.SuppressedReason
+		m.SuppressedReason = &v
+	}
+	return m
+}
@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// keysetPage sorts rows by (created_at, id), newest first when desc, and
+// returns up to page.Limit of the rows that sort after page.After, like the
+// keyset queries in internal/db/postgres.
+func keysetPage[T any](rows []T, key func(T) db.Cursor, page db.Page, desc bool) []T {
+	before := func(a, b db.Cursor) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return bytes.Compare(a.ID[:], b.ID[:]) < 0
+	}
+	sorted := func(a, b db.Cursor) bool {
+		if desc {
+			return before(b, a)
+		}
+		return before(a, b)
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return sorted(key(rows[i]), key(rows[j])) })
+	if page.After != nil {
+		start := sort.Search(len(rows), func(i int) bool { return sorted(*page.After, key(rows[i])) })
+		rows = rows[start:]
+	}
+	if page.Limit < len(rows) {
+		rows = rows[:page.Limit]
+	}
+	return rows
+}
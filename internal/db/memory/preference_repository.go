@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type preferenceRepo struct {
+	s *Store
+}
+
+// NewNotificationPreferenceRepository returns a
+// db.NotificationPreferenceRepository backed by s.
+func NewNotificationPreferenceRepository(s *Store) db.NotificationPreferenceRepository {
+	return &preferenceRepo{s: s}
+}
+
+// Get fetches the preferences of a customer, falling back to the defaults.
+func (r *preferenceRepo) Get(ctx context.Context, customerID uuid.UUID) (*db.NotificationPreferences, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if _, ok := r.s.customers[customerID]; !ok {
+		return nil, fmt.Errorf("select notification_preferences: %w", sql.ErrNoRows)
+	}
+	p, ok := r.s.preferences[customerID]
+	if !ok {
+		return db.DefaultNotificationPreferences(customerID), nil
+	}
+	out := copyPreferences(p)
+	return &out, nil
+}
+
+// Save upserts p and fills in UpdatedAt.
+func (r *preferenceRepo) Save(ctx context.Context, p *db.NotificationPreferences) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.customers[p.CustomerID]; !ok {
+		return fmt.Errorf("upsert notification_preferences: %w", foreignKeyViolation("customer %s does not exist", p.CustomerID))
+	}
+	for _, ch := range p.Channels {
+		if ch != db.ChannelEmail && ch != db.ChannelSMS {
+			return fmt.Errorf("upsert notification_preferences: %w", checkViolation("unknown channel %q", ch))
+		}
+	}
+	if (p.QuietStart == nil) != (p.QuietEnd == nil) {
+		return fmt.Errorf("upsert notification_preferences: %w", checkViolation("quiet hours need a start and an end"))
+	}
+	if p.QuietStart != nil {
+		start, end := *p.QuietStart, *p.QuietEnd
+		if start < 0 || start > 1439 || end < 0 || end > 1439 || start == end {
+			return fmt.Errorf("upsert notification_preferences: %w", checkViolation("invalid quiet hours %d-%d", start, end))
+		}
+	}
+	now := r.s.now()
+	p.UpdatedAt = &now
+	r.s.preferences[p.CustomerID] = copyPreferences(*p)
+	return nil
+}
+
+func copyPreferences(p db.NotificationPreferences) db.NotificationPreferences {
+	p.Channels = append([]string{}, p.Channels...)
+	p.OptedOutEvents = append([]string{}, p.OptedOutEvents...)
+	if p.QuietStart != nil {
+		v := *p.QuietStart
+		p.QuietStart = &v
+	}
+	if p.QuietEnd != nil {
+		v := *p.QuietEnd
+		p.QuietEnd = &v
+	}
+	if p.UpdatedAt != nil {
+		v := *p.UpdatedAt
+		p.UpdatedAt = &v
+	}
+	return p
+}
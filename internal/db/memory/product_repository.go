@@ -0,0 +1,311 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+)
+
+type productRepo struct {
+	s *Store
+}
+
+// NewProductRepository returns a db.ProductRepository backed by s.
+func NewProductRepository(s *Store) db.ProductRepository {
+	return &productRepo{s: s}
+}
+
+// Create inserts a new product.
+func (r *productRepo) Create(ctx context.Context, p *db.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, dup := r.s.products[p.ID]; dup {
+		return uniqueViolation("product %s already exists", p.ID)
+	}
+	if _, ok := r.s.categories[p.CategoryID]; !ok {
+		return foreignKeyViolation("category %s does not exist", p.CategoryID)
+	}
+	if p.Price.IsNegative() || p.Stock < 0 {
+		return checkViolation("product price and stock must not be negative")
+	}
+	p.CreatedAt = r.s.now()
+	p.UpdatedAt = p.CreatedAt
+	r.s.products[p.ID] = copyProduct(*p)
+	return nil
+}
+
+// GetByID fetches one product.
+func (r *productRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	p, ok := r.s.products[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	out := copyProduct(p)
+	return &out, nil
+}
+
+// GetByIDs fetches the products with the given IDs, skipping unknown ones.
+func (r *productRepo) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*db.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.Product
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if p, ok := r.s.products[id]; ok && !seen[id] {
+			seen[id] = true
+			cp := copyProduct(p)
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+// Update writes the editable fields of a product and bumps UpdatedAt.
+// It returns an error wrapping sql.ErrNoRows if the product does not exist.
+func (r *productRepo) Update(ctx context.Context, p *db.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cur, ok := r.s.products[p.ID]
+	if !ok {
+		return fmt.Errorf("update product: %w", sql.ErrNoRows)
+	}
+	if _, ok := r.s.categories[p.CategoryID]; !ok {
+		return foreignKeyViolation("category %s does not exist", p.CategoryID)
+	}
+	if p.Price.IsNegative() {
+		return checkViolation("product price must not be negative")
+	}
+	cur.Name, cur.Price, cur.CategoryID = p.Name, p.Price, p.CategoryID
+	cur.Description = nil
+	if p.Description != nil {
+		d := *p.Description
+		cur.Description = &d
+	}
+	cur.UpdatedAt = r.s.now()
+	r.s.products[p.ID] = cur
+	p.UpdatedAt = cur.UpdatedAt
+	return nil
+}
+
+// Archive sets ArchivedAt unless the product is already archived.
+// It returns an error wrapping sql.ErrNoRows if the product does not exist.
+func (r *productRepo) Archive(ctx context.Context, id uuid.UUID) (*db.Product, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cur, ok := r.s.products[id]
+	if !ok {
+		return nil, fmt.Errorf("archive product: %w", sql.ErrNoRows)
+	}
+	cur.UpdatedAt = r.s.now()
+	if cur.ArchivedAt == nil {
+		at := cur.UpdatedAt
+		cur.ArchivedAt = &at
+	}
+	r.s.products[id] = cur
+	out := copyProduct(cur)
+	return &out, nil
+}
+
+// Delete removes a product and its stock adjustments. It returns
+// db.ErrProductInUse while an order item references the product.
+func (r *productRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.products[id]; !ok {
+		return fmt.Errorf("delete product: %w", sql.ErrNoRows)
+	}
+	for _, items := range r.s.orderItems {
+		for _, it := range items {
+			if it.ProductID == id {
+				return db.ErrProductInUse
+			}
+		}
+	}
+	delete(r.s.products, id)
+	kept := r.s.adjustments[:0]
+	for _, a := range r.s.adjustments {
+		if a.ProductID != id {
+			kept = append(kept, a)
+		}
+	}
+	r.s.adjustments = kept
+	return nil
+}
+
+// ListByCategory returns one page of the unarchived products in a category
+// subtree, oldest first.
+func (r *productRepo) ListByCategory(ctx context.Context, categoryID uuid.UUID, page db.Page) ([]*db.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return keysetPage(r.s.productsIn(r.s.subtree(categoryID)), productKey, page, false), nil
+}
+
+// CountByCategory counts the unarchived products in the same subtree.
+func (r *productRepo) CountByCategory(ctx context.Context, categoryID uuid.UUID) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return len(r.s.productsIn(r.s.subtree(categoryID))), nil
+}
+
+// CountByCategories does CountByCategory for each known category.
+func (r *productRepo) CountByCategories(ctx context.Context, categoryIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	out := make(map[uuid.UUID]int, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if _, ok := r.s.categories[id]; ok {
+			out[id] = len(r.s.productsIn(r.s.subtree(id)))
+		}
+	}
+	return out, nil
+}
+
+// AveragePriceByCategory computes the average price over the same subtree,
+// rounded half away from zero to whole cents like Postgres ROUND(…, 2).
+func (r *productRepo) AveragePriceByCategory(ctx context.Context, categoryID uuid.UUID) (money.Money, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	products := r.s.productsIn(r.s.subtree(categoryID))
+	if len(products) == 0 {
+		return money.Zero(money.DefaultCurrency), nil
+	}
+	var sum int64
+	for _, p := range products {
+		sum += p.Price.Amount
+	}
+	return money.New(roundDiv(sum, int64(len(products))), money.DefaultCurrency), nil
+}
+
+// PriceStatsByCategory computes the same figures as the Postgres queries in
+// exact integer arithmetic, rounding half away from zero like ROUND(…, 2).
+func (r *productRepo) PriceStatsByCategory(ctx context.Context, categoryID uuid.UUID, subtree bool, buckets int) (*db.PriceStats, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if _, ok := r.s.categories[categoryID]; !ok {
+		return nil, fmt.Errorf("price stats: %w", sql.ErrNoRows)
+	}
+	cats := map[uuid.UUID]bool{categoryID: true}
+	if subtree {
+		cats = r.s.subtree(categoryID)
+	}
+	products := r.s.productsIn(cats)
+	stats := &db.PriceStats{Count: len(products)}
+	if len(products) == 0 {
+		return stats, nil
+	}
+
+	prices := make([]int64, len(products))
+	var sum int64
+	for i, p := range products {
+		prices[i] = p.Price.Amount
+		sum += p.Price.Amount
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	n := int64(len(prices))
+	cents := func(v int64) *money.Money {
+		m := money.New(v, money.DefaultCurrency)
+		return &m
+	}
+
+	// percentile interpolates at tenths*(n-1)/10 between neighbouring prices
+	percentile := func(tenths int64) *money.Money {
+		pos := tenths * (n - 1)
+		lo, frac := pos/10, pos%10
+		v := prices[lo] * (10 - frac)
+		if frac > 0 {
+			v += prices[lo+1] * frac
+		}
+		return cents(roundDiv(v, 10))
+	}
+
+	// population variance in cents² is (n·Σx² − (Σx)²) / n²
+	sumSq, sqSum := new(big.Int), new(big.Int)
+	for _, p := range prices {
+		x := big.NewInt(p)
+		sumSq.Add(sumSq, x.Mul(x, x))
+	}
+	sqSum.Mul(big.NewInt(sum), big.NewInt(sum))
+	spread := new(big.Int).Sub(sumSq.Mul(sumSq, big.NewInt(n)), sqSum)
+	// round(√spread / n) = ⌊(⌊2√spread / n⌋ + 1) / 2⌋, all in integers
+	twice := new(big.Int).Sqrt(spread.Mul(spread, big.NewInt(4)))
+	twice.Quo(twice, big.NewInt(n))
+	stdDev := (twice.Int64() + 1) / 2
+
+	stats.Min, stats.Max = cents(prices[0]), cents(prices[n-1])
+	stats.Mean = cents(roundDiv(sum, n))
+	stats.Median, stats.P90 = percentile(5), percentile(9)
+	stats.StdDev = cents(stdDev)
+
+	stats.Histogram = db.NewPriceHistogram(*stats.Min, *stats.Max, buckets)
+	for _, p := range prices {
+		i := sort.Search(len(stats.Histogram), func(i int) bool { return stats.Histogram[i].Min.Amount > p }) - 1
+		stats.Histogram[i].Count++
+	}
+	return stats, nil
+}
+
+// roundDiv divides num by a positive den, rounding half away from zero.
+func roundDiv(num, den int64) int64 {
+	q, rem := num/den, num%den
+	if rem < 0 {
+		rem = -rem
+	}
+	if 2*rem >= den {
+		if num < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// productsIn returns copies of the unarchived products whose category is in
+// cats, oldest first. Callers must hold s.mu.
+func (s *Store) productsIn(cats map[uuid.UUID]bool) []*db.Product {
+	var out []*db.Product
+	for _, p := range s.products {
+		if cats[p.CategoryID] && p.ArchivedAt == nil {
+			cp := copyProduct(p)
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func productKey(p *db.Product) db.Cursor {
+	return db.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+func copyProduct(p db.Product) db.Product {
+	if p.Description != nil {
+		d := *p.Description
+		p.Description = &d
+	}
+	if p.ArchivedAt != nil {
+		t := *p.ArchivedAt
+		p.ArchivedAt = &t
+	}
+	return p
+}
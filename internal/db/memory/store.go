@@ -0,0 +1,428 @@
+// Package memory implements the db repositories in process memory, for tests
+// and local development without Postgres.
+//
+// All repositories created from one Store share its data and lock, so they
+// see each other's writes exactly like repositories sharing one database.
+// Semantics follow internal/db/postgres: missing rows yield sql.ErrNoRows,
+// foreign key, unique and check constraints fail with the same *pq.Error
+// codes, and multi-row writes are all-or-nothing.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// Store holds the data of every in-memory repository.
+type Store struct {
+	mu sync.RWMutex
+
+	categories    map[uuid.UUID]db.Category
+	products      map[uuid.UUID]db.Product
+	customers     map[uuid.UUID]db.Customer
+	orders        map[uuid.UUID]db.Order
+	orderItems    map[uuid.UUID][]db.OrderItem
+	statusHistory map[uuid.UUID][]db.OrderStatusChange
+	adjustments   []db.StockAdjustment
+	idempotency   map[idempotencyID]db.IdempotencyKey
+	outbox        map[uuid.UUID]db.OutboxMessage
+	preferences   map[uuid.UUID]db.NotificationPreferences // by customer
+
+	webhookSubs       map[uuid.UUID]db.WebhookSubscription
+	webhookDeliveries map[uuid.UUID]db.WebhookDelivery
+	webhookAttempts   map[uuid.UUID][]db.WebhookAttempt // by delivery, oldest first
+
+	last time.Time // last timestamp handed out by now
+}
+
+type idempotencyID struct {
+	customerID uuid.UUID
+	key        string
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{
+		categories:    make(map[uuid.UUID]db.Category),
+		products:      make(map[uuid.UUID]db.Product),
+		customers:     make(map[uuid.UUID]db.Customer),
+		orders:        make(map[uuid.UUID]db.Order),
+		orderItems:    make(map[uuid.UUID][]db.OrderItem),
+		statusHistory: make(map[uuid.UUID][]db.OrderStatusChange),
+		idempotency:   make(map[idempotencyID]db.IdempotencyKey),
+		outbox:        make(map[uuid.UUID]db.OutboxMessage),
+		preferences:   make(map[uuid.UUID]db.NotificationPreferences),
+
+		webhookSubs:       make(map[uuid.UUID]db.WebhookSubscription),
+		webhookDeliveries: make(map[uuid.UUID]db.WebhookDelivery),
+		webhookAttempts:   make(map[uuid.UUID][]db.WebhookAttempt),
+	}
+}
+
+// now returns a strictly increasing timestamp, so rows created back to back
+// still sort in creation order. Callers must hold s.mu for writing.
+func (s *Store) now() time.Time {
+	t := time.Now().UTC()
+	if !t.After(s.last) {
+		t = s.last.Add(time.Microsecond)
+	}
+	s.last = t
+	return t
+}
+
+// Constraint violations are returned as *pq.Error carrying the SQLSTATE
+// Postgres would raise, so callers classify errors from both backends alike.
+
+func uniqueViolation(format string, args ...interface{}) error {
+	return &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint: " + fmt.Sprintf(format, args...)}
+}
+
+func foreignKeyViolation(format string, args ...interface{}) error {
+	return &pq.Error{Code: "23503", Message: "insert or update violates foreign key constraint: " + fmt.Sprintf(format, args...)}
+}
+
+func checkViolation(format string, args ...interface{}) error {
+	return &pq.Error{Code: "23514", Message: "new row violates check constraint: " + fmt.Sprintf(format, args...)}
+}
+
+// ----------------------------------------------------------------------
+// categoryRepo implements db.CategoryRepository.
+// ----------------------------------------------------------------------
+
+type categoryRepo struct {
+	s *Store
+}
+
+// NewCategoryRepository returns a db.CategoryRepository backed by s.
+func NewCategoryRepository(s *Store) db.CategoryRepository {
+	return &categoryRepo{s: s}
+}
+
+// Create inserts a new category.
+func (r *categoryRepo) Create(ctx context.Context, c *db.Category) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, dup := r.s.categories[c.ID]; dup {
+		return uniqueViolation("category %s already exists", c.ID)
+	}
+	if c.ParentID != nil {
+		if _, ok := r.s.categories[*c.ParentID]; !ok {
+			return foreignKeyViolation("parent category %s does not exist", *c.ParentID)
+		}
+	}
+	c.CreatedAt = r.s.now()
+	c.UpdatedAt = c.CreatedAt
+	r.s.categories[c.ID] = copyCategory(*c)
+	return nil
+}
+
+// GetByID fetches one category.
+func (r *categoryRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Category, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	c, ok := r.s.categories[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	out := copyCategory(c)
+	return &out, nil
+}
+
+// ListChildren returns one page of the direct children of parentID, or of
+// the root categories when parentID is nil, oldest first.
+func (r *categoryRepo) ListChildren(ctx context.Context, parentID *uuid.UUID, page db.Page) ([]*db.Category, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return keysetPage(r.s.children(parentID), categoryKey, page, false), nil
+}
+
+// CountChildren counts the rows ListChildren pages through.
+func (r *categoryRepo) CountChildren(ctx context.Context, parentID *uuid.UUID) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return len(r.s.children(parentID)), nil
+}
+
+// children returns copies of the direct children of parentID, or of the
+// root categories when parentID is nil. Callers must hold s.mu.
+func (s *Store) children(parentID *uuid.UUID) []*db.Category {
+	var out []*db.Category
+	for _, c := range s.categories {
+		if (parentID == nil && c.ParentID == nil) ||
+			(parentID != nil && c.ParentID != nil && *c.ParentID == *parentID) {
+			cp := copyCategory(c)
+			out = append(out, &cp)
+		}
+	}
+	return out
+}
+
+// GetByIDs fetches the categories with the given IDs, skipping unknown ones.
+func (r *categoryRepo) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*db.Category, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.Category
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if c, ok := r.s.categories[id]; ok && !seen[id] {
+			seen[id] = true
+			cp := copyCategory(c)
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+// ListChildrenOf returns the direct children of all parentIDs, oldest first.
+func (r *categoryRepo) ListChildrenOf(ctx context.Context, parentIDs []uuid.UUID) ([]*db.Category, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	want := make(map[uuid.UUID]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		want[id] = true
+	}
+	var out []*db.Category
+	for _, c := range r.s.categories {
+		if c.ParentID != nil && want[*c.ParentID] {
+			cp := copyCategory(c)
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Tree walks down from rootID, or from every root, depth first with
+// siblings oldest first.
+func (r *categoryRepo) Tree(ctx context.Context, rootID *uuid.UUID, maxDepth int) ([]*db.CategoryTreeNode, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var roots []*db.Category
+	if rootID == nil {
+		roots = r.s.children(nil)
+	} else if c, ok := r.s.categories[*rootID]; ok {
+		cp := copyCategory(c)
+		roots = []*db.Category{&cp}
+	}
+
+	var out []*db.CategoryTreeNode
+	var walk func(cats []*db.Category, depth int, path []uuid.UUID)
+	walk = func(cats []*db.Category, depth int, path []uuid.UUID) {
+		sort.Slice(cats, func(i, j int) bool {
+			a, b := categoryKey(cats[i]), categoryKey(cats[j])
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.ID.String() < b.ID.String()
+		})
+		for _, c := range cats {
+			p := append(append([]uuid.UUID(nil), path...), c.ID)
+			out = append(out, &db.CategoryTreeNode{Category: *c, Depth: depth, Path: p})
+			if maxDepth < 0 || depth < maxDepth {
+				walk(r.s.children(&c.ID), depth+1, p)
+			}
+		}
+	}
+	walk(roots, 0, nil)
+	return out, nil
+}
+
+// AncestorsOf walks up from every id, root first.
+func (r *categoryRepo) AncestorsOf(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]*db.Category, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	out := make(map[uuid.UUID][]*db.Category)
+	for _, id := range ids {
+		c, ok := r.s.categories[id]
+		if !ok {
+			continue
+		}
+		var chain []*db.Category
+		for cur := c.ParentID; cur != nil; cur = r.s.categories[*cur].ParentID {
+			cp := copyCategory(r.s.categories[*cur])
+			chain = append([]*db.Category{&cp}, chain...)
+		}
+		if len(chain) > 0 {
+			out[id] = chain
+		}
+	}
+	return out, nil
+}
+
+// Rename sets a category's name.
+func (r *categoryRepo) Rename(ctx context.Context, id uuid.UUID, name string) (*db.Category, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.categories[id]
+	if !ok {
+		return nil, fmt.Errorf("rename category: %w", sql.ErrNoRows)
+	}
+	c.Name = name
+	c.UpdatedAt = r.s.now()
+	r.s.categories[id] = c
+	out := copyCategory(c)
+	return &out, nil
+}
+
+// Move reparents a category unless parentID is the category itself or one
+// of its descendants.
+func (r *categoryRepo) Move(ctx context.Context, id uuid.UUID, parentID *uuid.UUID) (*db.Category, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.categories[id]
+	if !ok {
+		return nil, fmt.Errorf("move category: %w", sql.ErrNoRows)
+	}
+	if parentID != nil {
+		if _, ok := r.s.categories[*parentID]; !ok {
+			return nil, fmt.Errorf("select new parent: %w", sql.ErrNoRows)
+		}
+		// walk up from the new parent, as the Postgres ancestor query does
+		for cur := parentID; cur != nil; cur = r.s.categories[*cur].ParentID {
+			if *cur == id {
+				return nil, db.ErrCategoryCycle
+			}
+		}
+		pid := *parentID
+		c.ParentID = &pid
+	} else {
+		c.ParentID = nil
+	}
+	c.UpdatedAt = r.s.now()
+	r.s.categories[id] = c
+	out := copyCategory(c)
+	return &out, nil
+}
+
+// Delete removes a category as strategy says. Nothing changes when it fails.
+func (r *categoryRepo) Delete(ctx context.Context, id uuid.UUID, strategy db.CategoryDeleteStrategy) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.categories[id]
+	if !ok {
+		return fmt.Errorf("select category: %w", sql.ErrNoRows)
+	}
+	var children, products []uuid.UUID
+	for cid, child := range r.s.categories {
+		if child.ParentID != nil && *child.ParentID == id {
+			children = append(children, cid)
+		}
+	}
+	for pid, p := range r.s.products {
+		if p.CategoryID == id {
+			products = append(products, pid)
+		}
+	}
+
+	switch strategy {
+	case db.CategoryDeleteRestrict:
+		if len(children) > 0 || len(products) > 0 {
+			return db.ErrCategoryInUse
+		}
+	case db.CategoryDeleteReparent:
+		// products need a category, so under a root they block the delete
+		if c.ParentID == nil && len(products) > 0 {
+			return db.ErrCategoryInUse
+		}
+		now := r.s.now()
+		for _, cid := range children {
+			child := r.s.categories[cid]
+			child.ParentID = c.ParentID
+			child.UpdatedAt = now
+			r.s.categories[cid] = copyCategory(child)
+		}
+		for _, pid := range products {
+			p := r.s.products[pid]
+			p.CategoryID = *c.ParentID
+			p.UpdatedAt = now
+			r.s.products[pid] = p
+		}
+	case db.CategoryDeleteCascade:
+		tree := r.s.subtree(id)
+		doomed := make(map[uuid.UUID]bool)
+		for pid, p := range r.s.products {
+			if tree[p.CategoryID] {
+				doomed[pid] = true
+			}
+		}
+		for _, items := range r.s.orderItems {
+			for _, it := range items {
+				if doomed[it.ProductID] {
+					return db.ErrProductInUse
+				}
+			}
+		}
+		for pid := range doomed {
+			delete(r.s.products, pid)
+		}
+		kept := r.s.adjustments[:0]
+		for _, a := range r.s.adjustments {
+			if !doomed[a.ProductID] {
+				kept = append(kept, a)
+			}
+		}
+		r.s.adjustments = kept
+		for cid := range tree {
+			delete(r.s.categories, cid)
+		}
+		return nil
+	default:
+		return fmt.Errorf("delete category: unknown strategy %q", strategy)
+	}
+
+	delete(r.s.categories, id)
+	return nil
+}
+
+// subtree returns categoryID and all of its descendants, like the
+// WITH RECURSIVE queries in the Postgres repositories. An unknown ID yields
+// an empty set. Callers must hold s.mu.
+func (s *Store) subtree(categoryID uuid.UUID) map[uuid.UUID]bool {
+	out := make(map[uuid.UUID]bool)
+	if _, ok := s.categories[categoryID]; !ok {
+		return out
+	}
+	out[categoryID] = true
+	for grew := true; grew; {
+		grew = false
+		for id, c := range s.categories {
+			if !out[id] && c.ParentID != nil && out[*c.ParentID] {
+				out[id] = true
+				grew = true
+			}
+		}
+	}
+	return out
+}
+
+func categoryKey(c *db.Category) db.Cursor {
+	return db.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+func copyCategory(c db.Category) db.Category {
+	if c.ParentID != nil {
+		pid := *c.ParentID
+		c.ParentID = &pid
+	}
+	return c
+}
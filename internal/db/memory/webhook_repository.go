@@ -0,0 +1,401 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type webhookRepo struct {
+	s *Store
+}
+
+// NewWebhookRepository returns a db.WebhookRepository backed by s.
+func NewWebhookRepository(s *Store) db.WebhookRepository {
+	return &webhookRepo{s: s}
+}
+
+// CreateSubscription inserts a new subscription.
+func (r *webhookRepo) CreateSubscription(ctx context.Context, sub *db.WebhookSubscription) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, dup := r.s.webhookSubs[sub.ID]; dup {
+		return uniqueViolation("webhook subscription %s already exists", sub.ID)
+	}
+	if len(sub.EventTypes) == 0 {
+		return checkViolation("webhook subscription needs at least one event type")
+	}
+	sub.CreatedAt = r.s.now()
+	sub.UpdatedAt = sub.CreatedAt
+	r.s.webhookSubs[sub.ID] = copySubscription(*sub)
+	return nil
+}
+
+// GetSubscription fetches one subscription.
+func (r *webhookRepo) GetSubscription(ctx context.Context, id uuid.UUID) (*db.WebhookSubscription, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	sub, ok := r.s.webhookSubs[id]
+	if !ok {
+		return nil, fmt.Errorf("select webhook_subscription: %w", sql.ErrNoRows)
+	}
+	out := copySubscription(sub)
+	return &out, nil
+}
+
+// ListSubscriptions returns every subscription, oldest first.
+func (r *webhookRepo) ListSubscriptions(ctx context.Context) ([]*db.WebhookSubscription, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	out := make([]*db.WebhookSubscription, 0, len(r.s.webhookSubs))
+	for _, sub := range r.s.webhookSubs {
+		cp := copySubscription(sub)
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out, nil
+}
+
+// UpdateSubscription stores the mutable fields of sub.
+func (r *webhookRepo) UpdateSubscription(ctx context.Context, sub *db.WebhookSubscription) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cur, ok := r.s.webhookSubs[sub.ID]
+	if !ok {
+		return fmt.Errorf("update webhook_subscription: %w", sql.ErrNoRows)
+	}
+	if len(sub.EventTypes) == 0 {
+		return checkViolation("webhook subscription needs at least one event type")
+	}
+	cur.URL, cur.Secret, cur.EventTypes, cur.Active = sub.URL, sub.Secret, sub.EventTypes, sub.Active
+	cur.UpdatedAt = r.s.now()
+	r.s.webhookSubs[sub.ID] = copySubscription(cur)
+	sub.CreatedAt, sub.UpdatedAt = cur.CreatedAt, cur.UpdatedAt
+	return nil
+}
+
+// DeactivateSubscription clears the active flag of a subscription.
+func (r *webhookRepo) DeactivateSubscription(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cur, ok := r.s.webhookSubs[id]
+	if !ok {
+		return fmt.Errorf("deactivate webhook_subscription: %w", sql.ErrNoRows)
+	}
+	cur.Active = false
+	cur.UpdatedAt = r.s.now()
+	r.s.webhookSubs[id] = cur
+	return nil
+}
+
+// DeleteSubscription removes a subscription with its deliveries and their
+// attempts, as ON DELETE CASCADE does.
+func (r *webhookRepo) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.webhookSubs[id]; !ok {
+		return fmt.Errorf("delete webhook_subscription: %w", sql.ErrNoRows)
+	}
+	delete(r.s.webhookSubs, id)
+	for did, d := range r.s.webhookDeliveries {
+		if d.SubscriptionID == id {
+			delete(r.s.webhookDeliveries, did)
+			delete(r.s.webhookAttempts, did)
+		}
+	}
+	return nil
+}
+
+// checkWebhookEvents applies the constraints of webhook_deliveries to the
+// deliveries events would create. newOrderID names an order being stored in
+// the same write. Callers must hold s.mu.
+func (s *Store) checkWebhookEvents(events []*db.WebhookEvent, newOrderID uuid.UUID) error {
+	for _, e := range events {
+		if !json.Valid(e.Payload) {
+			return fmt.Errorf("insert webhook_deliveries: %w", &pq.Error{Code: "22P02", Message: "invalid input syntax for type json"})
+		}
+		if e.OrderID != nil && *e.OrderID != newOrderID {
+			if _, ok := s.orders[*e.OrderID]; !ok {
+				return fmt.Errorf("insert webhook_deliveries: %w", foreignKeyViolation("order %s does not exist", *e.OrderID))
+			}
+		}
+		for _, d := range s.webhookDeliveries {
+			if d.EventID == e.ID && subscribed(s.webhookSubs[d.SubscriptionID], e.Type) {
+				return fmt.Errorf("insert webhook_deliveries: %w", uniqueViolation("event %s already delivered to %s", e.ID, d.SubscriptionID))
+			}
+		}
+	}
+	return nil
+}
+
+// fanOut creates a delivery of every event that passed checkWebhookEvents
+// for each active subscription of its type. Callers must hold s.mu for
+// writing.
+func (s *Store) fanOut(events []*db.WebhookEvent) {
+	now := s.now()
+	for _, e := range events {
+		for _, sub := range s.webhookSubs {
+			if !subscribed(sub, e.Type) {
+				continue
+			}
+			d := db.WebhookDelivery{
+				ID:             uuid.New(),
+				SubscriptionID: sub.ID,
+				EventID:        e.ID,
+				EventType:      e.Type,
+				OrderID:        e.OrderID,
+				Payload:        e.Payload,
+				Status:         db.DeliveryPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+			s.webhookDeliveries[d.ID] = copyDelivery(d)
+		}
+	}
+}
+
+func subscribed(sub db.WebhookSubscription, eventType string) bool {
+	if !sub.Active {
+		return false
+	}
+	for _, t := range sub.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Claim leases due deliveries that no other claim holds.
+func (r *webhookRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]*db.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	var due []db.WebhookDelivery
+	for _, d := range r.s.webhookDeliveries {
+		if d.Status == db.DeliveryPending && !d.NextAttemptAt.After(now) &&
+			(d.LockedUntil == nil || !d.LockedUntil.After(now)) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID.String() < due[j].ID.String()
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	until := now.Add(lease)
+	out := make([]*db.WebhookDelivery, len(due))
+	for i, d := range due {
+		d.Attempts++
+		d.LockedUntil = &until
+		d.UpdatedAt = now
+		r.s.webhookDeliveries[d.ID] = copyDelivery(d)
+		cp := copyDelivery(d)
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+// MarkDelivered logs a and marks its delivery delivered.
+func (r *webhookRepo) MarkDelivered(ctx context.Context, a *db.WebhookAttempt) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.webhookDeliveries[a.DeliveryID]
+	if !ok || d.Status != db.DeliveryPending {
+		return fmt.Errorf("mark webhook delivered: %w", sql.ErrNoRows)
+	}
+	now := r.s.now()
+	d.Status = db.DeliveryDelivered
+	d.DeliveredAt, d.LockedUntil = &now, nil
+	d.UpdatedAt = now
+	r.s.webhookDeliveries[d.ID] = d
+	r.s.logAttempt(a, now)
+	return nil
+}
+
+// MarkFailed logs a and either schedules the next attempt or moves the
+// delivery to dead.
+func (r *webhookRepo) MarkFailed(ctx context.Context, a *db.WebhookAttempt, retryAt *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.webhookDeliveries[a.DeliveryID]
+	if !ok || d.Status != db.DeliveryPending {
+		return fmt.Errorf("mark webhook failed: %w", sql.ErrNoRows)
+	}
+	if retryAt == nil {
+		d.Status = db.DeliveryDead
+	} else {
+		d.NextAttemptAt = *retryAt
+	}
+	d.LastError, d.LockedUntil = nil, nil
+	if a.Error != nil {
+		msg := *a.Error
+		d.LastError = &msg
+	}
+	d.UpdatedAt = r.s.now()
+	r.s.webhookDeliveries[d.ID] = d
+	r.s.logAttempt(a, d.UpdatedAt)
+	return nil
+}
+
+// Release takes back the attempt Claim counted and ends the lease, unless
+// the delivery has been claimed again since.
+func (r *webhookRepo) Release(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.webhookDeliveries[id]
+	if !ok || d.Status != db.DeliveryPending || d.LockedUntil == nil || !d.LockedUntil.Equal(lockedUntil) {
+		return fmt.Errorf("release webhook: %w", sql.ErrNoRows)
+	}
+	if d.Attempts > 0 {
+		d.Attempts--
+	}
+	d.LockedUntil = nil
+	d.UpdatedAt = r.s.now()
+	r.s.webhookDeliveries[id] = d
+	return nil
+}
+
+// logAttempt stores a. Callers must hold s.mu for writing.
+func (s *Store) logAttempt(a *db.WebhookAttempt, now time.Time) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	a.CreatedAt = now
+	s.webhookAttempts[a.DeliveryID] = append(s.webhookAttempts[a.DeliveryID], copyAttempt(*a))
+}
+
+// Redeliver resets a dead delivery so it is claimed again right away.
+func (r *webhookRepo) Redeliver(ctx context.Context, id uuid.UUID) (*db.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.webhookDeliveries[id]
+	if !ok || d.Status != db.DeliveryDead {
+		return nil, fmt.Errorf("redeliver webhook: %w", sql.ErrNoRows)
+	}
+	now := r.s.now()
+	d.Status, d.Attempts = db.DeliveryPending, 0
+	d.NextAttemptAt, d.LockedUntil = now, nil
+	d.UpdatedAt = now
+	r.s.webhookDeliveries[id] = d
+	out := copyDelivery(d)
+	return &out, nil
+}
+
+// GetDelivery fetches one delivery.
+func (r *webhookRepo) GetDelivery(ctx context.Context, id uuid.UUID) (*db.WebhookDelivery, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	d, ok := r.s.webhookDeliveries[id]
+	if !ok {
+		return nil, fmt.Errorf("select webhook_delivery: %w", sql.ErrNoRows)
+	}
+	out := copyDelivery(d)
+	return &out, nil
+}
+
+// ListDeliveries returns a subscription's deliveries, newest first.
+func (r *webhookRepo) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status *string, limit int) ([]*db.WebhookDelivery, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.WebhookDelivery
+	for _, d := range r.s.webhookDeliveries {
+		if d.SubscriptionID == subscriptionID && (status == nil || d.Status == *status) {
+			cp := copyDelivery(d)
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID.String() > out[j].ID.String()
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// ListAttempts returns the attempts of a delivery, oldest first.
+func (r *webhookRepo) ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]*db.WebhookAttempt, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var out []*db.WebhookAttempt
+	for _, a := range r.s.webhookAttempts[deliveryID] {
+		cp := copyAttempt(a)
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func copySubscription(s db.WebhookSubscription) db.WebhookSubscription {
+	s.EventTypes = append([]string(nil), s.EventTypes...)
+	return s
+}
+
+func copyDelivery(d db.WebhookDelivery) db.WebhookDelivery {
+	if d.OrderID != nil {
+		v := *d.OrderID
+		d.OrderID = &v
+	}
+	d.Payload = append([]byte(nil), d.Payload...)
+	if d.LastError != nil {
+		v := *d.LastError
+		d.LastError = &v
+	}
+	if d.LockedUntil != nil {
+		v := *d.LockedUntil
+		d.LockedUntil = &v
+	}
+	if d.DeliveredAt != nil {
+		v := *d.DeliveredAt
+		d.DeliveredAt = &v
+	}
+	return d
+}
+
+func copyAttempt(a db.WebhookAttempt) db.WebhookAttempt {
+	if a.StatusCode != nil {
+		v := *a.StatusCode
+		a.StatusCode = &v
+	}
+	if a.Error != nil {
+		v := *a.Error
+		a.Error = &v
+	}
+	return a
+}
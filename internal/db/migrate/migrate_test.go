@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/felixojiambo/go-graphql-order-service/migrations"
+)
+
+func sqlFile(src string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(src)}
+}
+
+func TestLoad(t *testing.T) {
+	got, err := Load(fstest.MapFS{
+		"010_add_index.up.sql":       sqlFile("CREATE INDEX i ON t (a);"),
+		"002_create_t.down.sql":      sqlFile("DROP TABLE t;"),
+		"002_create_t.up.sql":        sqlFile("CREATE TABLE t (a INT);"),
+		"001_init.up.sql":            sqlFile("CREATE SCHEMA s;"),
+		"001_init.down.sql":          sqlFile("DROP SCHEMA s;"),
+		"README.md":                  sqlFile("not a migration"),
+		"drafts/003_later.up.sql":    sqlFile("ignored"),
+		"migrations.go":              sqlFile("package migrations"),
+		"010_add_index.up.sql.orig":  sqlFile("ignored"),
+		"fixtures/seed_products.sql": sqlFile("ignored"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE SCHEMA s;", Down: "DROP SCHEMA s;"},
+		{Version: 2, Name: "create_t", Up: "CREATE TABLE t (a INT);", Down: "DROP TABLE t;"},
+		// a migration without a down file cannot be reverted
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX i ON t (a);"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr string
+	}{
+		{"down without up", fstest.MapFS{
+			"001_init.down.sql": sqlFile("DROP SCHEMA s;"),
+		}, "migration 1_init has no up file"},
+		{"empty up", fstest.MapFS{
+			"001_init.up.sql": sqlFile(""),
+		}, "migration 1_init has no up file"},
+		{"two names", fstest.MapFS{
+			"001_init.up.sql":    sqlFile("CREATE SCHEMA s;"),
+			"001_setup.down.sql": sqlFile("DROP SCHEMA s;"),
+		}, "migration 1 has two names"},
+		{"duplicate up", fstest.MapFS{
+			"001_init.up.sql": sqlFile("CREATE SCHEMA s;"),
+			"1_init.up.sql":   sqlFile("CREATE SCHEMA t;"),
+		}, "migration 1 has two up files"},
+		{"duplicate down", fstest.MapFS{
+			"001_init.up.sql":    sqlFile("CREATE SCHEMA s;"),
+			"001_init.down.sql":  sqlFile("DROP SCHEMA s;"),
+			"0001_init.down.sql": sqlFile("DROP SCHEMA s;"),
+		}, "migration 1 has two down files"},
+		{"upper case", fstest.MapFS{"001_Init.up.sql": sqlFile("")}, "migration 001_Init.up.sql: name must look like"},
+		{"no version", fstest.MapFS{"init.up.sql": sqlFile("")}, "migration init.up.sql: name must look like"},
+		{"no direction", fstest.MapFS{"001_init.sql": sqlFile("")}, "migration 001_init.sql: name must look like"},
+		{"misspelled direction", fstest.MapFS{"001_init.upp.sql": sqlFile("")}, "migration 001_init.upp.sql: name must look like"},
+		{"version out of range", fstest.MapFS{"99999999999999999999_init.up.sql": sqlFile("")}, "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	ms, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range ms {
+		if m.Version != int64(i+1) || m.Down == "" {
+			t.Errorf("migration %d_%s: want version %d with a down file", m.Version, m.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cursor is a keyset position: the sort key of the last row a client has
+// seen. Every paged list is ordered by (created_at, id), so the pair is
+// unique and stable while rows are inserted.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Page selects up to Limit rows that sort after After, or from the start
+// when After is nil. Lists never skip rows with OFFSET.
+type Page struct {
+	Limit int
+	After *Cursor
+}
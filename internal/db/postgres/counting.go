@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// pqConn is the set of driver interfaces lib/pq connections implement and
+// countingConn passes through.
+type pqConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.QueryerContext
+	driver.ExecerContext
+	driver.Pinger
+	driver.NamedValueChecker
+	driver.SessionResetter
+	driver.Validator
+}
+
+// countingConnector opens lib/pq connections that report every query to the
+// db.QueryCounter in the query's context.
+type countingConnector struct {
+	driver.Connector
+}
+
+func (c countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, ok := conn.(pqConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("postgres: unexpected driver connection %T", conn)
+	}
+	return countingConn{pc}, nil
+}
+
+type countingConn struct {
+	pqConn
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db.CountQuery(ctx)
+	return c.pqConn.QueryContext(ctx, query, args)
+}
+
+func (c countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db.CountQuery(ctx)
+	return c.pqConn.ExecContext(ctx, query, args)
+}
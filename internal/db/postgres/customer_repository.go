@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type customerRepo struct {
+	db *sqlx.DB
+}
+
+// NewCustomerRepository returns a db.CustomerRepository backed by Postgres.
+func NewCustomerRepository(db *sqlx.DB) db.CustomerRepository {
+	return &customerRepo{db: db}
+}
+
+// Create inserts a new customer and fills in its timestamps.
+func (r *customerRepo) Create(ctx context.Context, c *db.Customer) error {
+	const query = `
+		INSERT INTO customers (id, name, email, firebase_uid, phone, locale)
+		VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'en'))
+		RETURNING locale, created_at, updated_at
+	`
+	return r.db.QueryRowxContext(ctx, query, c.ID, c.Name, c.Email, c.FirebaseUID, c.Phone, c.Locale).
+		Scan(&c.Locale, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// GetByID fetches one customer by its UUID.
+func (r *customerRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Customer, error) {
+	var c db.Customer
+	const query = `
+		SELECT id, name, email, firebase_uid, phone, locale, created_at, updated_at
+		FROM customers
+		WHERE id = $1
+	`
+	if err := r.db.GetContext(ctx, &c, query, id); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetByEmail fetches one customer by email, ignoring case.
+func (r *customerRepo) GetByEmail(ctx context.Context, email string) (*db.Customer, error) {
+	var c db.Customer
+	const query = `
+		SELECT id, name, email, firebase_uid, phone, locale, created_at, updated_at
+		FROM customers
+		WHERE lower(email) = lower($1)
+	`
+	if err := r.db.GetContext(ctx, &c, query, email); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetByFirebaseUID fetches the customer linked to a Firebase identity.
+func (r *customerRepo) GetByFirebaseUID(ctx context.Context, uid string) (*db.Customer, error) {
+	var c db.Customer
+	const query = `
+		SELECT id, name, email, firebase_uid, phone, locale, created_at, updated_at
+		FROM customers
+		WHERE firebase_uid = $1
+	`
+	if err := r.db.GetContext(ctx, &c, query, uid); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// List returns one page of customers ordered by creation time.
+func (r *customerRepo) List(ctx context.Context, page db.Page) ([]*db.Customer, error) {
+	var rows []*db.Customer
+	const query = `
+		SELECT id, name, email, firebase_uid, phone, locale, created_at, updated_at
+		FROM customers
+		WHERE ($1::timestamptz IS NULL OR (created_at, id) > ($1, $2))
+		ORDER BY created_at, id
+		LIMIT $3
+	`
+	createdAt, id := afterArgs(page)
+	if err := r.db.SelectContext(ctx, &rows, query, createdAt, id, page.Limit); err != nil {
+		return nil, fmt.Errorf("select customers: %w", err)
+	}
+	return rows, nil
+}
+
+// Count returns the number of customers.
+func (r *customerRepo) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := r.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM customers`); err != nil {
+		return 0, fmt.Errorf("count customers: %w", err)
+	}
+	return n, nil
+}
+
+// Update writes name, email, phone and locale and bumps updated_at.
+// It returns an error wrapping sql.ErrNoRows if the customer does not exist.
+func (r *customerRepo) Update(ctx context.Context, c *db.Customer) error {
+	const query = `
+		UPDATE customers
+		SET name = $2, email = $3, phone = $4, locale = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	if err := r.db.QueryRowxContext(ctx, query, c.ID, c.Name, c.Email, c.Phone, c.Locale).Scan(&c.UpdatedAt); err != nil {
+		return fmt.Errorf("update customer: %w", err)
+	}
+	return nil
+}
+
+// LinkFirebaseUID sets firebase_uid on a customer that has none yet.
+// It returns an error wrapping sql.ErrNoRows if the customer does not exist
+// or is already linked.
+func (r *customerRepo) LinkFirebaseUID(ctx context.Context, id uuid.UUID, uid string) error {
+	const query = `
+		UPDATE customers
+		SET firebase_uid = $2, updated_at = NOW()
+		WHERE id = $1 AND firebase_uid IS NULL
+		RETURNING id
+	`
+	var linked uuid.UUID
+	if err := r.db.QueryRowxContext(ctx, query, id, uid).Scan(&linked); err != nil {
+		return fmt.Errorf("link firebase uid: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type inventoryRepo struct {
+	db *sqlx.DB
+}
+
+// NewInventoryRepository returns a db.InventoryRepository backed by Postgres.
+func NewInventoryRepository(db *sqlx.DB) db.InventoryRepository {
+	return &inventoryRepo{db: db}
+}
+
+// AdjustStock applies delta to a product's stock and writes the audit row
+// in the same transaction.
+func (r *inventoryRepo) AdjustStock(ctx context.Context, productID uuid.UUID, delta int, reason, actorUID string) (*db.StockAdjustment, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// lock the row first so a missing product can be told apart from a shortfall
+	var current int
+	if err := tx.GetContext(ctx, &current,
+		`SELECT stock_quantity FROM products WHERE id = $1 FOR UPDATE`, productID,
+	); err != nil {
+		return nil, fmt.Errorf("select product stock: %w", err)
+	}
+	if current+delta < 0 {
+		return nil, &db.InsufficientStockError{ProductIDs: []uuid.UUID{productID}}
+	}
+
+	const update = `
+		UPDATE products
+		SET stock_quantity = stock_quantity + $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING stock_quantity
+	`
+	var after int
+	if err := tx.QueryRowxContext(ctx, update, productID, delta).Scan(&after); err != nil {
+		return nil, fmt.Errorf("update product stock: %w", err)
+	}
+
+	adj := &db.StockAdjustment{
+		ProductID:     productID,
+		Delta:         delta,
+		QuantityAfter: after,
+		Reason:        reason,
+		ActorUID:      &actorUID,
+	}
+	if err := insertStockAdjustment(ctx, tx, adj); err != nil {
+		return nil, err
+	}
+	return adj, tx.Commit()
+}
+
+// StockLevels returns products with their current stock, lowest first.
+func (r *inventoryRepo) StockLevels(ctx context.Context, productIDs []uuid.UUID) ([]*db.Product, error) {
+	var rows []*db.Product
+	var err error
+	if len(productIDs) == 0 {
+		const query = `
+			SELECT id, name, description, price, category_id, stock_quantity,
+			       created_at, updated_at, archived_at
+			FROM products
+			ORDER BY stock_quantity, name
+		`
+		err = r.db.SelectContext(ctx, &rows, query)
+	} else {
+		const query = `
+			SELECT id, name, description, price, category_id, stock_quantity,
+			       created_at, updated_at, archived_at
+			FROM products
+			WHERE id = ANY($1)
+			ORDER BY stock_quantity, name
+		`
+		err = r.db.SelectContext(ctx, &rows, query, pq.Array(productIDs))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select stock levels: %w", err)
+	}
+	return rows, nil
+}
+
+// ListAdjustments returns up to limit adjustments of a product, newest first.
+func (r *inventoryRepo) ListAdjustments(ctx context.Context, productID uuid.UUID, limit int) ([]*db.StockAdjustment, error) {
+	var rows []*db.StockAdjustment
+	const query = `
+		SELECT id, product_id, delta, quantity_after, reason, order_id, actor_uid, created_at
+		FROM stock_adjustments
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2
+	`
+	if err := r.db.SelectContext(ctx, &rows, query, productID, limit); err != nil {
+		return nil, fmt.Errorf("select stock_adjustments: %w", err)
+	}
+	return rows, nil
+}
+
+// insertStockAdjustment writes one audit row inside tx and fills in its ID
+// and timestamp.
+func insertStockAdjustment(ctx context.Context, tx *sqlx.Tx, a *db.StockAdjustment) error {
+	a.ID = uuid.New()
+	const query = `
+		INSERT INTO stock_adjustments (id, product_id, delta, quantity_after, reason, order_id, actor_uid)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`
+	if err := tx.QueryRowxContext(ctx, query,
+		a.ID, a.ProductID, a.Delta, a.QuantityAfter, a.Reason, a.OrderID, a.ActorUID,
+	).Scan(&a.CreatedAt); err != nil {
+		return fmt.Errorf("insert stock_adjustment: %w", err)
+	}
+	return nil
+}
@@ -3,7 +3,10 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -20,8 +23,9 @@ func NewOrderRepository(db *sqlx.DB) db.OrderRepository {
 	return &orderRepo{db: db}
 }
 
-// CreateOrder inserts an Order and its OrderItems in a single transaction.
-func (r *orderRepo) CreateOrder(ctx context.Context, o *db.Order, items []*db.OrderItem) error {
+// CreateOrder inserts an Order, its OrderItems, the outbox messages
+// announcing it and its webhook deliveries in a single transaction.
+func (r *orderRepo) CreateOrder(ctx context.Context, o *db.Order, items []*db.OrderItem, key *db.IdempotencyKey, outbox []*db.OutboxMessage, events []*db.WebhookEvent) error {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -40,6 +44,13 @@ func (r *orderRepo) CreateOrder(ctx context.Context, o *db.Order, items []*db.Or
 		return fmt.Errorf("insert order: %w", err)
 	}
 
+	// claim the idempotency key before reserving anything
+	if key != nil {
+		if err := claimIdempotencyKey(ctx, tx, o.ID, key); err != nil {
+			return err
+		}
+	}
+
 	// insert items
 	const insertItem = `
 		INSERT INTO order_items (
@@ -56,14 +67,165 @@ func (r *orderRepo) CreateOrder(ctx context.Context, o *db.Order, items []*db.Or
 		}
 	}
 
+	// reserve stock
+	if err := reserveStock(ctx, tx, o.ID, items); err != nil {
+		return err
+	}
+
+	if err := insertOutbox(ctx, tx, outbox); err != nil {
+		return err
+	}
+	if err := insertWebhookDeliveries(ctx, tx, events); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
+// claimIdempotencyKey stores key for orderID. An expired holder of the key is
+// replaced; a live one turns into *db.IdempotencyReplayError. A concurrent
+// transaction claiming the same key blocks on the primary key until it
+// finishes, so exactly one order wins.
+func claimIdempotencyKey(ctx context.Context, tx *sqlx.Tx, orderID uuid.UUID, key *db.IdempotencyKey) error {
+	const purge = `
+		DELETE FROM idempotency_keys
+		WHERE customer_id = $1 AND key = $2 AND expires_at <= NOW()
+	`
+	if _, err := tx.ExecContext(ctx, purge, key.CustomerID, key.Key); err != nil {
+		return fmt.Errorf("purge idempotency key: %w", err)
+	}
+
+	const claim = `
+		INSERT INTO idempotency_keys (customer_id, key, request_hash, order_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		ON CONFLICT (customer_id, key) DO NOTHING
+	`
+	res, err := tx.ExecContext(ctx, claim, key.CustomerID, key.Key, key.RequestHash, orderID, key.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert idempotency key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 1 {
+		key.OrderID = orderID
+		return nil
+	}
+
+	var held db.IdempotencyKey
+	const sel = `
+		SELECT customer_id, key, request_hash, order_id, created_at, expires_at
+		FROM idempotency_keys
+		WHERE customer_id = $1 AND key = $2
+	`
+	if err := tx.GetContext(ctx, &held, sel, key.CustomerID, key.Key); err != nil {
+		return fmt.Errorf("select idempotency key: %w", err)
+	}
+	return &db.IdempotencyReplayError{OrderID: held.OrderID, RequestHash: held.RequestHash}
+}
+
+// reserveStock decrements stock for every product in items and audits each
+// reservation. Products are locked in ID order so concurrent orders cannot
+// deadlock. All short products are collected before failing.
+func reserveStock(ctx context.Context, tx *sqlx.Tx, orderID uuid.UUID, items []*db.OrderItem) error {
+	qty := make(map[uuid.UUID]int)
+	var ids []uuid.UUID
+	for _, it := range items {
+		if _, seen := qty[it.ProductID]; !seen {
+			ids = append(ids, it.ProductID)
+		}
+		qty[it.ProductID] += it.Quantity
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	const decrement = `
+		UPDATE products
+		SET stock_quantity = stock_quantity - $2, updated_at = NOW()
+		WHERE id = $1 AND stock_quantity >= $2
+		RETURNING stock_quantity
+	`
+	short := &db.InsufficientStockError{}
+	for _, pid := range ids {
+		var after int
+		err := tx.QueryRowxContext(ctx, decrement, pid, qty[pid]).Scan(&after)
+		if errors.Is(err, sql.ErrNoRows) {
+			short.ProductIDs = append(short.ProductIDs, pid)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reserve stock for %s: %w", pid, err)
+		}
+		if err := insertStockAdjustment(ctx, tx, &db.StockAdjustment{
+			ProductID:     pid,
+			Delta:         -qty[pid],
+			QuantityAfter: after,
+			Reason:        db.StockReasonOrderPlaced,
+			OrderID:       &orderID,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(short.ProductIDs) > 0 {
+		return short
+	}
+	return nil
+}
+
+// releaseStock returns the reserved quantities of an order to stock.
+func releaseStock(ctx context.Context, tx *sqlx.Tx, orderID uuid.UUID) error {
+	const increment = `
+		UPDATE products p
+		SET stock_quantity = p.stock_quantity + oi.qty, updated_at = NOW()
+		FROM (
+			SELECT product_id, SUM(quantity) AS qty
+			FROM order_items
+			WHERE order_id = $1
+			GROUP BY product_id
+		) oi
+		WHERE p.id = oi.product_id
+		RETURNING p.id, oi.qty, p.stock_quantity
+	`
+	rows, err := tx.QueryxContext(ctx, increment, orderID)
+	if err != nil {
+		return fmt.Errorf("release stock: %w", err)
+	}
+	type released struct {
+		productID uuid.UUID
+		qty       int
+		after     int
+	}
+	var all []released
+	for rows.Next() {
+		var rel released
+		if err := rows.Scan(&rel.productID, &rel.qty, &rel.after); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, rel)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, rel := range all {
+		if err := insertStockAdjustment(ctx, tx, &db.StockAdjustment{
+			ProductID:     rel.productID,
+			Delta:         rel.qty,
+			QuantityAfter: rel.after,
+			Reason:        db.StockReasonOrderCancelled,
+			OrderID:       &orderID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetByID fetches one Order together with its items.
 func (r *orderRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Order, []*db.OrderItem, error) {
 	var o db.Order
 	const selOrder = `
-		SELECT id, customer_id, total_amount, status, created_at, updated_at
+		SELECT id, customer_id, total_amount, status, created_at, updated_at,
+		       cancel_reason, cancel_note, cancelled_at
 		FROM orders
 		WHERE id = $1
 	`
@@ -85,17 +247,143 @@ func (r *orderRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Order, []*db
 	return &o, items, nil
 }
 
-// ListByCustomer returns all Orders for a given customer.
-func (r *orderRepo) ListByCustomer(ctx context.Context, customerID uuid.UUID) ([]*db.Order, error) {
+// GetIdempotencyKey fetches a live idempotency key.
+func (r *orderRepo) GetIdempotencyKey(ctx context.Context, customerID uuid.UUID, key string) (*db.IdempotencyKey, error) {
+	const query = `
+		SELECT customer_id, key, request_hash, order_id, created_at, expires_at
+		FROM idempotency_keys
+		WHERE customer_id = $1 AND key = $2 AND expires_at > NOW()
+	`
+	var k db.IdempotencyKey
+	if err := r.db.GetContext(ctx, &k, query, customerID, key); err != nil {
+		return nil, fmt.Errorf("select idempotency key: %w", err)
+	}
+	return &k, nil
+}
+
+// ListByCustomer returns one page of a customer's Orders, newest first.
+func (r *orderRepo) ListByCustomer(ctx context.Context, customerID uuid.UUID, page db.Page) ([]*db.Order, error) {
 	var orders []*db.Order
 	const sel = `
-		SELECT id, customer_id, total_amount, status, created_at, updated_at
+		SELECT id, customer_id, total_amount, status, created_at, updated_at,
+		       cancel_reason, cancel_note, cancelled_at
 		FROM orders
 		WHERE customer_id = $1
-		ORDER BY created_at DESC
+		  AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
 	`
-	if err := r.db.SelectContext(ctx, &orders, sel, customerID); err != nil {
+	createdAt, id := afterArgs(page)
+	if err := r.db.SelectContext(ctx, &orders, sel, customerID, createdAt, id, page.Limit); err != nil {
 		return nil, fmt.Errorf("select orders by customer: %w", err)
 	}
 	return orders, nil
 }
+
+// CountByCustomer returns the number of Orders of a customer.
+func (r *orderRepo) CountByCustomer(ctx context.Context, customerID uuid.UUID) (int, error) {
+	var n int
+	const sel = `SELECT COUNT(*) FROM orders WHERE customer_id = $1`
+	if err := r.db.GetContext(ctx, &n, sel, customerID); err != nil {
+		return 0, fmt.Errorf("count orders by customer: %w", err)
+	}
+	return n, nil
+}
+
+// UpdateStatus changes an order's status, appends a history row and fans out
+// events in one transaction. The update only applies while the order is
+// still in from.
+func (r *orderRepo) UpdateStatus(ctx context.Context, id uuid.UUID, from, to, actorUID string, events []*db.WebhookEvent) error {
+	if to == "cancelled" {
+		return db.ErrCancelViaUpdateStatus
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const updOrder = `
+		UPDATE orders
+		SET status = $3, updated_at = NOW()
+		WHERE id = $1 AND status = $2
+	`
+	if err := execTransition(ctx, tx, updOrder, id, from, to, actorUID); err != nil {
+		return err
+	}
+	if err := insertWebhookDeliveries(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CancelOrder marks an order cancelled with its reason, records the
+// transition and enqueues outbox and events, under the same compare-and-set
+// rule as UpdateStatus.
+func (r *orderRepo) CancelOrder(ctx context.Context, id uuid.UUID, from, actorUID, reason string, note *string, outbox []*db.OutboxMessage, events []*db.WebhookEvent) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const updOrder = `
+		UPDATE orders
+		SET status = $3, cancel_reason = $4, cancel_note = $5,
+		    cancelled_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = $2
+	`
+	if err := execTransition(ctx, tx, updOrder, id, from, "cancelled", actorUID, reason, note); err != nil {
+		return err
+	}
+	if err := releaseStock(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, outbox); err != nil {
+		return err
+	}
+	if err := insertWebhookDeliveries(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execTransition runs a status UPDATE whose first three parameters are
+// (id, from, to) and appends the matching order_status_history row.
+// It returns db.ErrStatusConflict when no row was updated.
+func execTransition(ctx context.Context, tx *sqlx.Tx, update string, id uuid.UUID, from, to, actorUID string, extra ...interface{}) error {
+	args := append([]interface{}{id, from, to}, extra...)
+	res, err := tx.ExecContext(ctx, update, args...)
+	if err != nil {
+		return fmt.Errorf("update order status: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return db.ErrStatusConflict
+	}
+
+	const insHistory = `
+		INSERT INTO order_status_history (id, order_id, from_status, to_status, actor_uid, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`
+	if _, err := tx.ExecContext(ctx, insHistory, uuid.New(), id, from, to, actorUID); err != nil {
+		return fmt.Errorf("insert order_status_history: %w", err)
+	}
+	return nil
+}
+
+// ListStatusHistory returns an order's transitions, oldest first.
+func (r *orderRepo) ListStatusHistory(ctx context.Context, orderID uuid.UUID) ([]*db.OrderStatusChange, error) {
+	var changes []*db.OrderStatusChange
+	const sel = `
+		SELECT id, order_id, from_status, to_status, actor_uid, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at
+	`
+	if err := r.db.SelectContext(ctx, &changes, sel, orderID); err != nil {
+		return nil, fmt.Errorf("select order_status_history: %w", err)
+	}
+	return changes, nil
+}
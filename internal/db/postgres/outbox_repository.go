@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type outboxRepo struct {
+	db *sqlx.DB
+}
+
+// NewOutboxRepository returns a db.OutboxRepository backed by Postgres.
+func NewOutboxRepository(db *sqlx.DB) db.OutboxRepository {
+	return &outboxRepo{db: db}
+}
+
+// Enqueue inserts msgs in one transaction.
+func (r *outboxRepo) Enqueue(ctx context.Context, msgs ...*db.OutboxMessage) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertOutbox(ctx, tx, msgs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertOutbox enqueues msgs within tx and fills in their stored state.
+func insertOutbox(ctx context.Context, tx *sqlx.Tx, msgs []*db.OutboxMessage) error {
+	const insert = `
+		INSERT INTO notification_outbox (id, order_id, customer_id, event, channel, recipient,
+		                                 subject, body, html_body, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10, NOW()))
+		RETURNING status, attempts, next_attempt_at, created_at, updated_at
+	`
+	for _, m := range msgs {
+		if m.ID == uuid.Nil {
+			m.ID = uuid.New()
+		}
+		var due *time.Time
+		if !m.NextAttemptAt.IsZero() {
+			due = &m.NextAttemptAt
+		}
+		if err := tx.QueryRowxContext(
+			ctx, insert,
+			m.ID, m.OrderID, m.CustomerID, m.Event, m.Channel, m.Recipient,
+			m.Subject, m.Body, m.HTMLBody, due,
+		).Scan(&m.Status, &m.Attempts, &m.NextAttemptAt, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return fmt.Errorf("insert notification_outbox: %w", err)
+		}
+	}
+	return nil
+}
+
+// Claim leases due messages. SKIP LOCKED lets concurrent dispatchers claim
+// disjoint batches without waiting on each other.
+func (r *outboxRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]*db.OutboxMessage, error) {
+	const query = `
+		UPDATE notification_outbox o
+		SET attempts = o.attempts + 1,
+		    locked_until = NOW() + make_interval(secs => $2),
+		    updated_at = NOW()
+		FROM (
+			SELECT id
+			FROM notification_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			  AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE o.id = due.id
+		RETURNING o.id, o.order_id, o.customer_id, o.event, o.channel, o.recipient,
+		          o.subject, o.body, o.html_body, o.status, o.attempts, o.last_error,
+		          o.next_attempt_at, o.locked_until, o.created_at, o.updated_at, o.sent_at,
+		          o.suppressed_reason
+	`
+	var msgs []*db.OutboxMessage
+	if err := r.db.SelectContext(ctx, &msgs, query, limit, lease.Seconds()); err != nil {
+		return nil, fmt.Errorf("claim notification_outbox: %w", err)
+	}
+	// RETURNING does not keep the subquery's order
+	sort.Slice(msgs, func(i, j int) bool {
+		if !msgs[i].NextAttemptAt.Equal(msgs[j].NextAttemptAt) {
+			return msgs[i].NextAttemptAt.Before(msgs[j].NextAttemptAt)
+		}
+		return msgs[i].ID.String() < msgs[j].ID.String()
+	})
+	return msgs, nil
+}
+
+// MarkSent records a delivery and releases the lease.
+// It returns an error wrapping sql.ErrNoRows unless the message is pending.
+func (r *outboxRepo) MarkSent(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE notification_outbox
+		SET status = 'sent', sent_at = NOW(), locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id
+	`
+	var marked uuid.UUID
+	if err := r.db.QueryRowxContext(ctx, query, id).Scan(&marked); err != nil {
+		return fmt.Errorf("mark notification sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed stores errMsg and either schedules the next attempt or moves
+// the message to 'dead'.
+// It returns an error wrapping sql.ErrNoRows unless the message is pending.
+func (r *outboxRepo) MarkFailed(ctx context.Context, id uuid.UUID, errMsg string, retryAt *time.Time) error {
+	const query = `
+		UPDATE notification_outbox
+		SET status = CASE WHEN $3::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+		    next_attempt_at = COALESCE($3, next_attempt_at),
+		    last_error = $2, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id
+	`
+	var marked uuid.UUID
+	if err := r.db.QueryRowxContext(ctx, query, id, errMsg, retryAt).Scan(&marked); err != nil {
+		return fmt.Errorf("mark notification failed: %w", err)
+	}
+	return nil
+}
+
+// MarkSuppressed moves the message to 'suppressed' with reason and releases
+// the lease.
+// It returns an error wrapping sql.ErrNoRows unless the message is pending.
+func (r *outboxRepo) MarkSuppressed(ctx context.Context, id uuid.UUID, reason string) error {
+	const query = `
+		UPDATE notification_outbox
+		SET status = 'suppressed', suppressed_reason = $2, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id
+	`
+	var marked uuid.UUID
+	if err := r.db.QueryRowxContext(ctx, query, id, reason).Scan(&marked); err != nil {
+		return fmt.Errorf("mark notification suppressed: %w", err)
+	}
+	return nil
+}
+
+// Postpone takes back the attempt Claim counted and makes the message due
+// at until.
+// It returns an error wrapping sql.ErrNoRows unless the message is pending.
+func (r *outboxRepo) Postpone(ctx context.Context, id uuid.UUID, until time.Time) error {
+	const query = `
+		UPDATE notification_outbox
+		SET attempts = GREATEST(attempts - 1, 0), next_attempt_at = $2,
+		    locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id
+	`
+	var marked uuid.UUID
+	if err := r.db.QueryRowxContext(ctx, query, id, until).Scan(&marked); err != nil {
+		return fmt.Errorf("postpone notification: %w", err)
+	}
+	return nil
+}
+
+// Release takes back the attempt Claim counted and ends the lease, unless
+// the message has been claimed again since.
+// It returns an error wrapping sql.ErrNoRows when it changed nothing.
+func (r *outboxRepo) Release(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error {
+	const query = `
+		UPDATE notification_outbox
+		SET attempts = GREATEST(attempts - 1, 0), locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND locked_until = $2
+		RETURNING id
+	`
+	var released uuid.UUID
+	if err := r.db.QueryRowxContext(ctx, query, id, lockedUntil).Scan(&released); err != nil {
+		return fmt.Errorf("release notification: %w", err)
+	}
+	return nil
+}
+
+// Requeue resets a dead message so it is claimed again right away.
+func (r *outboxRepo) Requeue(ctx context.Context, id uuid.UUID) (*db.OutboxMessage, error) {
+	const query = `
+		UPDATE notification_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(),
+		    locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING id, order_id, customer_id, event, channel, recipient, subject, body, html_body,
+		          status, attempts, last_error, next_attempt_at, locked_until, created_at,
+		          updated_at, sent_at, suppressed_reason
+	`
+	var m db.OutboxMessage
+	if err := r.db.GetContext(ctx, &m, query, id); err != nil {
+		return nil, fmt.Errorf("requeue notification: %w", err)
+	}
+	return &m, nil
+}
+
+// ListByOrder returns the messages of an order, oldest first.
+func (r *outboxRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]*db.OutboxMessage, error) {
+	const query = `
+		SELECT id, order_id, customer_id, event, channel, recipient, subject, body, html_body,
+		       status, attempts, last_error, next_attempt_at, locked_until, created_at,
+		       updated_at, sent_at, suppressed_reason
+		FROM notification_outbox
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`
+	var msgs []*db.OutboxMessage
+	if err := r.db.SelectContext(ctx, &msgs, query, orderID); err != nil {
+		return nil, fmt.Errorf("select notification_outbox by order: %w", err)
+	}
+	return msgs, nil
+}
+
+// ListByStatus returns messages in status, most recently updated first.
+func (r *outboxRepo) ListByStatus(ctx context.Context, status string, limit int) ([]*db.OutboxMessage, error) {
+	const query = `
+		SELECT id, order_id, customer_id, event, channel, recipient, subject, body, html_body,
+		       status, attempts, last_error, next_attempt_at, locked_until, created_at,
+		       updated_at, sent_at, suppressed_reason
+		FROM notification_outbox
+		WHERE status = $1
+		ORDER BY updated_at DESC, id DESC
+		LIMIT $2
+	`
+	var msgs []*db.OutboxMessage
+	if err := r.db.SelectContext(ctx, &msgs, query, status, limit); err != nil {
+		return nil, fmt.Errorf("select notification_outbox by status: %w", err)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,17 @@
+package postgres
+
+import "github.com/felixojiambo/go-graphql-order-service/internal/db"
+
+// afterArgs returns the created_at and id arguments of a keyset condition
+// written as
+//
+//	($n::timestamptz IS NULL OR (created_at, id) > ($n, $m))
+//
+// (with < for lists ordered newest first). Both are NULL when the page
+// starts at the beginning, which disables the condition.
+func afterArgs(page db.Page) (createdAt, id interface{}) {
+	if page.After == nil {
+		return nil, nil
+	}
+	return page.After.CreatedAt, page.After.ID
+}
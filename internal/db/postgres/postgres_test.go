@@ -0,0 +1,10 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/dbtest"
+)
+
+// TestPostgres skips unless TEST_DATABASE_URL is set; see dbtest.Postgres.
+func TestPostgres(t *testing.T) { dbtest.Run(t, dbtest.Postgres) }
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type preferenceRepo struct {
+	db *sqlx.DB
+}
+
+// NewNotificationPreferenceRepository returns a
+// db.NotificationPreferenceRepository backed by Postgres.
+func NewNotificationPreferenceRepository(db *sqlx.DB) db.NotificationPreferenceRepository {
+	return &preferenceRepo{db: db}
+}
+
+// preferenceRow scans a customer left joined with its preferences; the
+// preference columns are NULL when none were saved.
+type preferenceRow struct {
+	CustomerID     uuid.UUID      `db:"customer_id"`
+	Saved          bool           `db:"saved"`
+	Channels       pq.StringArray `db:"channels"`
+	OptedOutEvents pq.StringArray `db:"opted_out_events"`
+	QuietStart     *int           `db:"quiet_start"`
+	QuietEnd       *int           `db:"quiet_end"`
+	TimeZone       *string        `db:"time_zone"`
+	UpdatedAt      *time.Time     `db:"updated_at"`
+}
+
+// Get fetches the preferences of a customer, falling back to the defaults.
+func (r *preferenceRepo) Get(ctx context.Context, customerID uuid.UUID) (*db.NotificationPreferences, error) {
+	const query = `
+		SELECT c.id AS customer_id, p.customer_id IS NOT NULL AS saved,
+		       p.channels, p.opted_out_events, p.quiet_start, p.quiet_end,
+		       p.time_zone, p.updated_at
+		FROM customers c
+		LEFT JOIN notification_preferences p ON p.customer_id = c.id
+		WHERE c.id = $1
+	`
+	var row preferenceRow
+	if err := r.db.GetContext(ctx, &row, query, customerID); err != nil {
+		return nil, fmt.Errorf("select notification_preferences: %w", err)
+	}
+	if !row.Saved {
+		return db.DefaultNotificationPreferences(customerID), nil
+	}
+	return &db.NotificationPreferences{
+		CustomerID:     row.CustomerID,
+		Channels:       []string(row.Channels),
+		OptedOutEvents: []string(row.OptedOutEvents),
+		QuietStart:     row.QuietStart,
+		QuietEnd:       row.QuietEnd,
+		TimeZone:       *row.TimeZone,
+		UpdatedAt:      row.UpdatedAt,
+	}, nil
+}
+
+// Save upserts p and fills in UpdatedAt.
+func (r *preferenceRepo) Save(ctx context.Context, p *db.NotificationPreferences) error {
+	const query = `
+		INSERT INTO notification_preferences
+		    (customer_id, channels, opted_out_events, quiet_start, quiet_end, time_zone)
+		VALUES ($1, COALESCE($2, '{}'::text[]), COALESCE($3, '{}'::text[]), $4, $5, $6)
+		ON CONFLICT (customer_id) DO UPDATE
+		SET channels = EXCLUDED.channels,
+		    opted_out_events = EXCLUDED.opted_out_events,
+		    quiet_start = EXCLUDED.quiet_start,
+		    quiet_end = EXCLUDED.quiet_end,
+		    time_zone = EXCLUDED.time_zone,
+		    updated_at = NOW()
+		RETURNING updated_at
+	`
+	var updated time.Time
+	if err := r.db.QueryRowxContext(
+		ctx, query,
+		p.CustomerID, pq.Array(p.Channels), pq.Array(p.OptedOutEvents),
+		p.QuietStart, p.QuietEnd, p.TimeZone,
+	).Scan(&updated); err != nil {
+		return fmt.Errorf("upsert notification_preferences: %w", err)
+	}
+	p.UpdatedAt = &updated
+	return nil
+}
@@ -3,12 +3,15 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
 	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
 )
 
 type productRepo struct {
@@ -22,9 +25,9 @@ func NewProductRepository(db *sqlx.DB) db.ProductRepository {
 // Create inserts a new product.
 func (r *productRepo) Create(ctx context.Context, p *db.Product) error {
 	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO products (id,name,description,price,category_id)
-		 VALUES ($1,$2,$3,$4,$5)`,
-		p.ID, p.Name, p.Description, p.Price, p.CategoryID,
+		`INSERT INTO products (id,name,description,price,category_id,stock_quantity)
+		 VALUES ($1,$2,$3,$4,$5,$6)`,
+		p.ID, p.Name, p.Description, p.Price, p.CategoryID, p.Stock,
 	)
 	return err
 }
@@ -33,16 +36,82 @@ func (r *productRepo) Create(ctx context.Context, p *db.Product) error {
 func (r *productRepo) GetByID(ctx context.Context, id uuid.UUID) (*db.Product, error) {
 	var p db.Product
 	if err := r.db.GetContext(ctx, &p,
-		`SELECT id,name,description,price,category_id FROM products WHERE id=$1`, id,
+		`SELECT id,name,description,price,category_id,stock_quantity,created_at,updated_at,archived_at FROM products WHERE id=$1`, id,
 	); err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-// ListByCategory returns all products in a category subtree.
-func (r *productRepo) ListByCategory(ctx context.Context, categoryID uuid.UUID) ([]*db.Product, error) {
-	// for simplicity assume subtree logic in SQL
+// GetByIDs fetches the products with the given IDs.
+func (r *productRepo) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*db.Product, error) {
+	var out []*db.Product
+	if err := r.db.SelectContext(ctx, &out,
+		`SELECT id,name,description,price,category_id,stock_quantity,created_at,updated_at,archived_at FROM products WHERE id = ANY($1)`, pq.Array(ids),
+	); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Update writes the editable columns of a product and bumps updated_at.
+// It returns an error wrapping sql.ErrNoRows if the product does not exist.
+func (r *productRepo) Update(ctx context.Context, p *db.Product) error {
+	const query = `
+		UPDATE products
+		SET name = $2, description = $3, price = $4, category_id = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	if err := r.db.QueryRowxContext(ctx, query, p.ID, p.Name, p.Description, p.Price, p.CategoryID).
+		Scan(&p.UpdatedAt); err != nil {
+		return fmt.Errorf("update product: %w", err)
+	}
+	return nil
+}
+
+// Archive sets archived_at unless the product is already archived.
+// It returns an error wrapping sql.ErrNoRows if the product does not exist.
+func (r *productRepo) Archive(ctx context.Context, id uuid.UUID) (*db.Product, error) {
+	var p db.Product
+	const query = `
+		UPDATE products
+		SET archived_at = COALESCE(archived_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, name, description, price, category_id, stock_quantity, created_at, updated_at, archived_at
+	`
+	if err := r.db.GetContext(ctx, &p, query, id); err != nil {
+		return nil, fmt.Errorf("archive product: %w", err)
+	}
+	return &p, nil
+}
+
+// Delete removes a product; its stock adjustments cascade. Order items
+// referencing it make the foreign key fail, which is reported as
+// db.ErrProductInUse.
+func (r *productRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM products WHERE id = $1`, id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" { // foreign_key_violation
+		return db.ErrProductInUse
+	}
+	if err != nil {
+		return fmt.Errorf("delete product: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete product: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("delete product: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// ListByCategory returns one page of the unarchived products in a category
+// subtree.
+func (r *productRepo) ListByCategory(ctx context.Context, categoryID uuid.UUID, page db.Page) ([]*db.Product, error) {
+	createdAt, id := afterArgs(page)
 	rows, err := r.db.QueryxContext(ctx, `
 WITH RECURSIVE ch(id) AS (
     SELECT id FROM categories WHERE id = $1
@@ -50,10 +119,14 @@ WITH RECURSIVE ch(id) AS (
     SELECT c.id FROM categories c
     JOIN ch ON c.parent_id = ch.id
 )
-SELECT p.id,p.name,p.description,p.price,p.category_id
+SELECT p.id,p.name,p.description,p.price,p.category_id,p.stock_quantity,p.created_at,p.updated_at,p.archived_at
   FROM products p
   JOIN ch ON p.category_id = ch.id
-`, categoryID)
+ WHERE p.archived_at IS NULL
+   AND ($2::timestamptz IS NULL OR (p.created_at, p.id) > ($2, $3))
+ ORDER BY p.created_at, p.id
+ LIMIT $4
+`, categoryID, createdAt, id, page.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -67,12 +140,64 @@ SELECT p.id,p.name,p.description,p.price,p.category_id
 		}
 		out = append(out, &p)
 	}
+	return out, rows.Err()
+}
+
+// CountByCategory counts the unarchived products in the same subtree.
+func (r *productRepo) CountByCategory(ctx context.Context, categoryID uuid.UUID) (int, error) {
+	var n int
+	query := `
+WITH RECURSIVE ch(id) AS (
+    SELECT id FROM categories WHERE id = $1
+  UNION ALL
+    SELECT c.id FROM categories c
+    JOIN ch ON c.parent_id = ch.id
+)
+SELECT COUNT(*) FROM products p
+  JOIN ch ON p.category_id = ch.id
+ WHERE p.archived_at IS NULL;
+`
+	if err := r.db.GetContext(ctx, &n, query, categoryID); err != nil {
+		return 0, fmt.Errorf("count query: %w", err)
+	}
+	return n, nil
+}
+
+// CountByCategories counts the unarchived products under each category
+// with one recursive query that remembers which category each walk started
+// from.
+func (r *productRepo) CountByCategories(ctx context.Context, categoryIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	const query = `
+WITH RECURSIVE ch(origin, id) AS (
+    SELECT id, id FROM categories WHERE id = ANY($1)
+  UNION ALL
+    SELECT ch.origin, c.id FROM categories c
+    JOIN ch ON c.parent_id = ch.id
+)
+SELECT ch.origin, COUNT(p.id) AS n
+  FROM ch
+  LEFT JOIN products p ON p.category_id = ch.id AND p.archived_at IS NULL
+ GROUP BY ch.origin
+`
+	var rows []struct {
+		Origin uuid.UUID `db:"origin"`
+		N      int       `db:"n"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(categoryIDs)); err != nil {
+		return nil, fmt.Errorf("count query: %w", err)
+	}
+	out := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		out[row.Origin] = row.N
+	}
 	return out, nil
 }
 
-// AveragePriceByCategory computes the average price over the same subtree.
-func (r *productRepo) AveragePriceByCategory(ctx context.Context, categoryID uuid.UUID) (float64, error) {
-	var avg sql.NullFloat64
+// AveragePriceByCategory computes the average price of the unarchived
+// products in the same subtree.
+// The mean is rounded to cents in SQL so it scans exactly into money.Money.
+func (r *productRepo) AveragePriceByCategory(ctx context.Context, categoryID uuid.UUID) (money.Money, error) {
+	var avg money.NullMoney
 	query := `
 WITH RECURSIVE ch(id) AS (
     SELECT id FROM categories WHERE id = $1
@@ -80,14 +205,112 @@ WITH RECURSIVE ch(id) AS (
     SELECT c.id FROM categories c
     JOIN ch ON c.parent_id = ch.id
 )
-SELECT AVG(p.price) FROM products p
-  JOIN ch ON p.category_id = ch.id;
+SELECT ROUND(AVG(p.price), 2) FROM products p
+  JOIN ch ON p.category_id = ch.id
+ WHERE p.archived_at IS NULL;
 `
 	if err := r.db.GetContext(ctx, &avg, query, categoryID); err != nil {
-		return 0, fmt.Errorf("avg query: %w", err)
+		return money.Money{}, fmt.Errorf("avg query: %w", err)
 	}
 	if !avg.Valid {
-		return 0, nil
+		return money.Zero(money.DefaultCurrency), nil
+	}
+	return avg.Money, nil
+}
+
+// PriceStatsByCategory reads the summary and the histogram in one
+// repeatable-read transaction so both describe the same set of products.
+// Percentiles interpolate between the two nearest prices like
+// percentile_cont, but in NUMERIC so they round exactly.
+func (r *productRepo) PriceStatsByCategory(ctx context.Context, categoryID uuid.UUID, subtree bool, buckets int) (*db.PriceStats, error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// ch stops at the category itself unless subtree is set
+	const prices = `
+WITH RECURSIVE ch(id) AS (
+    SELECT id FROM categories WHERE id = $1
+  UNION ALL
+    SELECT c.id FROM categories c
+    JOIN ch ON c.parent_id = ch.id
+   WHERE $2::boolean
+),
+prices AS (
+    SELECT p.price FROM products p
+      JOIN ch ON p.category_id = ch.id
+     WHERE p.archived_at IS NULL
+)`
+	const summary = prices + `,
+ranked AS (
+    SELECT price, row_number() OVER (ORDER BY price) - 1 AS i, COUNT(*) OVER () AS n
+      FROM prices
+),
+percentiles AS (
+    SELECT f.f, ROUND(SUM(r.price * CASE r.i - FLOOR(f.f * (r.n - 1))
+                WHEN 0 THEN 1 - (f.f * (r.n - 1) - FLOOR(f.f * (r.n - 1)))
+                WHEN 1 THEN f.f * (r.n - 1) - FLOOR(f.f * (r.n - 1))
+                ELSE 0
+            END), 2) AS v
+      FROM ranked r
+     CROSS JOIN (VALUES (0.5), (0.9)) AS f(f)
+     GROUP BY f.f
+)
+SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1) AS found,
+       COUNT(*) AS count,
+       MIN(price) AS min,
+       MAX(price) AS max,
+       ROUND(AVG(price), 2) AS mean,
+       (SELECT v FROM percentiles WHERE f = 0.5) AS median,
+       (SELECT v FROM percentiles WHERE f = 0.9) AS p90,
+       ROUND(STDDEV_POP(price), 2) AS stddev
+  FROM prices
+`
+	var row struct {
+		Found  bool            `db:"found"`
+		Count  int             `db:"count"`
+		Min    money.NullMoney `db:"min"`
+		Max    money.NullMoney `db:"max"`
+		Mean   money.NullMoney `db:"mean"`
+		Median money.NullMoney `db:"median"`
+		P90    money.NullMoney `db:"p90"`
+		StdDev money.NullMoney `db:"stddev"`
+	}
+	if err := tx.GetContext(ctx, &row, summary, categoryID, subtree); err != nil {
+		return nil, fmt.Errorf("price stats query: %w", err)
+	}
+	if !row.Found {
+		return nil, fmt.Errorf("price stats: %w", sql.ErrNoRows)
+	}
+	stats := &db.PriceStats{Count: row.Count}
+	if row.Count == 0 {
+		return stats, nil
+	}
+	stats.Min, stats.Max, stats.Mean = &row.Min.Money, &row.Max.Money, &row.Mean.Money
+	stats.Median, stats.P90, stats.StdDev = &row.Median.Money, &row.P90.Money, &row.StdDev.Money
+
+	stats.Histogram = db.NewPriceHistogram(*stats.Min, *stats.Max, buckets)
+	lowers := make([]string, len(stats.Histogram))
+	for i, b := range stats.Histogram {
+		lowers[i] = b.Min.Decimal()
+	}
+	// width_bucket numbers the buckets from 1 by their lower bounds
+	const histogram = prices + `
+SELECT width_bucket(price, $3::numeric[]) AS bucket, COUNT(*) AS n
+  FROM prices
+ GROUP BY 1
+`
+	var counts []struct {
+		Bucket int `db:"bucket"`
+		N      int `db:"n"`
+	}
+	if err := tx.SelectContext(ctx, &counts, histogram, categoryID, subtree, pq.Array(lowers)); err != nil {
+		return nil, fmt.Errorf("price histogram query: %w", err)
+	}
+	for _, c := range counts {
+		stats.Histogram[c.Bucket-1].Count = c.N
 	}
-	return avg.Float64, nil
+	return stats, nil
 }
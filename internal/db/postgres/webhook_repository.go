@@ -0,0 +1,328 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+type webhookRepo struct {
+	db *sqlx.DB
+}
+
+// NewWebhookRepository returns a db.WebhookRepository backed by Postgres.
+func NewWebhookRepository(db *sqlx.DB) db.WebhookRepository {
+	return &webhookRepo{db: db}
+}
+
+// subscriptionRow scans event_types, which sqlx cannot put in a []string.
+type subscriptionRow struct {
+	ID         uuid.UUID      `db:"id"`
+	URL        string         `db:"url"`
+	Secret     string         `db:"secret"`
+	EventTypes pq.StringArray `db:"event_types"`
+	Active     bool           `db:"active"`
+	CreatedAt  time.Time      `db:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at"`
+}
+
+func (row subscriptionRow) subscription() *db.WebhookSubscription {
+	return &db.WebhookSubscription{
+		ID:         row.ID,
+		URL:        row.URL,
+		Secret:     row.Secret,
+		EventTypes: []string(row.EventTypes),
+		Active:     row.Active,
+		CreatedAt:  row.CreatedAt,
+		UpdatedAt:  row.UpdatedAt,
+	}
+}
+
+// CreateSubscription inserts s and fills in its timestamps.
+func (r *webhookRepo) CreateSubscription(ctx context.Context, s *db.WebhookSubscription) error {
+	const query = `
+		INSERT INTO webhook_subscriptions (id, url, secret, event_types, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at
+	`
+	if err := r.db.QueryRowxContext(ctx, query, s.ID, s.URL, s.Secret, pq.Array(s.EventTypes), s.Active).
+		Scan(&s.CreatedAt, &s.UpdatedAt); err != nil {
+		return fmt.Errorf("insert webhook_subscription: %w", err)
+	}
+	return nil
+}
+
+// GetSubscription fetches one subscription.
+func (r *webhookRepo) GetSubscription(ctx context.Context, id uuid.UUID) (*db.WebhookSubscription, error) {
+	const query = `
+		SELECT id, url, secret, event_types, active, created_at, updated_at
+		FROM webhook_subscriptions
+		WHERE id = $1
+	`
+	var row subscriptionRow
+	if err := r.db.GetContext(ctx, &row, query, id); err != nil {
+		return nil, fmt.Errorf("select webhook_subscription: %w", err)
+	}
+	return row.subscription(), nil
+}
+
+// ListSubscriptions returns every subscription, oldest first.
+func (r *webhookRepo) ListSubscriptions(ctx context.Context) ([]*db.WebhookSubscription, error) {
+	const query = `
+		SELECT id, url, secret, event_types, active, created_at, updated_at
+		FROM webhook_subscriptions
+		ORDER BY created_at, id
+	`
+	var rows []subscriptionRow
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, fmt.Errorf("select webhook_subscriptions: %w", err)
+	}
+	out := make([]*db.WebhookSubscription, len(rows))
+	for i, row := range rows {
+		out[i] = row.subscription()
+	}
+	return out, nil
+}
+
+// UpdateSubscription stores the mutable fields of s.
+func (r *webhookRepo) UpdateSubscription(ctx context.Context, s *db.WebhookSubscription) error {
+	const query = `
+		UPDATE webhook_subscriptions
+		SET url = $2, secret = $3, event_types = $4, active = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`
+	if err := r.db.QueryRowxContext(ctx, query, s.ID, s.URL, s.Secret, pq.Array(s.EventTypes), s.Active).
+		Scan(&s.CreatedAt, &s.UpdatedAt); err != nil {
+		return fmt.Errorf("update webhook_subscription: %w", err)
+	}
+	return nil
+}
+
+// DeactivateSubscription clears the active flag of a subscription.
+func (r *webhookRepo) DeactivateSubscription(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_subscriptions SET active = FALSE, updated_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deactivate webhook_subscription: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("deactivate webhook_subscription: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// DeleteSubscription removes a subscription; its deliveries and attempts
+// go with it through ON DELETE CASCADE.
+func (r *webhookRepo) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook_subscription: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("delete webhook_subscription: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// insertWebhookDeliveries fans events out within tx to the active
+// subscriptions of their types.
+func insertWebhookDeliveries(ctx context.Context, tx *sqlx.Tx, events []*db.WebhookEvent) error {
+	const insert = `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, order_id, payload)
+		SELECT id, $1, $2, $3, $4
+		FROM webhook_subscriptions
+		WHERE active AND $2 = ANY(event_types)
+	`
+	for _, e := range events {
+		// as a string: lib/pq would send []byte as bytea
+		if _, err := tx.ExecContext(ctx, insert, e.ID, e.Type, e.OrderID, string(e.Payload)); err != nil {
+			return fmt.Errorf("insert webhook_deliveries: %w", err)
+		}
+	}
+	return nil
+}
+
+// Claim leases due deliveries with FOR UPDATE SKIP LOCKED, so concurrent
+// dispatchers claim disjoint batches.
+func (r *webhookRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]*db.WebhookDelivery, error) {
+	const query = `
+		UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1,
+		    locked_until = NOW() + make_interval(secs => $2),
+		    updated_at = NOW()
+		FROM (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			  AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE d.id = due.id
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.order_id, d.payload,
+		          d.status, d.attempts, d.last_error, d.next_attempt_at,
+		          d.locked_until, d.created_at, d.updated_at, d.delivered_at
+	`
+	var ds []*db.WebhookDelivery
+	if err := r.db.SelectContext(ctx, &ds, query, limit, lease.Seconds()); err != nil {
+		return nil, fmt.Errorf("claim webhook_deliveries: %w", err)
+	}
+	// RETURNING does not keep the subquery's order
+	sort.Slice(ds, func(i, j int) bool {
+		if !ds[i].NextAttemptAt.Equal(ds[j].NextAttemptAt) {
+			return ds[i].NextAttemptAt.Before(ds[j].NextAttemptAt)
+		}
+		return ds[i].ID.String() < ds[j].ID.String()
+	})
+	return ds, nil
+}
+
+// MarkDelivered logs a and marks its delivery delivered.
+// It returns an error wrapping sql.ErrNoRows unless the delivery is pending.
+func (r *webhookRepo) MarkDelivered(ctx context.Context, a *db.WebhookAttempt) error {
+	const query = `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', delivered_at = NOW(), locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id
+	`
+	return r.recordAttempt(ctx, a, "mark webhook delivered", query, a.DeliveryID)
+}
+
+// MarkFailed logs a and either schedules the next attempt or moves the
+// delivery to 'dead'.
+// It returns an error wrapping sql.ErrNoRows unless the delivery is pending.
+func (r *webhookRepo) MarkFailed(ctx context.Context, a *db.WebhookAttempt, retryAt *time.Time) error {
+	const query = `
+		UPDATE webhook_deliveries
+		SET status = CASE WHEN $3::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+		    next_attempt_at = COALESCE($3, next_attempt_at),
+		    last_error = $2, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id
+	`
+	return r.recordAttempt(ctx, a, "mark webhook failed", query, a.DeliveryID, a.Error, retryAt)
+}
+
+// Release takes back the attempt Claim counted and ends the lease, unless
+// the delivery has been claimed again since.
+// It returns an error wrapping sql.ErrNoRows when it changed nothing.
+func (r *webhookRepo) Release(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error {
+	const query = `
+		UPDATE webhook_deliveries
+		SET attempts = GREATEST(attempts - 1, 0), locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND locked_until = $2
+		RETURNING id
+	`
+	var released uuid.UUID
+	if err := r.db.QueryRowxContext(ctx, query, id, lockedUntil).Scan(&released); err != nil {
+		return fmt.Errorf("release webhook: %w", err)
+	}
+	return nil
+}
+
+// recordAttempt runs the delivery update and inserts a in one transaction.
+func (r *webhookRepo) recordAttempt(ctx context.Context, a *db.WebhookAttempt, op, update string, args ...interface{}) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var marked uuid.UUID
+	if err := tx.QueryRowxContext(ctx, update, args...).Scan(&marked); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	const insert = `
+		INSERT INTO webhook_attempts (id, delivery_id, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if err := tx.QueryRowxContext(ctx, insert, a.ID, a.DeliveryID, a.StatusCode, a.Error, a.DurationMS).
+		Scan(&a.CreatedAt); err != nil {
+		return fmt.Errorf("insert webhook_attempt: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Redeliver resets a dead delivery so it is claimed again right away.
+func (r *webhookRepo) Redeliver(ctx context.Context, id uuid.UUID) (*db.WebhookDelivery, error) {
+	const query = `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(),
+		    locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING id, subscription_id, event_id, event_type, order_id, payload, status, attempts,
+		          last_error, next_attempt_at, locked_until, created_at, updated_at, delivered_at
+	`
+	var d db.WebhookDelivery
+	if err := r.db.GetContext(ctx, &d, query, id); err != nil {
+		return nil, fmt.Errorf("redeliver webhook: %w", err)
+	}
+	return &d, nil
+}
+
+// GetDelivery fetches one delivery.
+func (r *webhookRepo) GetDelivery(ctx context.Context, id uuid.UUID) (*db.WebhookDelivery, error) {
+	const query = `
+		SELECT id, subscription_id, event_id, event_type, order_id, payload, status, attempts,
+		       last_error, next_attempt_at, locked_until, created_at, updated_at, delivered_at
+		FROM webhook_deliveries
+		WHERE id = $1
+	`
+	var d db.WebhookDelivery
+	if err := r.db.GetContext(ctx, &d, query, id); err != nil {
+		return nil, fmt.Errorf("select webhook_delivery: %w", err)
+	}
+	return &d, nil
+}
+
+// ListDeliveries returns a subscription's deliveries, newest first.
+func (r *webhookRepo) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status *string, limit int) ([]*db.WebhookDelivery, error) {
+	const query = `
+		SELECT id, subscription_id, event_id, event_type, order_id, payload, status, attempts,
+		       last_error, next_attempt_at, locked_until, created_at, updated_at, delivered_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2::text IS NULL OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`
+	var ds []*db.WebhookDelivery
+	if err := r.db.SelectContext(ctx, &ds, query, subscriptionID, status, limit); err != nil {
+		return nil, fmt.Errorf("select webhook_deliveries: %w", err)
+	}
+	return ds, nil
+}
+
+// ListAttempts returns the attempts of a delivery, oldest first.
+func (r *webhookRepo) ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]*db.WebhookAttempt, error) {
+	const query = `
+		SELECT id, delivery_id, status_code, error, duration_ms, created_at
+		FROM webhook_attempts
+		WHERE delivery_id = $1
+		ORDER BY created_at, id
+	`
+	var as []*db.WebhookAttempt
+	if err := r.db.SelectContext(ctx, &as, query, deliveryID); err != nil {
+		return nil, fmt.Errorf("select webhook_attempts: %w", err)
+	}
+	return as, nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import "github.com/felixojiambo/go-graphql-order-service/internal/money"
+
+// PriceStats summarizes the prices of the unarchived products in a
+// category. The amounts are rounded to cents and are nil when Count is 0,
+// so an empty category is not mistaken for one of free items.
+type PriceStats struct {
+	Count     int
+	Min       *money.Money
+	Max       *money.Money
+	Mean      *money.Money
+	Median    *money.Money
+	P90       *money.Money // 90th percentile, interpolated like Median
+	StdDev    *money.Money // population standard deviation
+	Histogram []PriceBucket
+}
+
+// PriceBucket counts the prices from Min up to but excluding Max. The last
+// bucket of a histogram also holds prices equal to its Max.
+type PriceBucket struct {
+	Min   money.Money
+	Max   money.Money
+	Count int
+}
+
+// NewPriceHistogram returns up to n empty buckets of equal width in whole
+// cents covering [min, max]. Fewer are returned when the range holds fewer
+// than n distinct cent values, so that no bucket has zero width; buckets
+// may still receive no prices.
+func NewPriceHistogram(min, max money.Money, n int) []PriceBucket {
+	span := max.Amount - min.Amount
+	var out []PriceBucket
+	for i := 0; i < n; i++ {
+		lower := min.Amount + span*int64(i)/int64(n)
+		if len(out) > 0 && lower == out[len(out)-1].Min.Amount {
+			continue
+		}
+		if len(out) > 0 {
+			out[len(out)-1].Max = money.New(lower, min.Currency)
+		}
+		out = append(out, PriceBucket{Min: money.New(lower, min.Currency)})
+	}
+	out[len(out)-1].Max = max
+	return out
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// QueryCounter counts the database queries run on behalf of one request.
+type QueryCounter struct {
+	n atomic.Int64
+}
+
+// Count returns the number of queries counted so far.
+func (c *QueryCounter) Count() int64 {
+	return c.n.Load()
+}
+
+type queryCounterCtx struct{}
+
+// WithQueryCounter returns a context that counts the queries run with it and
+// the counter that receives them.
+func WithQueryCounter(ctx context.Context) (context.Context, *QueryCounter) {
+	c := &QueryCounter{}
+	return context.WithValue(ctx, queryCounterCtx{}, c), c
+}
+
+// CountQuery records one query against the counter in ctx, if any.
+// Backends call it for every statement they send.
+func CountQuery(ctx context.Context) {
+	if c, ok := ctx.Value(queryCounterCtx{}).(*QueryCounter); ok {
+		c.n.Add(1)
+	}
+}
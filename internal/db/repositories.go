@@ -2,37 +2,284 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
 )
 
 // CustomerRepository defines CRUD over customers.
 type CustomerRepository interface {
 	Create(ctx context.Context, c *Customer) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Customer, error)
-	List(ctx context.Context, limit, offset int) ([]*Customer, error)
+	GetByEmail(ctx context.Context, email string) (*Customer, error)
+	GetByFirebaseUID(ctx context.Context, uid string) (*Customer, error)
+
+	// List returns one page of customers, oldest first.
+	List(ctx context.Context, page Page) ([]*Customer, error)
+	Count(ctx context.Context) (int, error)
+
+	// Update writes name, email, phone and locale of an existing customer.
+	Update(ctx context.Context, c *Customer) error
+
+	// LinkFirebaseUID binds a Firebase identity to a customer that is not
+	// linked yet.
+	LinkFirebaseUID(ctx context.Context, id uuid.UUID, uid string) error
 }
 
 // CategoryRepository encapsulates category persistence.
 type CategoryRepository interface {
 	Create(ctx context.Context, c *Category) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Category, error)
-	ListChildren(ctx context.Context, parentID *uuid.UUID) ([]*Category, error)
+
+	// ListChildren returns one page of the direct children of parentID, or of
+	// the root categories when parentID is nil, oldest first.
+	ListChildren(ctx context.Context, parentID *uuid.UUID, page Page) ([]*Category, error)
+	CountChildren(ctx context.Context, parentID *uuid.UUID) (int, error)
+
+	// GetByIDs fetches the categories with the given IDs in one query.
+	// Unknown IDs are skipped; the order of the result is unspecified.
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Category, error)
+
+	// ListChildrenOf returns the direct children of all parentIDs in one
+	// query, oldest first within each parent.
+	ListChildrenOf(ctx context.Context, parentIDs []uuid.UUID) ([]*Category, error)
+
+	// Tree returns the subtree under rootID, or every tree when rootID is
+	// nil, in depth-first order with siblings oldest first. Categories deeper
+	// than maxDepth below the root are left out; a negative maxDepth means
+	// no limit. An unknown rootID yields an empty slice.
+	Tree(ctx context.Context, rootID *uuid.UUID, maxDepth int) ([]*CategoryTreeNode, error)
+
+	// AncestorsOf returns the ancestors of each of ids, root first and
+	// without the category itself. Roots and unknown IDs are left out of
+	// the map.
+	AncestorsOf(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]*Category, error)
+
+	// Rename sets a category's name and returns the category.
+	Rename(ctx context.Context, id uuid.UUID, name string) (*Category, error)
+
+	// Move makes parentID the parent of category id, or makes it a root when
+	// parentID is nil, and returns the category. It returns ErrCategoryCycle
+	// if parentID is id or one of its descendants.
+	Move(ctx context.Context, id uuid.UUID, parentID *uuid.UUID) (*Category, error)
+
+	// Delete removes a category, handling its subcategories and products as
+	// strategy says. It returns ErrCategoryInUse when strategy does not
+	// allow the delete and ErrProductInUse when a product to be deleted is
+	// referenced by orders.
+	Delete(ctx context.Context, id uuid.UUID, strategy CategoryDeleteStrategy) error
 }
 
 // ProductRepository handles products.
 type ProductRepository interface {
 	Create(ctx context.Context, p *Product) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Product, error)
-	ListByCategory(ctx context.Context, categoryID uuid.UUID) ([]*Product, error)
 
-	// compute the average price of all products in the subtree of categoryID.
-	AveragePriceByCategory(ctx context.Context, categoryID uuid.UUID) (float64, error)
+	// GetByIDs fetches the products with the given IDs in one query.
+	// Unknown IDs are skipped; the order of the result is unspecified.
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Product, error)
+
+	// ListByCategory returns one page of the unarchived products in the
+	// subtree of categoryID, oldest first.
+	ListByCategory(ctx context.Context, categoryID uuid.UUID, page Page) ([]*Product, error)
+	CountByCategory(ctx context.Context, categoryID uuid.UUID) (int, error)
+
+	// CountByCategories does CountByCategory for each of categoryIDs in one
+	// query. Unknown IDs are left out of the map.
+	CountByCategories(ctx context.Context, categoryIDs []uuid.UUID) (map[uuid.UUID]int, error)
+
+	// Update writes name, description, price and category of an existing
+	// product and bumps updated_at. Stock is changed through
+	// InventoryRepository only.
+	Update(ctx context.Context, p *Product) error
+
+	// Archive sets archived_at on a product, keeping an earlier archival
+	// time, and returns the product.
+	Archive(ctx context.Context, id uuid.UUID) (*Product, error)
+
+	// Delete removes a product and its stock history. It returns
+	// ErrProductInUse while any order item references the product.
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// compute the average price of all products in the subtree of categoryID,
+	// rounded to whole minor units. Archived products are left out.
+	AveragePriceByCategory(ctx context.Context, categoryID uuid.UUID) (money.Money, error)
+
+	// PriceStatsByCategory summarizes the prices of the unarchived products
+	// in categoryID, or in its whole subtree when subtree is true, with a
+	// histogram of at most buckets buckets. It returns an error wrapping
+	// sql.ErrNoRows if the category does not exist.
+	PriceStatsByCategory(ctx context.Context, categoryID uuid.UUID, subtree bool, buckets int) (*PriceStats, error)
 }
 
 // OrderRepository manages orders and items.
 type OrderRepository interface {
-	CreateOrder(ctx context.Context, o *Order, items []*OrderItem) error
+	// CreateOrder inserts the order and its items and reserves stock for them
+	// atomically. If any product is short it returns *InsufficientStockError
+	// and nothing is written.
+	//
+	// When key is non-nil it is stored with the order. If the customer already
+	// holds the same unexpired key, nothing is written and
+	// *IdempotencyReplayError is returned.
+	//
+	// The outbox messages and webhook events are enqueued in the same
+	// transaction, so they are delivered if and only if the order is stored.
+	CreateOrder(ctx context.Context, o *Order, items []*OrderItem, key *IdempotencyKey, outbox []*OutboxMessage, events []*WebhookEvent) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Order, []*OrderItem, error)
-	ListByCustomer(ctx context.Context, customerID uuid.UUID) ([]*Order, error)
+
+	// GetIdempotencyKey returns the unexpired key a customer stored with an
+	// earlier order. It returns an error wrapping sql.ErrNoRows if there is
+	// none.
+	GetIdempotencyKey(ctx context.Context, customerID uuid.UUID, key string) (*IdempotencyKey, error)
+
+	// ListByCustomer returns one page of a customer's orders, newest first;
+	// page.After continues towards older orders.
+	ListByCustomer(ctx context.Context, customerID uuid.UUID, page Page) ([]*Order, error)
+	CountByCustomer(ctx context.Context, customerID uuid.UUID) (int, error)
+
+	// UpdateStatus moves an order from one status to another, records the
+	// transition and enqueues the webhook events. It returns
+	// ErrStatusConflict if the order is no longer in from, and
+	// ErrCancelViaUpdateStatus if to is 'cancelled'.
+	UpdateStatus(ctx context.Context, id uuid.UUID, from, to, actorUID string, events []*WebhookEvent) error
+	ListStatusHistory(ctx context.Context, orderID uuid.UUID) ([]*OrderStatusChange, error)
+
+	// CancelOrder moves an order from status from to 'cancelled', storing the
+	// reason code and optional note, and releases the order's reserved stock.
+	// Like UpdateStatus it returns ErrStatusConflict if the order is no longer
+	// in from. The outbox messages and webhook events are enqueued in the
+	// same transaction.
+	CancelOrder(ctx context.Context, id uuid.UUID, from, actorUID, reason string, note *string, outbox []*OutboxMessage, events []*WebhookEvent) error
+}
+
+// InventoryRepository manages product stock levels and their audit trail.
+type InventoryRepository interface {
+	// AdjustStock adds delta (which may be negative) to a product's stock and
+	// records the adjustment. It returns *InsufficientStockError if the stock
+	// would drop below zero.
+	AdjustStock(ctx context.Context, productID uuid.UUID, delta int, reason, actorUID string) (*StockAdjustment, error)
+
+	// StockLevels returns the given products, or every product when
+	// productIDs is empty, ordered by ascending stock.
+	StockLevels(ctx context.Context, productIDs []uuid.UUID) ([]*Product, error)
+
+	// ListAdjustments returns a product's most recent adjustments first.
+	ListAdjustments(ctx context.Context, productID uuid.UUID, limit int) ([]*StockAdjustment, error)
+}
+
+// OutboxRepository stores notifications until the outbox dispatcher has
+// delivered them. A message is claimed for a lease; if it is neither marked
+// sent nor failed before the lease ends, it is claimed again.
+type OutboxRepository interface {
+	// Enqueue stores new pending messages, due immediately unless their
+	// NextAttemptAt is set.
+	Enqueue(ctx context.Context, msgs ...*OutboxMessage) error
+
+	// Claim leases up to limit due pending messages, earliest due first, and
+	// counts the attempt. Messages leased by another caller are skipped.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxMessage, error)
+
+	// MarkSent records a successful delivery.
+	MarkSent(ctx context.Context, id uuid.UUID) error
+
+	// MarkFailed records a failed attempt. The message is retried at retryAt,
+	// or moves to the dead-letter state when retryAt is nil.
+	MarkFailed(ctx context.Context, id uuid.UUID, errMsg string, retryAt *time.Time) error
+
+	// MarkSuppressed records that the recipient's preferences ruled the
+	// message out; it is not sent.
+	MarkSuppressed(ctx context.Context, id uuid.UUID, reason string) error
+
+	// Postpone releases a claimed message until until without counting the
+	// attempt, e.g. to wait out quiet hours.
+	Postpone(ctx context.Context, id uuid.UUID, until time.Time) error
+
+	// Release hands back a claimed message that was not sent, without
+	// counting the attempt, so it can be claimed again right away.
+	// lockedUntil is the LockedUntil Claim returned; once another caller
+	// has claimed the message, Release returns an error wrapping
+	// sql.ErrNoRows and changes nothing.
+	Release(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error
+
+	// Requeue makes a dead message pending again with a fresh attempt count.
+	// It returns an error wrapping sql.ErrNoRows unless the message is dead.
+	Requeue(ctx context.Context, id uuid.UUID) (*OutboxMessage, error)
+
+	// ListByOrder returns the messages of an order, oldest first.
+	ListByOrder(ctx context.Context, orderID uuid.UUID) ([]*OutboxMessage, error)
+
+	// ListByStatus returns up to limit messages in status, most recently
+	// updated first.
+	ListByStatus(ctx context.Context, status string, limit int) ([]*OutboxMessage, error)
+}
+
+// NotificationPreferenceRepository stores what customers want to be
+// notified about.
+type NotificationPreferenceRepository interface {
+	// Get returns the preferences of a customer, or
+	// DefaultNotificationPreferences when none were saved. It returns an
+	// error wrapping sql.ErrNoRows for an unknown customer.
+	Get(ctx context.Context, customerID uuid.UUID) (*NotificationPreferences, error)
+
+	// Save replaces the preferences of p.CustomerID and sets p.UpdatedAt.
+	Save(ctx context.Context, p *NotificationPreferences) error
+}
+
+// WebhookRepository stores webhook subscriptions and the deliveries of
+// order events to them. Deliveries are created by OrderRepository writes,
+// one per event and active subscription of its type, and are claimed for a
+// lease like outbox messages.
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, s *WebhookSubscription) error
+	GetSubscription(ctx context.Context, id uuid.UUID) (*WebhookSubscription, error)
+
+	// ListSubscriptions returns every subscription, oldest first.
+	ListSubscriptions(ctx context.Context) ([]*WebhookSubscription, error)
+
+	// UpdateSubscription stores the URL, secret, event types and active flag
+	// of s. It returns an error wrapping sql.ErrNoRows if s does not exist.
+	UpdateSubscription(ctx context.Context, s *WebhookSubscription) error
+
+	// DeactivateSubscription stops new deliveries to a subscription, e.g.
+	// after its endpoint answered 410 Gone, leaving its other fields alone.
+	// It returns an error wrapping sql.ErrNoRows if it does not exist.
+	DeactivateSubscription(ctx context.Context, id uuid.UUID) error
+
+	// DeleteSubscription removes a subscription with its deliveries and
+	// their attempts. It returns an error wrapping sql.ErrNoRows if it does
+	// not exist.
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// Claim leases up to limit due pending deliveries, earliest due first,
+	// and counts the attempt. Deliveries leased by another caller are skipped.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error)
+
+	// MarkDelivered logs the successful attempt a and marks its delivery
+	// delivered.
+	MarkDelivered(ctx context.Context, a *WebhookAttempt) error
+
+	// MarkFailed logs the failed attempt a. The delivery is retried at
+	// retryAt, or moves to the dead-letter state when retryAt is nil.
+	MarkFailed(ctx context.Context, a *WebhookAttempt, retryAt *time.Time) error
+
+	// Release hands back a claimed delivery that was not sent, without
+	// counting the attempt or logging one. lockedUntil is the LockedUntil
+	// Claim returned; once another caller has claimed the delivery, Release
+	// returns an error wrapping sql.ErrNoRows and changes nothing.
+	Release(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error
+
+	// Redeliver makes a dead delivery pending again with a fresh attempt
+	// count. It returns an error wrapping sql.ErrNoRows unless it is dead.
+	Redeliver(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error)
+
+	GetDelivery(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error)
+
+	// ListDeliveries returns up to limit deliveries of a subscription,
+	// newest first, optionally only those in status.
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status *string, limit int) ([]*WebhookDelivery, error)
+
+	// ListAttempts returns the attempts of a delivery, oldest first.
+	ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]*WebhookAttempt, error)
 }
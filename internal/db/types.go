@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
 )
 
 // Customer represents a purchaser in the system.
@@ -13,6 +15,12 @@ type Customer struct {
 	Email     string    `db:"email"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
+
+	// FirebaseUID links the customer to a Firebase identity; nil until linked.
+	FirebaseUID *string `db:"firebase_uid"`
+
+	Phone  *string `db:"phone"`  // E.164 number for SMS, e.g. "+15550100"
+	Locale string  `db:"locale"` // BCP 47 tag notifications are rendered in; "en" when empty on create
 }
 
 // Category models a hierarchical product grouping.
@@ -24,34 +32,242 @@ type Category struct {
 	UpdatedAt time.Time  `db:"updated_at"`
 }
 
+// CategoryTreeNode is one category of a flattened tree, as returned by
+// CategoryRepository.Tree.
+type CategoryTreeNode struct {
+	Category
+	Depth int         // 0 for the tree's root
+	Path  []uuid.UUID // IDs from the tree's root down to this category
+}
+
+// CategoryDeleteStrategy says what happens to the contents of a deleted
+// category.
+type CategoryDeleteStrategy string
+
+const (
+	// CategoryDeleteRestrict refuses to delete a category that has
+	// subcategories or products.
+	CategoryDeleteRestrict CategoryDeleteStrategy = "restrict"
+	// CategoryDeleteReparent moves subcategories and products to the
+	// deleted category's parent. Products of a root category cannot move,
+	// so they make the delete fail.
+	CategoryDeleteReparent CategoryDeleteStrategy = "reparent"
+	// CategoryDeleteCascade deletes the whole subtree with its products.
+	// Products referenced by orders make the delete fail.
+	CategoryDeleteCascade CategoryDeleteStrategy = "cascade"
+)
+
 // Product defines an item for sale.
 type Product struct {
-	ID          uuid.UUID `db:"id"`
-	Name        string    `db:"name"`
-	Description *string   `db:"description"`
-	Price       float64   `db:"price"`
-	CategoryID  uuid.UUID `db:"category_id"`
-	CreatedAt   time.Time `db:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at"`
+	ID          uuid.UUID   `db:"id"`
+	Name        string      `db:"name"`
+	Description *string     `db:"description"`
+	Price       money.Money `db:"price"`
+	CategoryID  uuid.UUID   `db:"category_id"`
+	Stock       int         `db:"stock_quantity"` // units available to order
+	CreatedAt   time.Time   `db:"created_at"`
+	UpdatedAt   time.Time   `db:"updated_at"`
+
+	// ArchivedAt is set once the product is withdrawn from sale. Archived
+	// products are no longer listed but stay valid for past order items.
+	ArchivedAt *time.Time `db:"archived_at"`
 }
 
 // Order represents a customer purchase.
 type Order struct {
+	ID         uuid.UUID   `db:"id"`
+	CustomerID uuid.UUID   `db:"customer_id"`
+	Total      money.Money `db:"total_amount"`
+	Status     string      `db:"status"`
+	CreatedAt  time.Time   `db:"created_at"`
+	UpdatedAt  time.Time   `db:"updated_at"`
+
+	// Set when the order has been cancelled.
+	CancelReason *string    `db:"cancel_reason"`
+	CancelNote   *string    `db:"cancel_note"`
+	CancelledAt  *time.Time `db:"cancelled_at"`
+}
+
+// OrderItem links products to an order.
+type OrderItem struct {
+	ID        uuid.UUID   `db:"id"`
+	OrderID   uuid.UUID   `db:"order_id"`
+	ProductID uuid.UUID   `db:"product_id"`
+	Quantity  int         `db:"quantity"`
+	UnitPrice money.Money `db:"unit_price"`
+	CreatedAt time.Time   `db:"created_at"`
+	UpdatedAt time.Time   `db:"updated_at"`
+}
+
+// OrderStatusChange records one transition in an order's lifecycle.
+type OrderStatusChange struct {
+	ID         uuid.UUID `db:"id"`
+	OrderID    uuid.UUID `db:"order_id"`
+	FromStatus string    `db:"from_status"`
+	ToStatus   string    `db:"to_status"`
+	ActorUID   string    `db:"actor_uid"` // Firebase UID of whoever made the change
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Stock adjustment reasons written by the repositories themselves.
+const (
+	StockReasonOrderPlaced    = "order_placed"
+	StockReasonOrderCancelled = "order_cancelled"
+)
+
+// StockAdjustment is one audited change to a product's stock level.
+type StockAdjustment struct {
+	ID            uuid.UUID  `db:"id"`
+	ProductID     uuid.UUID  `db:"product_id"`
+	Delta         int        `db:"delta"`
+	QuantityAfter int        `db:"quantity_after"`
+	Reason        string     `db:"reason"`
+	OrderID       *uuid.UUID `db:"order_id"`  // set for reservations and releases
+	ActorUID      *string    `db:"actor_uid"` // set for manual adjustments
+	CreatedAt     time.Time  `db:"created_at"`
+}
+
+// IdempotencyKey ties a client-supplied key to the order it created, so a
+// retried placeOrder can return the original order.
+type IdempotencyKey struct {
+	CustomerID  uuid.UUID `db:"customer_id"`
+	Key         string    `db:"key"`
+	RequestHash string    `db:"request_hash"` // hash of the canonical request payload
+	OrderID     uuid.UUID `db:"order_id"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// Notification channels.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Outbox message states.
+const (
+	OutboxPending = "pending" // waiting for its next attempt
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // failed permanently or too often; not retried
+
+	// OutboxSuppressed messages were ruled out by the customer's
+	// notification preferences and are never sent.
+	OutboxSuppressed = "suppressed"
+)
+
+// OutboxMessage is a notification waiting in, or delivered from, the
+// transactional outbox.
+type OutboxMessage struct {
+	ID            uuid.UUID  `db:"id"`
+	OrderID       *uuid.UUID `db:"order_id"`    // the order it is about, if any
+	CustomerID    *uuid.UUID `db:"customer_id"` // whose preferences apply; nil for none
+	Event         string     `db:"event"`       // notification event, e.g. "order_placed"
+	Channel       string     `db:"channel"`
+	Recipient     string     `db:"recipient"` // email address or phone number
+	Subject       string     `db:"subject"`   // empty for SMS
+	Body          string     `db:"body"`
+	HTMLBody      string     `db:"html_body"` // email only; derived from Body when empty
+	Status        string     `db:"status"`
+	Attempts      int        `db:"attempts"` // delivery attempts started so far
+	LastError     *string    `db:"last_error"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+	LockedUntil   *time.Time `db:"locked_until"` // end of the current claim's lease
+	CreatedAt     time.Time  `db:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+	SentAt        *time.Time `db:"sent_at"`
+
+	// SuppressedReason says why preferences ruled the message out; set
+	// when Status is OutboxSuppressed.
+	SuppressedReason *string `db:"suppressed_reason"`
+}
+
+// NotificationPreferences are what a customer wants to be notified about.
+// Quiet hours are set when QuietStart and QuietEnd are; they are minutes
+// after midnight in TimeZone, and the window wraps past midnight when
+// QuietEnd is smaller.
+type NotificationPreferences struct {
+	CustomerID     uuid.UUID  `db:"customer_id"`
+	Channels       []string   `db:"channels"`         // channels that may be used
+	OptedOutEvents []string   `db:"opted_out_events"` // events sent on no channel
+	QuietStart     *int       `db:"quiet_start"`
+	QuietEnd       *int       `db:"quiet_end"`
+	TimeZone       string     `db:"time_zone"`  // IANA name, e.g. "Europe/Berlin"
+	UpdatedAt      *time.Time `db:"updated_at"` // nil until first saved
+}
+
+// DefaultNotificationPreferences returns the preferences of a customer who
+// never saved any: every channel, every event and no quiet hours.
+func DefaultNotificationPreferences(customerID uuid.UUID) *NotificationPreferences {
+	return &NotificationPreferences{
+		CustomerID:     customerID,
+		Channels:       []string{ChannelEmail, ChannelSMS},
+		OptedOutEvents: []string{},
+		TimeZone:       "UTC",
+	}
+}
+
+// Webhook event types. Subscriptions name the types they receive.
+const (
+	WebhookOrderPlaced        = "order.placed"
+	WebhookOrderStatusChanged = "order.status_changed"
+	WebhookOrderCancelled     = "order.cancelled"
+)
+
+// WebhookEventTypes lists every webhook event type.
+var WebhookEventTypes = []string{WebhookOrderPlaced, WebhookOrderStatusChanged, WebhookOrderCancelled}
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending" // waiting for its next attempt
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // refused or failed too often; not retried
+)
+
+// WebhookSubscription is a merchant endpoint that receives order events.
+type WebhookSubscription struct {
 	ID         uuid.UUID `db:"id"`
-	CustomerID uuid.UUID `db:"customer_id"`
-	Total      float64   `db:"total_amount"`
-	Status     string    `db:"status"`
+	URL        string    `db:"url"`
+	Secret     string    `db:"secret"` // HMAC-SHA256 key for signing payloads
+	EventTypes []string  `db:"event_types"`
+	Active     bool      `db:"active"` // inactive subscriptions receive no new events
 	CreatedAt  time.Time `db:"created_at"`
 	UpdatedAt  time.Time `db:"updated_at"`
 }
 
-// OrderItem links products to an order.
-type OrderItem struct {
-	ID        uuid.UUID `db:"id"`
-	OrderID   uuid.UUID `db:"order_id"`
-	ProductID uuid.UUID `db:"product_id"`
-	Quantity  int       `db:"quantity"`
-	UnitPrice float64   `db:"unit_price"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+// WebhookEvent is an order event to fan out to the subscriptions of its
+// type. It is not stored itself; every matching subscription gets a
+// WebhookDelivery of it.
+type WebhookEvent struct {
+	ID      uuid.UUID
+	Type    string
+	OrderID *uuid.UUID
+	Payload []byte // JSON document sent as the request body
+}
+
+// WebhookDelivery is one event on its way to one subscription.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `db:"id"`
+	SubscriptionID uuid.UUID  `db:"subscription_id"`
+	EventID        uuid.UUID  `db:"event_id"` // shared by the deliveries of one event
+	EventType      string     `db:"event_type"`
+	OrderID        *uuid.UUID `db:"order_id"`
+	Payload        []byte     `db:"payload"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"` // attempts started since created or redelivered
+	LastError      *string    `db:"last_error"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	LockedUntil    *time.Time `db:"locked_until"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+}
+
+// WebhookAttempt is the logged outcome of one HTTP request of a delivery.
+type WebhookAttempt struct {
+	ID         uuid.UUID `db:"id"`
+	DeliveryID uuid.UUID `db:"delivery_id"`
+	StatusCode *int      `db:"status_code"` // nil when no response arrived
+	Error      *string   `db:"error"`       // nil when the attempt succeeded
+	DurationMS int       `db:"duration_ms"`
+	CreatedAt  time.Time `db:"created_at"`
 }
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCancellation is returned when a cancellation request is incomplete
+// or uses an unknown reason.
+var ErrInvalidCancellation = errors.New("invalid cancellation")
+
+// CancelReason is the reason code stored with a cancelled order.
+type CancelReason string
+
+const (
+	CancelOrderedByMistake CancelReason = "ordered_by_mistake"
+	CancelFoundCheaper     CancelReason = "found_cheaper_elsewhere"
+	CancelDeliveryTooSlow  CancelReason = "delivery_too_slow"
+	CancelNoLongerNeeded   CancelReason = "no_longer_needed"
+	CancelPaymentIssue     CancelReason = "payment_issue"
+	CancelOther            CancelReason = "other"
+)
+
+// Valid reports whether r is a known reason code.
+func (r CancelReason) Valid() bool {
+	switch r {
+	case CancelOrderedByMistake, CancelFoundCheaper, CancelDeliveryTooSlow,
+		CancelNoLongerNeeded, CancelPaymentIssue, CancelOther:
+		return true
+	}
+	return false
+}
+
+// ValidateCancellation checks that an order in status may be cancelled for
+// reason. A free-text note is mandatory when the reason is CancelOther.
+func ValidateCancellation(status OrderStatus, reason CancelReason, note string) error {
+	if !reason.Valid() {
+		return fmt.Errorf("%w: unknown cancel reason %q", ErrInvalidCancellation, reason)
+	}
+	if reason == CancelOther && note == "" {
+		return fmt.Errorf("%w: a note is required when the cancel reason is %q", ErrInvalidCancellation, reason)
+	}
+	if !status.Cancellable() {
+		return fmt.Errorf("%w: %s orders can no longer be cancelled", ErrInvalidTransition, status)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Package domain holds business rules that sit above persistence and
+// transport, such as the order status lifecycle.
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OrderStatus is the lifecycle state of an order, stored as-is in orders.status.
+type OrderStatus string
+
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderPaid      OrderStatus = "paid"
+	OrderFulfilled OrderStatus = "fulfilled"
+	OrderShipped   OrderStatus = "shipped"
+	OrderDelivered OrderStatus = "delivered"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderRefunded  OrderStatus = "refunded"
+)
+
+// ErrInvalidTransition is returned when an order cannot move between two states.
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// orderTransitions lists, for each state, the states it may move to.
+// States without an entry (cancelled, refunded) are terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderFulfilled, OrderCancelled, OrderRefunded},
+	OrderFulfilled: {OrderShipped, OrderRefunded},
+	OrderShipped:   {OrderDelivered},
+	OrderDelivered: {OrderRefunded},
+}
+
+// Valid reports whether s is a known order status.
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderPending, OrderPaid, OrderFulfilled, OrderShipped,
+		OrderDelivered, OrderCancelled, OrderRefunded:
+		return true
+	}
+	return false
+}
+
+// Terminal reports whether no further transitions are possible from s.
+func (s OrderStatus) Terminal() bool {
+	return s.Valid() && len(orderTransitions[s]) == 0
+}
+
+// Cancellable reports whether an order in status s may still be cancelled.
+func (s OrderStatus) Cancellable() bool {
+	return CanTransition(s, OrderCancelled)
+}
+
+// CanTransition reports whether an order may move from one status to another.
+func CanTransition(from, to OrderStatus) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition validates a move between two states and returns an error
+// wrapping ErrInvalidTransition when it is not allowed.
+func Transition(from, to OrderStatus) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, to)
+	}
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// allStatuses lists every known status followed by unknown ones.
+var allStatuses = []OrderStatus{
+	OrderPending, OrderPaid, OrderFulfilled, OrderShipped,
+	OrderDelivered, OrderCancelled, OrderRefunded,
+	"", "archived", "PAID",
+}
+
+func TestTransition(t *testing.T) {
+	allowed := map[[2]OrderStatus]bool{
+		{OrderPending, OrderPaid}:       true,
+		{OrderPending, OrderCancelled}:  true,
+		{OrderPaid, OrderFulfilled}:     true,
+		{OrderPaid, OrderCancelled}:     true,
+		{OrderPaid, OrderRefunded}:      true,
+		{OrderFulfilled, OrderShipped}:  true,
+		{OrderFulfilled, OrderRefunded}: true,
+		{OrderShipped, OrderDelivered}:  true,
+		{OrderDelivered, OrderRefunded}: true,
+	}
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			want := allowed[[2]OrderStatus{from, to}]
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", from, to, got, want)
+			}
+			err := Transition(from, to)
+			switch {
+			case want && err != nil:
+				t.Errorf("Transition(%q, %q) = %v, want nil", from, to, err)
+			case !want && !errors.Is(err, ErrInvalidTransition):
+				t.Errorf("Transition(%q, %q) = %v, want ErrInvalidTransition", from, to, err)
+			}
+		}
+	}
+}
+
+func TestOrderStatusPredicates(t *testing.T) {
+	tests := []struct {
+		status                       OrderStatus
+		valid, terminal, cancellable bool
+	}{
+		{OrderPending, true, false, true},
+		{OrderPaid, true, false, true},
+		{OrderFulfilled, true, false, false},
+		{OrderShipped, true, false, false},
+		{OrderDelivered, true, false, false},
+		{OrderCancelled, true, true, false},
+		{OrderRefunded, true, true, false},
+		{"", false, false, false},
+		{"archived", false, false, false},
+		{"PAID", false, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.status.Valid(); got != tt.valid {
+			t.Errorf("%q.Valid() = %v, want %v", tt.status, got, tt.valid)
+		}
+		if got := tt.status.Terminal(); got != tt.terminal {
+			t.Errorf("%q.Terminal() = %v, want %v", tt.status, got, tt.terminal)
+		}
+		if got := tt.status.Cancellable(); got != tt.cancellable {
+			t.Errorf("%q.Cancellable() = %v, want %v", tt.status, got, tt.cancellable)
+		}
+	}
+}
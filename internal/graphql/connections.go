@@ -0,0 +1,123 @@
+package graphql
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// Page sizes accepted by the first argument of connection fields.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// The connection models are written by hand so they can carry the count
+// query behind totalCount, which only runs when the field is selected.
+
+type CategoryConnection struct {
+	Edges    []*CategoryEdge `json:"edges"`
+	PageInfo *PageInfo       `json:"pageInfo"`
+
+	count func(ctx context.Context) (int, error)
+}
+
+type ProductConnection struct {
+	Edges    []*ProductEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+
+	count func(ctx context.Context) (int, error)
+}
+
+type OrderConnection struct {
+	Edges    []*OrderEdge `json:"edges"`
+	PageInfo *PageInfo    `json:"pageInfo"`
+
+	count func(ctx context.Context) (int, error)
+}
+
+type CustomerConnection struct {
+	Edges    []*CustomerEdge `json:"edges"`
+	PageInfo *PageInfo       `json:"pageInfo"`
+
+	count func(ctx context.Context) (int, error)
+}
+
+// pageRequest validates first and after. The returned page asks for one row
+// more than first so paginate can tell whether another page follows.
+func pageRequest(first *int, after *string) (db.Page, error) {
+	n := defaultPageSize
+	if first != nil {
+		n = *first
+	}
+	if n < 1 || n > maxPageSize {
+		return db.Page{}, apperror.Validation("first must be between 1 and %d", maxPageSize)
+	}
+	page := db.Page{Limit: n + 1}
+	if after != nil {
+		c, err := decodeCursor(*after)
+		if err != nil {
+			return db.Page{}, err
+		}
+		page.After = &c
+	}
+	return page, nil
+}
+
+// paginate turns the rows fetched for page into edges and PageInfo.
+func paginate[T, E any](rows []T, page db.Page, key func(T) db.Cursor, edge func(cursor string, row T) E) ([]E, *PageInfo) {
+	info := &PageInfo{HasPreviousPage: page.After != nil}
+	if len(rows) == page.Limit {
+		rows = rows[:page.Limit-1]
+		info.HasNextPage = true
+	}
+	edges := make([]E, len(rows))
+	for i, row := range rows {
+		cursor := encodeCursor(key(row))
+		edges[i] = edge(cursor, row)
+		if i == 0 {
+			info.StartCursor = &cursor
+		}
+		info.EndCursor = &cursor
+	}
+	return edges, info
+}
+
+// encodeCursor makes an opaque cursor from a keyset position.
+func encodeCursor(c db.Cursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor is the inverse of encodeCursor.
+func decodeCursor(s string) (db.Cursor, error) {
+	invalid := apperror.Validation("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return db.Cursor{}, invalid
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return db.Cursor{}, invalid
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return db.Cursor{}, invalid
+	}
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return db.Cursor{}, invalid
+	}
+	return db.Cursor{CreatedAt: createdAt, ID: uid}, nil
+}
+
+func categoryCursor(c *db.Category) db.Cursor { return db.Cursor{CreatedAt: c.CreatedAt, ID: c.ID} }
+func productCursor(p *db.Product) db.Cursor   { return db.Cursor{CreatedAt: p.CreatedAt, ID: p.ID} }
+func orderCursor(o *db.Order) db.Cursor       { return db.Cursor{CreatedAt: o.CreatedAt, ID: o.ID} }
+func customerCursor(c *db.Customer) db.Cursor { return db.Cursor{CreatedAt: c.CreatedAt, ID: c.ID} }
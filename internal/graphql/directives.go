@@ -0,0 +1,77 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/auth"
+)
+
+// NewConfig returns the executable schema configuration for r with the
+// @auth and @hasRole directive handlers installed.
+func NewConfig(r *Resolver) Config {
+	return Config{
+		Resolvers: r,
+		Directives: DirectiveRoot{
+			Auth:    authDirective,
+			HasRole: hasRoleDirective,
+		},
+	}
+}
+
+// authDirective implements @auth: the field resolves only for a caller with
+// verified token claims.
+func authDirective(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if _, ok := auth.FromContext(ctx); !ok {
+		return nil, apperror.Unauthenticated()
+	}
+	return next(ctx)
+}
+
+// hasRoleDirective implements @hasRole: the caller must hold at least one of
+// roles.
+func hasRoleDirective(ctx context.Context, obj interface{}, next graphql.Resolver, roles []Role) (interface{}, error) {
+	if _, ok := auth.FromContext(ctx); !ok {
+		return nil, apperror.Unauthenticated()
+	}
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = roleName(role)
+		if auth.HasRole(ctx, names[i]) {
+			return next(ctx)
+		}
+	}
+	return nil, apperror.Forbidden("requires role %s", strings.Join(names, " or "))
+}
+
+// roleName maps a schema Role to the role string carried in tokens.
+func roleName(r Role) string {
+	return strings.ToLower(string(r))
+}
+
+// CheckMutationAuth returns an error naming every Mutation field that has
+// neither @auth nor @hasRole, so an unprotected mutation fails at startup
+// instead of shipping.
+func CheckMutationAuth(schema *ast.Schema) error {
+	if schema.Mutation == nil {
+		return nil
+	}
+	var missing []string
+	for _, f := range schema.Mutation.Fields {
+		if strings.HasPrefix(f.Name, "__") {
+			continue
+		}
+		if f.Directives.ForName("auth") == nil && f.Directives.ForName("hasRole") == nil {
+			missing = append(missing, f.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("mutations without @auth or @hasRole: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
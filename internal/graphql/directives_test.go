@@ -0,0 +1,103 @@
+package graphql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/auth"
+)
+
+func TestDirectives(t *testing.T) {
+	as := func(roles ...string) context.Context {
+		return auth.NewContext(context.Background(), &auth.Claims{UID: "uid-1", Roles: roles})
+	}
+	hasRole := func(roles ...Role) func(context.Context) (interface{}, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			return hasRoleDirective(ctx, nil, resolved, roles)
+		}
+	}
+	authOnly := func(ctx context.Context) (interface{}, error) {
+		return authDirective(ctx, nil, resolved)
+	}
+
+	tests := []struct {
+		name      string
+		directive func(context.Context) (interface{}, error)
+		ctx       context.Context
+		wantCode  apperror.Code // empty when the field resolves
+	}{
+		{"@auth without identity", authOnly, context.Background(), apperror.CodeUnauthenticated},
+		{"@auth with identity", authOnly, as(), ""},
+		{"@hasRole without identity", hasRole(RoleAdmin), context.Background(), apperror.CodeUnauthenticated},
+		{"@hasRole with another role", hasRole(RoleAdmin), as("customer"), apperror.CodeForbidden},
+		{"@hasRole without roles", hasRole(RoleCustomer), as(), apperror.CodeForbidden},
+		{"@hasRole with the role", hasRole(RoleAdmin), as("customer", "admin"), ""},
+		{"@hasRole with either role", hasRole(RoleAdmin, RoleCustomer), as("customer"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.directive(tt.ctx)
+			if tt.wantCode == "" {
+				if err != nil || got != "resolved" {
+					t.Errorf("directive = %v, %v; want the field resolved", got, err)
+				}
+				return
+			}
+			if got != nil || apperror.CodeOf(err) != tt.wantCode {
+				t.Errorf("directive = %v, %v; want %s", got, err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func resolved(context.Context) (interface{}, error) {
+	return "resolved", nil
+}
+
+func TestCheckMutationAuth(t *testing.T) {
+	load := func(src string) *ast.Schema {
+		t.Helper()
+		schema, err := gqlparser.LoadSchema(&ast.Source{Name: "test.graphql", Input: `
+			directive @auth on FIELD_DEFINITION
+			directive @hasRole(roles: [String!]!) on FIELD_DEFINITION
+			type Query { ping: Boolean }
+		` + src})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return schema
+	}
+
+	if err := CheckMutationAuth(load(`
+		type Mutation {
+			a: Boolean @auth
+			b: Boolean @hasRole(roles: ["admin"])
+		}
+	`)); err != nil {
+		t.Errorf("CheckMutationAuth(protected) = %v", err)
+	}
+	if err := CheckMutationAuth(load("")); err != nil {
+		t.Errorf("CheckMutationAuth(no mutations) = %v", err)
+	}
+	err := CheckMutationAuth(load(`
+		type Mutation {
+			a: Boolean @auth
+			open: Boolean
+			alsoOpen: Boolean @deprecated
+		}
+	`))
+	if err == nil || !strings.Contains(err.Error(), "open, alsoOpen") {
+		t.Errorf("CheckMutationAuth(unprotected) = %v, want open and alsoOpen named", err)
+	}
+
+	// the served schema protects every mutation
+	r, _ := newTestResolver(t)
+	if err := CheckMutationAuth(NewExecutableSchema(NewConfig(r)).Schema()); err != nil {
+		t.Errorf("CheckMutationAuth(schema) = %v", err)
+	}
+}
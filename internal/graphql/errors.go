@@ -0,0 +1,39 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+)
+
+// ErrorPresenter turns resolver errors into GraphQL errors that always carry
+// extensions.code. Errors gqlgen raised itself while parsing or validating
+// the request already have a code and pass through. Anything apperror cannot
+// classify is logged and shown only as "internal server error".
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		if _, ok := gqlErr.Extensions["code"]; ok {
+			return gqlErr
+		}
+		appErr = apperror.From(err)
+	}
+	if appErr.Code == apperror.CodeInternal {
+		log.Printf("graphql: internal error at %s: %v", gqlErr.Path, err)
+	}
+	gqlErr.Message = appErr.Message
+	gqlErr.Extensions = appErr.Extensions()
+	return gqlErr
+}
+
+// idempotencyConflictError reports that key was already used for a
+// placeOrder request with a different payload.
+func idempotencyConflictError(key string) error {
+	return apperror.Conflict("idempotency key %q was already used with a different request", key)
+}
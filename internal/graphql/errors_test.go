@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+func TestErrorPresenter(t *testing.T) {
+	productID := uuid.New()
+	tests := []struct {
+		name        string
+		err         error
+		wantMessage string
+		wantExt     map[string]interface{}
+	}{
+		{"app error", fmt.Errorf("resolve order: %w", apperror.NotFound("order %s not found", "o-1")),
+			"order o-1 not found", map[string]interface{}{"code": "NOT_FOUND"}},
+		{"classified error", fmt.Errorf("select order: %w", sql.ErrNoRows),
+			"not found", map[string]interface{}{"code": "NOT_FOUND"}},
+		{"extra fields", &db.InsufficientStockError{ProductIDs: []uuid.UUID{productID}},
+			"insufficient stock for products: " + productID.String(),
+			map[string]interface{}{"code": "INSUFFICIENT_STOCK", "productIDs": []string{productID.String()}}},
+		{"internal error", errors.New(`pq: password authentication failed for user "orders"`),
+			"internal server error", map[string]interface{}{"code": "INTERNAL"}},
+		{"gqlgen error", &gqlerror.Error{
+			Message:    `Cannot query field "secret" on type "Order".`,
+			Extensions: map[string]interface{}{"code": "GRAPHQL_VALIDATION_FAILED"},
+		}, `Cannot query field "secret" on type "Order".`, map[string]interface{}{"code": "GRAPHQL_VALIDATION_FAILED"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ErrorPresenter(context.Background(), tt.err)
+			if got.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", got.Message, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(got.Extensions, tt.wantExt) {
+				t.Errorf("extensions = %v, want %v", got.Extensions, tt.wantExt)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+)
+
+// IdempotencyKeyHeader is the HTTP header clients may use instead of
+// OrderInput.idempotencyKey.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLen bounds client-supplied keys.
+const maxIdempotencyKeyLen = 255
+
+type idempotencyKeyCtx struct{}
+
+// IdempotencyKeyMiddleware copies the Idempotency-Key request header into the
+// request context so placeOrder can use it.
+func IdempotencyKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader)); key != "" {
+			r = r.WithContext(context.WithValue(r.Context(), idempotencyKeyCtx{}, key))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// idempotencyKey returns the key for this placeOrder call, taken from the
+// input field or the request header. Both may be given only if they agree.
+func idempotencyKey(ctx context.Context, input *string) (string, error) {
+	header, _ := ctx.Value(idempotencyKeyCtx{}).(string)
+	var key string
+	if input != nil {
+		key = strings.TrimSpace(*input)
+	}
+	switch {
+	case key == "":
+		key = header
+	case header != "" && header != key:
+		return "", apperror.Validation("idempotencyKey does not match the %s header", IdempotencyKeyHeader)
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return "", apperror.Validation("idempotency key must be at most %d characters", maxIdempotencyKeyLen)
+	}
+	return key, nil
+}
+
+// orderRequestHash fingerprints a placeOrder payload so a replayed key can be
+// checked against the original request. Items are summed per product and
+// sorted, so the hash does not depend on line order.
+func orderRequestHash(customerID uuid.UUID, items []*OrderItemInput) (string, error) {
+	qty := make(map[string]int)
+	for _, in := range items {
+		pid, err := uuid.Parse(in.ProductID)
+		if err != nil {
+			return "", apperror.Validation("invalid productID")
+		}
+		qty[pid.String()] += in.Quantity
+	}
+	ids := make([]string, 0, len(qty))
+	for id := range qty {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "customer=%s\n", customerID)
+	for _, id := range ids {
+		fmt.Fprintf(h, "%s=%d\n", id, qty[id])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// replayOrder answers a placeOrder retry under key with the order the key
+// already holds. The retry must send the same request as the original.
+func (r *Resolver) replayOrder(ctx context.Context, key, hash string, orderID uuid.UUID, heldHash string) (*Order, error) {
+	if hash != heldHash {
+		return nil, idempotencyConflictError(key)
+	}
+	o, items, err := r.OrderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLOrder(o, items), nil
+}
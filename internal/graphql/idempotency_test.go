@@ -0,0 +1,132 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+)
+
+func TestPlaceOrderIdempotency(t *testing.T) {
+	r, repos := newTestResolver(t)
+	m := &mutationResolver{r}
+	p := seedProduct(t, repos, "2.50", 10)
+	ctx := customerCtx("uid-1", "buyer@example.com")
+
+	key := "order-1"
+	input := func(qty int) OrderInput {
+		return OrderInput{
+			Items:          []*OrderItemInput{{ProductID: p.ID.String(), Quantity: qty}},
+			IdempotencyKey: &key,
+		}
+	}
+
+	first, err := m.PlaceOrder(ctx, input(2))
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	replayed, err := m.PlaceOrder(ctx, input(2))
+	if err != nil {
+		t.Fatalf("replayed PlaceOrder: %v", err)
+	}
+	if replayed.ID != first.ID || replayed.Total != first.Total {
+		t.Errorf("replay = order %s total %v, want order %s total %v", replayed.ID, replayed.Total, first.ID, first.Total)
+	}
+	wantStock(t, repos, p.ID, 8)
+
+	// the header carries the same key
+	hctx := context.WithValue(ctx, idempotencyKeyCtx{}, key)
+	in := input(2)
+	in.IdempotencyKey = nil
+	if o, err := m.PlaceOrder(hctx, in); err != nil || o.ID != first.ID {
+		t.Errorf("PlaceOrder with header key = %v, %v; want order %s", o, err, first.ID)
+	}
+
+	if _, err := m.PlaceOrder(ctx, input(3)); apperror.CodeOf(err) != apperror.CodeConflict {
+		t.Errorf("PlaceOrder with reused key and other items error = %v, want %s", err, apperror.CodeConflict)
+	}
+	wantStock(t, repos, p.ID, 8)
+
+	// another customer may use the same key
+	other, err := m.PlaceOrder(customerCtx("uid-2", "other@example.com"), input(1))
+	if err != nil {
+		t.Fatalf("PlaceOrder by another customer: %v", err)
+	}
+	if other.ID == first.ID {
+		t.Error("another customer's key replayed the first customer's order")
+	}
+	wantStock(t, repos, p.ID, 7)
+}
+
+func TestPlaceOrderIdempotencyKeyExpires(t *testing.T) {
+	r, repos := newTestResolver(t)
+	r.IdempotencyTTL = 10 * time.Millisecond
+	m := &mutationResolver{r}
+	p := seedProduct(t, repos, "1.00", 10)
+	ctx := customerCtx("uid-1", "buyer@example.com")
+
+	key := "order-1"
+	input := OrderInput{
+		Items:          []*OrderItemInput{{ProductID: p.ID.String(), Quantity: 1}},
+		IdempotencyKey: &key,
+	}
+	first, err := m.PlaceOrder(ctx, input)
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	time.Sleep(2 * r.IdempotencyTTL)
+
+	second, err := m.PlaceOrder(ctx, input)
+	if err != nil {
+		t.Fatalf("PlaceOrder after the key expired: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Errorf("PlaceOrder after the key expired replayed order %s", first.ID)
+	}
+	wantStock(t, repos, p.ID, 8)
+}
+
+func TestPlaceOrderReplayAfterProductChanges(t *testing.T) {
+	r, repos := newTestResolver(t)
+	m := &mutationResolver{r}
+	p := seedProduct(t, repos, "2.50", 10)
+	ctx := customerCtx("uid-1", "buyer@example.com")
+
+	key := "order-1"
+	input := OrderInput{
+		Items:          []*OrderItemInput{{ProductID: p.ID.String(), Quantity: 2}},
+		IdempotencyKey: &key,
+	}
+	first, err := m.PlaceOrder(ctx, input)
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if _, err := repos.Products.Archive(context.Background(), p.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the retry replays the order instead of rejecting the archived product
+	replayed, err := m.PlaceOrder(ctx, input)
+	if err != nil {
+		t.Fatalf("replayed PlaceOrder after archiving: %v", err)
+	}
+	if replayed.ID != first.ID || replayed.Total != first.Total {
+		t.Errorf("replay = order %s total %v, want order %s total %v", replayed.ID, replayed.Total, first.ID, first.Total)
+	}
+	wantStock(t, repos, p.ID, 8)
+
+	// without the key the archived product is still refused
+	input.IdempotencyKey = nil
+	if _, err := m.PlaceOrder(ctx, input); apperror.CodeOf(err) != apperror.CodeValidationFailed {
+		t.Errorf("PlaceOrder of an archived product error = %v, want %s", err, apperror.CodeValidationFailed)
+	}
+}
+
+func TestIdempotencyKeyHeaderMismatch(t *testing.T) {
+	ctx := context.WithValue(context.Background(), idempotencyKeyCtx{}, "from-header")
+	key := "from-input"
+	if _, err := idempotencyKey(ctx, &key); apperror.CodeOf(err) != apperror.CodeValidationFailed {
+		t.Errorf("idempotencyKey with mismatching header error = %v, want %s", err, apperror.CodeValidationFailed)
+	}
+}
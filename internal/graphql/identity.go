@@ -0,0 +1,106 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/auth"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// linkedCustomer returns the customer bound to the caller's Firebase UID,
+// or nil if the identity has not been linked yet.
+func (r *Resolver) linkedCustomer(ctx context.Context) (*db.Customer, error) {
+	claims, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, apperror.Unauthenticated()
+	}
+	c, err := r.CustomerRepo.GetByFirebaseUID(ctx, claims.UID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return c, err
+}
+
+// currentCustomer returns the customer the authenticated caller acts as,
+// linking the Firebase identity to a customer row on first use.
+func (r *Resolver) currentCustomer(ctx context.Context) (*db.Customer, error) {
+	c, err := r.linkedCustomer(ctx)
+	if err != nil || c != nil {
+		return c, err
+	}
+	return r.registerCustomer(ctx, "")
+}
+
+// registerCustomer binds auth.Claims.UID to a customer row. An existing
+// unlinked customer with the same email is adopted when the token's email is
+// verified; otherwise a new customer is created. name defaults to the local
+// part of the email address.
+func (r *Resolver) registerCustomer(ctx context.Context, name string) (*db.Customer, error) {
+	claims, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, apperror.Unauthenticated()
+	}
+	if c, err := r.CustomerRepo.GetByFirebaseUID(ctx, claims.UID); err == nil {
+		return c, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if claims.Email == "" {
+		return nil, apperror.Validation("cannot register a customer without an email address on the token")
+	}
+
+	existing, err := r.CustomerRepo.GetByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		if existing.FirebaseUID != nil || !claims.EmailVerified {
+			return nil, apperror.Conflict("customer with email %s is already registered", claims.Email)
+		}
+		if err := r.CustomerRepo.LinkFirebaseUID(ctx, existing.ID, claims.UID); err != nil {
+			return nil, err
+		}
+		existing.FirebaseUID = &claims.UID
+		return existing, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	if strings.TrimSpace(name) == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	name, email, err := normalizeCustomer(name, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	uid := claims.UID
+	c := &db.Customer{
+		ID:          uuid.New(),
+		Name:        name,
+		Email:       email,
+		FirebaseUID: &uid,
+	}
+	if err := r.CustomerRepo.Create(ctx, c); err != nil {
+		// a concurrent first request may have registered the same identity
+		if linked, lerr := r.CustomerRepo.GetByFirebaseUID(ctx, uid); lerr == nil {
+			return linked, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// canAccessCustomer reports whether the caller may act on the customer
+// record and orders of customerID: admins on everyone, customers only on
+// themselves.
+func (r *Resolver) canAccessCustomer(ctx context.Context, customerID uuid.UUID) bool {
+	if auth.HasRole(ctx, "admin") {
+		return true
+	}
+	me, err := r.linkedCustomer(ctx)
+	return err == nil && me != nil && me.ID == customerID
+}
@@ -0,0 +1,120 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/dataloader"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// Loaders batch the lookups that nested fields repeat once per parent.
+// A fresh set is created for every request by LoaderMiddleware.
+type Loaders struct {
+	CategoryByID         *dataloader.Loader[uuid.UUID, *db.Category]
+	CategoryChildren     *dataloader.Loader[uuid.UUID, []*db.Category]
+	CategoryAncestors    *dataloader.Loader[uuid.UUID, []*db.Category]
+	CategoryProductCount *dataloader.Loader[uuid.UUID, int]
+	ProductByID          *dataloader.Loader[uuid.UUID, *db.Product]
+}
+
+// NewLoaders returns loaders backed by r's repositories.
+func NewLoaders(r *Resolver) *Loaders {
+	return &Loaders{
+		CategoryByID: dataloader.New(func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*db.Category, error) {
+			cats, err := r.CategoryRepo.GetByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			out := make(map[uuid.UUID]*db.Category, len(cats))
+			for _, c := range cats {
+				out[c.ID] = c
+			}
+			return out, nil
+		}),
+		CategoryChildren: dataloader.New(func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]*db.Category, error) {
+			cats, err := r.CategoryRepo.ListChildrenOf(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			out := make(map[uuid.UUID][]*db.Category, len(ids))
+			for _, c := range cats {
+				out[*c.ParentID] = append(out[*c.ParentID], c)
+			}
+			return out, nil
+		}),
+		CategoryAncestors: dataloader.New(func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]*db.Category, error) {
+			return r.CategoryRepo.AncestorsOf(ctx, ids)
+		}),
+		CategoryProductCount: dataloader.New(func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]int, error) {
+			return r.ProductRepo.CountByCategories(ctx, ids)
+		}),
+		ProductByID: dataloader.New(func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*db.Product, error) {
+			prods, err := r.ProductRepo.GetByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			out := make(map[uuid.UUID]*db.Product, len(prods))
+			for _, p := range prods {
+				out[p.ID] = p
+			}
+			return out, nil
+		}),
+	}
+}
+
+type loadersCtx struct{}
+
+// LoaderMiddleware gives every request its own Loaders, so results are
+// shared between the fields of one request and never across requests.
+func (r *Resolver) LoaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := context.WithValue(req.Context(), loadersCtx{}, NewLoaders(r))
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// loaders returns the request's Loaders, or unshared ones when the request
+// did not pass through LoaderMiddleware.
+func (r *Resolver) loaders(ctx context.Context) *Loaders {
+	if l, ok := ctx.Value(loadersCtx{}).(*Loaders); ok {
+		return l
+	}
+	return NewLoaders(r)
+}
+
+// loadCategory returns one category through the request's loader, or
+// sql.ErrNoRows if it does not exist.
+func (r *Resolver) loadCategory(ctx context.Context, id uuid.UUID) (*db.Category, error) {
+	c, err := r.loaders(ctx).CategoryByID.Load(ctx, id)
+	if err == nil && c == nil {
+		err = sql.ErrNoRows
+	}
+	return c, err
+}
+
+// loadProduct returns one product through the request's loader, or
+// sql.ErrNoRows if it does not exist.
+func (r *Resolver) loadProduct(ctx context.Context, id uuid.UUID) (*db.Product, error) {
+	p, err := r.loaders(ctx).ProductByID.Load(ctx, id)
+	if err == nil && p == nil {
+		err = sql.ErrNoRows
+	}
+	return p, err
+}
+
+// QueryLogMiddleware logs how many database queries each request ran and
+// how long it took. It is meant for debugging N+1 query patterns.
+func QueryLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, counter := db.WithQueryCounter(req.Context())
+		start := time.Now()
+		next.ServeHTTP(w, req.WithContext(ctx))
+		log.Printf("debug: %s %s ran %d queries in %s", req.Method, req.URL.Path, counter.Count(), time.Since(start))
+	})
+}
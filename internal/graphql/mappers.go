@@ -0,0 +1,281 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/domain"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification/templates"
+)
+
+// toGQLCategory maps a db.Category onto its GraphQL model.
+// The parent is returned as an ID stub that categoryResolver.Parent expands.
+func toGQLCategory(c *db.Category) *Category {
+	out := &Category{
+		ID:   c.ID.String(),
+		Name: c.Name,
+	}
+	if c.ParentID != nil {
+		out.Parent = &Category{ID: c.ParentID.String()}
+	}
+	return out
+}
+
+// toGQLProduct maps a db.Product onto its GraphQL model.
+// The category is returned as an ID stub that productResolver.Category
+// expands.
+func toGQLProduct(p *db.Product) *Product {
+	return &Product{
+		ID:          p.ID.String(),
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Category:    &Category{ID: p.CategoryID.String()},
+		Stock:       p.Stock,
+		ArchivedAt:  p.ArchivedAt,
+	}
+}
+
+// toGQLPriceStats maps db.PriceStats onto its GraphQL model.
+func toGQLPriceStats(st *db.PriceStats) *PriceStats {
+	out := &PriceStats{
+		Count:     st.Count,
+		Min:       st.Min,
+		Max:       st.Max,
+		Mean:      st.Mean,
+		Median:    st.Median,
+		P90:       st.P90,
+		StdDev:    st.StdDev,
+		Histogram: make([]*PriceBucket, len(st.Histogram)),
+	}
+	for i, b := range st.Histogram {
+		out.Histogram[i] = &PriceBucket{Min: b.Min, Max: b.Max, Count: b.Count}
+	}
+	return out
+}
+
+// toGQLOrderItem maps a db.OrderItem; the product is an ID stub that
+// orderItemResolver.Product expands on demand.
+func toGQLOrderItem(it *db.OrderItem) *OrderItem {
+	return &OrderItem{
+		ID:       it.ID.String(),
+		Product:  &Product{ID: it.ProductID.String()},
+		Quantity: it.Quantity,
+		Price:    it.UnitPrice,
+	}
+}
+
+// toGQLOrder maps a db.Order. When items is nil the Items field is left
+// empty and orderResolver.Items loads them lazily.
+func toGQLOrder(o *db.Order, items []*db.OrderItem) *Order {
+	out := &Order{
+		ID:         o.ID.String(),
+		CustomerID: o.CustomerID.String(),
+		Total:      o.Total,
+		Status:     toGQLOrderStatus(domain.OrderStatus(o.Status)),
+		CreatedAt:  o.CreatedAt,
+	}
+	if o.CancelReason != nil {
+		reason := toGQLCancelReason(domain.CancelReason(*o.CancelReason))
+		out.CancelReason = &reason
+		out.CancelNote = o.CancelNote
+		out.CancelledAt = o.CancelledAt
+	}
+	if items != nil {
+		out.Items = toGQLOrderItems(items)
+	}
+	return out
+}
+
+func toGQLOrderItems(items []*db.OrderItem) []*OrderItem {
+	out := make([]*OrderItem, len(items))
+	for i, it := range items {
+		out[i] = toGQLOrderItem(it)
+	}
+	return out
+}
+
+// toGQLOrderStatus maps a domain status ("pending") to its enum value ("PENDING").
+func toGQLOrderStatus(s domain.OrderStatus) OrderStatus {
+	return OrderStatus(strings.ToUpper(string(s)))
+}
+
+// toDomainOrderStatus is the inverse of toGQLOrderStatus.
+func toDomainOrderStatus(s OrderStatus) domain.OrderStatus {
+	return domain.OrderStatus(strings.ToLower(string(s)))
+}
+
+func toGQLOrderStatusChange(c *db.OrderStatusChange) *OrderStatusChange {
+	return &OrderStatusChange{
+		From:      toGQLOrderStatus(domain.OrderStatus(c.FromStatus)),
+		To:        toGQLOrderStatus(domain.OrderStatus(c.ToStatus)),
+		ActorUID:  c.ActorUID,
+		ChangedAt: c.CreatedAt,
+	}
+}
+
+func toGQLCancelReason(r domain.CancelReason) CancelReason {
+	return CancelReason(strings.ToUpper(string(r)))
+}
+
+func toDomainCancelReason(r CancelReason) domain.CancelReason {
+	return domain.CancelReason(strings.ToLower(string(r)))
+}
+
+func toGQLCustomer(c *db.Customer) *Customer {
+	return &Customer{
+		ID:        c.ID.String(),
+		Name:      c.Name,
+		Email:     c.Email,
+		Phone:     c.Phone,
+		Locale:    c.Locale,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+func toGQLStockAdjustment(a *db.StockAdjustment) *StockAdjustment {
+	out := &StockAdjustment{
+		ID:            a.ID.String(),
+		ProductID:     a.ProductID.String(),
+		Delta:         a.Delta,
+		QuantityAfter: a.QuantityAfter,
+		Reason:        a.Reason,
+		ActorUID:      a.ActorUID,
+		CreatedAt:     a.CreatedAt,
+	}
+	if a.OrderID != nil {
+		oid := a.OrderID.String()
+		out.OrderID = &oid
+	}
+	return out
+}
+
+func toGQLNotification(m *db.OutboxMessage) *Notification {
+	out := &Notification{
+		ID:               m.ID.String(),
+		Channel:          NotificationChannel(strings.ToUpper(m.Channel)),
+		Recipient:        m.Recipient,
+		Subject:          m.Subject,
+		Status:           NotificationStatus(strings.ToUpper(m.Status)),
+		Attempts:         m.Attempts,
+		LastError:        m.LastError,
+		NextAttemptAt:    m.NextAttemptAt,
+		CreatedAt:        m.CreatedAt,
+		SentAt:           m.SentAt,
+		SuppressedReason: m.SuppressedReason,
+	}
+	if m.OrderID != nil {
+		oid := m.OrderID.String()
+		out.OrderID = &oid
+	}
+	return out
+}
+
+func toGQLNotifications(msgs []*db.OutboxMessage) []*Notification {
+	out := make([]*Notification, len(msgs))
+	for i, m := range msgs {
+		out[i] = toGQLNotification(m)
+	}
+	return out
+}
+
+func toGQLNotificationPreferences(p *db.NotificationPreferences) *NotificationPreferences {
+	out := &NotificationPreferences{
+		Channels:       make([]NotificationChannel, len(p.Channels)),
+		OptedOutEvents: make([]NotificationEvent, len(p.OptedOutEvents)),
+		UpdatedAt:      p.UpdatedAt,
+	}
+	for i, ch := range p.Channels {
+		out.Channels[i] = NotificationChannel(strings.ToUpper(ch))
+	}
+	for i, e := range p.OptedOutEvents {
+		out.OptedOutEvents[i] = NotificationEvent(strings.ToUpper(e))
+	}
+	if p.QuietStart != nil && p.QuietEnd != nil {
+		out.QuietHours = &QuietHours{
+			Start:    formatClock(*p.QuietStart),
+			End:      formatClock(*p.QuietEnd),
+			TimeZone: p.TimeZone,
+		}
+	}
+	return out
+}
+
+// formatClock turns minutes after midnight into "HH:MM".
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func toGQLNotificationPreview(r *templates.Rendered) *NotificationPreview {
+	out := &NotificationPreview{
+		Event:   NotificationEvent(strings.ToUpper(string(r.Event))),
+		Locale:  r.Locale,
+		Subject: r.Subject,
+		Text:    r.Text,
+	}
+	if r.HTML != "" {
+		out.HTML = &r.HTML
+	}
+	if r.SMS != "" {
+		out.Sms = &r.SMS
+	}
+	return out
+}
+
+// Webhook event types are "order.status_changed" in the database and
+// ORDER_STATUS_CHANGED in the schema.
+func toGQLWebhookEventType(t string) WebhookEventType {
+	return WebhookEventType(strings.ToUpper(strings.Replace(t, ".", "_", 1)))
+}
+
+func toDBWebhookEventType(t WebhookEventType) string {
+	return strings.ToLower(strings.Replace(string(t), "_", ".", 1))
+}
+
+func toGQLWebhookSubscription(s *db.WebhookSubscription) *WebhookSubscription {
+	out := &WebhookSubscription{
+		ID:         s.ID.String(),
+		URL:        s.URL,
+		EventTypes: make([]WebhookEventType, len(s.EventTypes)),
+		Active:     s.Active,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+	}
+	for i, t := range s.EventTypes {
+		out.EventTypes[i] = toGQLWebhookEventType(t)
+	}
+	return out
+}
+
+func toGQLWebhookDelivery(d *db.WebhookDelivery) *WebhookDelivery {
+	out := &WebhookDelivery{
+		ID:             d.ID.String(),
+		SubscriptionID: d.SubscriptionID.String(),
+		EventID:        d.EventID.String(),
+		EventType:      toGQLWebhookEventType(d.EventType),
+		Payload:        string(d.Payload),
+		Status:         WebhookDeliveryStatus(strings.ToUpper(d.Status)),
+		Attempts:       d.Attempts,
+		LastError:      d.LastError,
+		NextAttemptAt:  d.NextAttemptAt,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+	if d.OrderID != nil {
+		oid := d.OrderID.String()
+		out.OrderID = &oid
+	}
+	return out
+}
+
+func toGQLWebhookAttempt(a *db.WebhookAttempt) *WebhookAttempt {
+	return &WebhookAttempt{
+		ID:         a.ID.String(),
+		StatusCode: a.StatusCode,
+		Error:      a.Error,
+		DurationMs: a.DurationMS,
+		CreatedAt:  a.CreatedAt,
+	}
+}
@@ -3,14 +3,49 @@
 package graphql
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
 	"time"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
 )
 
 type Category struct {
-	ID       string      `json:"id"`
-	Name     string      `json:"name"`
-	Parent   *Category   `json:"parent,omitempty"`
-	Children []*Category `json:"children"`
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Parent       *Category   `json:"parent,omitempty"`
+	Children     []*Category `json:"children"`
+	Ancestors    []*Category `json:"ancestors"`
+	ProductCount int         `json:"productCount"`
+}
+
+type CategoryEdge struct {
+	Cursor string    `json:"cursor"`
+	Node   *Category `json:"node"`
+}
+
+type CategoryTreeNode struct {
+	Category *Category `json:"category"`
+	Depth    int       `json:"depth"`
+	Path     []string  `json:"path"`
+}
+
+type Customer struct {
+	ID                      string                   `json:"id"`
+	Name                    string                   `json:"name"`
+	Email                   string                   `json:"email"`
+	Phone                   *string                  `json:"phone,omitempty"`
+	Locale                  string                   `json:"locale"`
+	NotificationPreferences *NotificationPreferences `json:"notificationPreferences"`
+	CreatedAt               time.Time                `json:"createdAt"`
+	UpdatedAt               time.Time                `json:"updatedAt"`
+}
+
+type CustomerEdge struct {
+	Cursor string    `json:"cursor"`
+	Node   *Customer `json:"node"`
 }
 
 type Mutation struct {
@@ -21,31 +56,93 @@ type NewCategory struct {
 	ParentID *string `json:"parentID,omitempty"`
 }
 
+type NewCustomer struct {
+	Name   string  `json:"name"`
+	Email  string  `json:"email"`
+	Phone  *string `json:"phone,omitempty"`
+	Locale *string `json:"locale,omitempty"`
+}
+
 type NewProduct struct {
-	Name        string  `json:"name"`
-	Description *string `json:"description,omitempty"`
-	Price       float64 `json:"price"`
-	CategoryID  string  `json:"categoryID"`
+	Name        string      `json:"name"`
+	Description *string     `json:"description,omitempty"`
+	Price       money.Money `json:"price"`
+	CategoryID  string      `json:"categoryID"`
+}
+
+type NewWebhookSubscription struct {
+	URL        string             `json:"url"`
+	Secret     string             `json:"secret"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+}
+
+type Notification struct {
+	ID               string              `json:"id"`
+	OrderID          *string             `json:"orderID,omitempty"`
+	Channel          NotificationChannel `json:"channel"`
+	Recipient        string              `json:"recipient"`
+	Subject          string              `json:"subject"`
+	Status           NotificationStatus  `json:"status"`
+	Attempts         int                 `json:"attempts"`
+	LastError        *string             `json:"lastError,omitempty"`
+	NextAttemptAt    time.Time           `json:"nextAttemptAt"`
+	CreatedAt        time.Time           `json:"createdAt"`
+	SentAt           *time.Time          `json:"sentAt,omitempty"`
+	SuppressedReason *string             `json:"suppressedReason,omitempty"`
+}
+
+type NotificationPreferences struct {
+	Channels       []NotificationChannel `json:"channels"`
+	OptedOutEvents []NotificationEvent   `json:"optedOutEvents"`
+	QuietHours     *QuietHours           `json:"quietHours,omitempty"`
+	UpdatedAt      *time.Time            `json:"updatedAt,omitempty"`
+}
+
+type NotificationPreferencesInput struct {
+	Channels       []NotificationChannel `json:"channels"`
+	OptedOutEvents []NotificationEvent   `json:"optedOutEvents"`
+	QuietHours     *QuietHoursInput      `json:"quietHours,omitempty"`
+}
+
+type NotificationPreview struct {
+	Event   NotificationEvent `json:"event"`
+	Locale  string            `json:"locale"`
+	Subject string            `json:"subject"`
+	Text    string            `json:"text"`
+	HTML    *string           `json:"html,omitempty"`
+	Sms     *string           `json:"sms,omitempty"`
 }
 
 type Order struct {
-	ID         string       `json:"id"`
-	CustomerID string       `json:"customerID"`
-	Items      []*OrderItem `json:"items"`
-	Total      float64      `json:"total"`
-	CreatedAt  time.Time    `json:"createdAt"`
+	ID            string               `json:"id"`
+	CustomerID    string               `json:"customerID"`
+	Items         []*OrderItem         `json:"items"`
+	Total         money.Money          `json:"total"`
+	Status        OrderStatus          `json:"status"`
+	StatusHistory []*OrderStatusChange `json:"statusHistory"`
+	Notifications []*Notification      `json:"notifications"`
+	CancelReason  *CancelReason        `json:"cancelReason,omitempty"`
+	CancelNote    *string              `json:"cancelNote,omitempty"`
+	CancelledAt   *time.Time           `json:"cancelledAt,omitempty"`
+	CreatedAt     time.Time            `json:"createdAt"`
+}
+
+type OrderEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Order `json:"node"`
 }
 
 type OrderInput struct {
-	CustomerID string            `json:"customerID"`
-	Items      []*OrderItemInput `json:"items"`
+	CustomerID     *string           `json:"customerID,omitempty"`
+	Items          []*OrderItemInput `json:"items"`
+	IdempotencyKey *string           `json:"idempotencyKey,omitempty"`
 }
 
 type OrderItem struct {
-	ID       string   `json:"id"`
-	Product  *Product `json:"product"`
-	Quantity int      `json:"quantity"`
-	Price    float64  `json:"price"`
+	ID       string      `json:"id"`
+	Product  *Product    `json:"product"`
+	Quantity int         `json:"quantity"`
+	Price    money.Money `json:"price"`
 }
 
 type OrderItemInput struct {
@@ -53,13 +150,663 @@ type OrderItemInput struct {
 	Quantity  int    `json:"quantity"`
 }
 
+type OrderStatusChange struct {
+	From      OrderStatus `json:"from"`
+	To        OrderStatus `json:"to"`
+	ActorUID  string      `json:"actorUID"`
+	ChangedAt time.Time   `json:"changedAt"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
+type PriceBucket struct {
+	Min   money.Money `json:"min"`
+	Max   money.Money `json:"max"`
+	Count int         `json:"count"`
+}
+
+type PriceStats struct {
+	Count     int            `json:"count"`
+	Min       *money.Money   `json:"min,omitempty"`
+	Max       *money.Money   `json:"max,omitempty"`
+	Mean      *money.Money   `json:"mean,omitempty"`
+	Median    *money.Money   `json:"median,omitempty"`
+	P90       *money.Money   `json:"p90,omitempty"`
+	StdDev    *money.Money   `json:"stdDev,omitempty"`
+	Histogram []*PriceBucket `json:"histogram"`
+}
+
 type Product struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description *string   `json:"description,omitempty"`
-	Price       float64   `json:"price"`
-	Category    *Category `json:"category"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description *string     `json:"description,omitempty"`
+	Price       money.Money `json:"price"`
+	Category    *Category   `json:"category"`
+	Stock       int         `json:"stock"`
+	ArchivedAt  *time.Time  `json:"archivedAt,omitempty"`
+}
+
+type ProductEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Product `json:"node"`
 }
 
 type Query struct {
 }
+
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"timeZone"`
+}
+
+type QuietHoursInput struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"timeZone"`
+}
+
+type StockAdjustment struct {
+	ID            string    `json:"id"`
+	ProductID     string    `json:"productID"`
+	Delta         int       `json:"delta"`
+	QuantityAfter int       `json:"quantityAfter"`
+	Reason        string    `json:"reason"`
+	OrderID       *string   `json:"orderID,omitempty"`
+	ActorUID      *string   `json:"actorUID,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type StockAdjustmentInput struct {
+	ProductID string `json:"productID"`
+	Delta     int    `json:"delta"`
+	Reason    string `json:"reason"`
+}
+
+type StockLevel struct {
+	Product  *Product `json:"product"`
+	Quantity int      `json:"quantity"`
+}
+
+type UpdateCustomer struct {
+	Name   *string `json:"name,omitempty"`
+	Email  *string `json:"email,omitempty"`
+	Phone  *string `json:"phone,omitempty"`
+	Locale *string `json:"locale,omitempty"`
+}
+
+type UpdateProduct struct {
+	Name        *string      `json:"name,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	Price       *money.Money `json:"price,omitempty"`
+	CategoryID  *string      `json:"categoryID,omitempty"`
+}
+
+type UpdateWebhookSubscription struct {
+	URL        *string            `json:"url,omitempty"`
+	Secret     *string            `json:"secret,omitempty"`
+	EventTypes []WebhookEventType `json:"eventTypes,omitempty"`
+	Active     *bool              `json:"active,omitempty"`
+}
+
+type WebhookAttempt struct {
+	ID         string    `json:"id"`
+	StatusCode *int      `json:"statusCode,omitempty"`
+	Error      *string   `json:"error,omitempty"`
+	DurationMs int       `json:"durationMs"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscriptionID"`
+	EventID        string                `json:"eventID"`
+	EventType      WebhookEventType      `json:"eventType"`
+	OrderID        *string               `json:"orderID,omitempty"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastError      *string               `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time             `json:"nextAttemptAt"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+	AttemptLog     []*WebhookAttempt     `json:"attemptLog"`
+}
+
+type WebhookSubscription struct {
+	ID         string             `json:"id"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+	Active     bool               `json:"active"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+type CancelReason string
+
+const (
+	CancelReasonOrderedByMistake      CancelReason = "ORDERED_BY_MISTAKE"
+	CancelReasonFoundCheaperElsewhere CancelReason = "FOUND_CHEAPER_ELSEWHERE"
+	CancelReasonDeliveryTooSlow       CancelReason = "DELIVERY_TOO_SLOW"
+	CancelReasonNoLongerNeeded        CancelReason = "NO_LONGER_NEEDED"
+	CancelReasonPaymentIssue          CancelReason = "PAYMENT_ISSUE"
+	CancelReasonOther                 CancelReason = "OTHER"
+)
+
+var AllCancelReason = []CancelReason{
+	CancelReasonOrderedByMistake,
+	CancelReasonFoundCheaperElsewhere,
+	CancelReasonDeliveryTooSlow,
+	CancelReasonNoLongerNeeded,
+	CancelReasonPaymentIssue,
+	CancelReasonOther,
+}
+
+func (e CancelReason) IsValid() bool {
+	switch e {
+	case CancelReasonOrderedByMistake, CancelReasonFoundCheaperElsewhere, CancelReasonDeliveryTooSlow, CancelReasonNoLongerNeeded, CancelReasonPaymentIssue, CancelReasonOther:
+		return true
+	}
+	return false
+}
+
+func (e CancelReason) String() string {
+	return string(e)
+}
+
+func (e *CancelReason) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CancelReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CancelReason", str)
+	}
+	return nil
+}
+
+func (e CancelReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *CancelReason) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e CancelReason) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type CategoryDeleteStrategy string
+
+const (
+	CategoryDeleteStrategyRestrict CategoryDeleteStrategy = "RESTRICT"
+	CategoryDeleteStrategyReparent CategoryDeleteStrategy = "REPARENT"
+	CategoryDeleteStrategyCascade  CategoryDeleteStrategy = "CASCADE"
+)
+
+var AllCategoryDeleteStrategy = []CategoryDeleteStrategy{
+	CategoryDeleteStrategyRestrict,
+	CategoryDeleteStrategyReparent,
+	CategoryDeleteStrategyCascade,
+}
+
+func (e CategoryDeleteStrategy) IsValid() bool {
+	switch e {
+	case CategoryDeleteStrategyRestrict, CategoryDeleteStrategyReparent, CategoryDeleteStrategyCascade:
+		return true
+	}
+	return false
+}
+
+func (e CategoryDeleteStrategy) String() string {
+	return string(e)
+}
+
+func (e *CategoryDeleteStrategy) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CategoryDeleteStrategy(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CategoryDeleteStrategy", str)
+	}
+	return nil
+}
+
+func (e CategoryDeleteStrategy) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *CategoryDeleteStrategy) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e CategoryDeleteStrategy) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail NotificationChannel = "EMAIL"
+	NotificationChannelSms   NotificationChannel = "SMS"
+)
+
+var AllNotificationChannel = []NotificationChannel{
+	NotificationChannelEmail,
+	NotificationChannelSms,
+}
+
+func (e NotificationChannel) IsValid() bool {
+	switch e {
+	case NotificationChannelEmail, NotificationChannelSms:
+		return true
+	}
+	return false
+}
+
+func (e NotificationChannel) String() string {
+	return string(e)
+}
+
+func (e *NotificationChannel) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationChannel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationChannel", str)
+	}
+	return nil
+}
+
+func (e NotificationChannel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationChannel) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationChannel) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationEvent string
+
+const (
+	NotificationEventOrderPlaced    NotificationEvent = "ORDER_PLACED"
+	NotificationEventOrderCancelled NotificationEvent = "ORDER_CANCELLED"
+)
+
+var AllNotificationEvent = []NotificationEvent{
+	NotificationEventOrderPlaced,
+	NotificationEventOrderCancelled,
+}
+
+func (e NotificationEvent) IsValid() bool {
+	switch e {
+	case NotificationEventOrderPlaced, NotificationEventOrderCancelled:
+		return true
+	}
+	return false
+}
+
+func (e NotificationEvent) String() string {
+	return string(e)
+}
+
+func (e *NotificationEvent) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationEvent", str)
+	}
+	return nil
+}
+
+func (e NotificationEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationEvent) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationEvent) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending    NotificationStatus = "PENDING"
+	NotificationStatusSent       NotificationStatus = "SENT"
+	NotificationStatusDead       NotificationStatus = "DEAD"
+	NotificationStatusSuppressed NotificationStatus = "SUPPRESSED"
+)
+
+var AllNotificationStatus = []NotificationStatus{
+	NotificationStatusPending,
+	NotificationStatusSent,
+	NotificationStatusDead,
+	NotificationStatusSuppressed,
+}
+
+func (e NotificationStatus) IsValid() bool {
+	switch e {
+	case NotificationStatusPending, NotificationStatusSent, NotificationStatusDead, NotificationStatusSuppressed:
+		return true
+	}
+	return false
+}
+
+func (e NotificationStatus) String() string {
+	return string(e)
+}
+
+func (e *NotificationStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationStatus", str)
+	}
+	return nil
+}
+
+func (e NotificationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusPending   OrderStatus = "PENDING"
+	OrderStatusPaid      OrderStatus = "PAID"
+	OrderStatusFulfilled OrderStatus = "FULFILLED"
+	OrderStatusShipped   OrderStatus = "SHIPPED"
+	OrderStatusDelivered OrderStatus = "DELIVERED"
+	OrderStatusCancelled OrderStatus = "CANCELLED"
+	OrderStatusRefunded  OrderStatus = "REFUNDED"
+)
+
+var AllOrderStatus = []OrderStatus{
+	OrderStatusPending,
+	OrderStatusPaid,
+	OrderStatusFulfilled,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+}
+
+func (e OrderStatus) IsValid() bool {
+	switch e {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusFulfilled, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (e OrderStatus) String() string {
+	return string(e)
+}
+
+func (e *OrderStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderStatus", str)
+	}
+	return nil
+}
+
+func (e OrderStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OrderStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OrderStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RoleAdmin    Role = "ADMIN"
+	RoleCustomer Role = "CUSTOMER"
+)
+
+var AllRole = []Role{
+	RoleAdmin,
+	RoleCustomer,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleAdmin, RoleCustomer:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "DEAD"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusDead,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e WebhookDeliveryStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type WebhookEventType string
+
+const (
+	WebhookEventTypeOrderPlaced        WebhookEventType = "ORDER_PLACED"
+	WebhookEventTypeOrderStatusChanged WebhookEventType = "ORDER_STATUS_CHANGED"
+	WebhookEventTypeOrderCancelled     WebhookEventType = "ORDER_CANCELLED"
+)
+
+var AllWebhookEventType = []WebhookEventType{
+	WebhookEventTypeOrderPlaced,
+	WebhookEventTypeOrderStatusChanged,
+	WebhookEventTypeOrderCancelled,
+}
+
+func (e WebhookEventType) IsValid() bool {
+	switch e {
+	case WebhookEventTypeOrderPlaced, WebhookEventTypeOrderStatusChanged, WebhookEventTypeOrderCancelled:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEventType) String() string {
+	return string(e)
+}
+
+func (e *WebhookEventType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEventType", str)
+	}
+	return nil
+}
+
+func (e WebhookEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *WebhookEventType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e WebhookEventType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,53 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/auth"
+)
+
+func TestOrderOwnership(t *testing.T) {
+	r, repos := newTestResolver(t)
+	q, m := &queryResolver{r}, &mutationResolver{r}
+	p := seedProduct(t, repos, "2.50", 10)
+	owner := customerCtx("uid-1", "buyer@example.com")
+	stranger := customerCtx("uid-2", "other@example.com")
+	// the stranger is a registered customer too
+	if _, err := m.PlaceOrder(stranger, OrderInput{Items: []*OrderItemInput{{ProductID: p.ID.String(), Quantity: 1}}}); err != nil {
+		t.Fatalf("PlaceOrder by the stranger: %v", err)
+	}
+
+	placed, err := m.PlaceOrder(owner, OrderInput{Items: []*OrderItemInput{{ProductID: p.ID.String(), Quantity: 2}}})
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	wantStock(t, repos, p.ID, 7)
+
+	if o, err := q.Order(owner, placed.ID); err != nil || o == nil || o.ID != placed.ID {
+		t.Errorf("order as owner = %v, %v; want order %s", o, err, placed.ID)
+	}
+	admin := auth.NewContext(context.Background(), &auth.Claims{UID: "admin-1", Roles: []string{"admin"}})
+	if o, err := q.Order(admin, placed.ID); err != nil || o == nil || o.ID != placed.ID {
+		t.Errorf("order as admin = %v, %v; want order %s", o, err, placed.ID)
+	}
+
+	// another customer can neither see nor cancel the order, and is not
+	// told it exists
+	if o, err := q.Order(stranger, placed.ID); err != nil || o != nil {
+		t.Errorf("order as another customer = %v, %v; want null", o, err)
+	}
+	if _, err := m.CancelOrder(stranger, placed.ID, CancelReasonNoLongerNeeded, nil); apperror.CodeOf(err) != apperror.CodeNotFound {
+		t.Errorf("cancelOrder as another customer error = %v, want %s", err, apperror.CodeNotFound)
+	}
+	if o, err := q.Order(owner, placed.ID); err != nil || o == nil || o.Status != OrderStatusPending {
+		t.Errorf("order after a stranger's cancelOrder = %v, %v; want it still pending", o, err)
+	}
+	wantStock(t, repos, p.ID, 7)
+
+	if o, err := m.CancelOrder(owner, placed.ID, CancelReasonNoLongerNeeded, nil); err != nil || o.Status != OrderStatusCancelled {
+		t.Errorf("cancelOrder as owner = %v, %v; want it cancelled", o, err)
+	}
+	wantStock(t, repos, p.ID, 9)
+}
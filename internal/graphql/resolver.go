@@ -1,29 +1,136 @@
+//go:generate go run github.com/99designs/gqlgen generate
+
 package graphql
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
 	"github.com/felixojiambo/go-graphql-order-service/internal/db"
-	"github.com/felixojiambo/go-graphql-order-service/internal/notification"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification/templates"
 )
 
 // Resolver is the root dependency‐injection struct for all GraphQL resolvers.
 
 type Resolver struct {
-	CategoryRepo    db.CategoryRepository
-	ProductRepo     db.ProductRepository
-	OrderRepo       db.OrderRepository
-	NotificationSvc notification.NotificationService
+	CategoryRepo  db.CategoryRepository
+	ProductRepo   db.ProductRepository
+	OrderRepo     db.OrderRepository
+	CustomerRepo  db.CustomerRepository
+	InventoryRepo db.InventoryRepository
+	OutboxRepo    db.OutboxRepository
+	WebhookRepo   db.WebhookRepository
+	PrefsRepo     db.NotificationPreferenceRepository
+
+	// Templates renders the notifications enqueued for order events.
+	Templates *templates.Registry
+
+	// IdempotencyTTL is how long a placeOrder idempotency key stays bound to
+	// its order.
+	IdempotencyTTL time.Duration
 }
 
+// DefaultIdempotencyTTL is the IdempotencyTTL set by NewResolver.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
 func NewResolver(
 	cat db.CategoryRepository,
 	prod db.ProductRepository,
 	ord db.OrderRepository,
-	notif notification.NotificationService,
+	cust db.CustomerRepository,
+	inv db.InventoryRepository,
+	outbox db.OutboxRepository,
+	hooks db.WebhookRepository,
+	prefs db.NotificationPreferenceRepository,
 ) *Resolver {
 	return &Resolver{
-		CategoryRepo:    cat,
-		ProductRepo:     prod,
-		OrderRepo:       ord,
-		NotificationSvc: notif,
+		CategoryRepo:   cat,
+		ProductRepo:    prod,
+		OrderRepo:      ord,
+		CustomerRepo:   cust,
+		InventoryRepo:  inv,
+		OutboxRepo:     outbox,
+		WebhookRepo:    hooks,
+		PrefsRepo:      prefs,
+		Templates:      templates.Default(),
+		IdempotencyTTL: DefaultIdempotencyTTL,
+	}
+}
+
+// orderView builds the template view of o, loading the products of its
+// items.
+func (r *Resolver) orderView(ctx context.Context, o *db.Order, items []*db.OrderItem, cust *db.Customer) (templates.OrderView, error) {
+	ids := make([]uuid.UUID, len(items))
+	for i, it := range items {
+		ids[i] = it.ProductID
+	}
+	prods, err := r.ProductRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return templates.OrderView{}, fmt.Errorf("lookup order products: %w", err)
+	}
+	products := make(map[uuid.UUID]*db.Product, len(prods))
+	for _, p := range prods {
+		products[p.ID] = p
+	}
+	return templates.NewOrderView(o, items, products, cust), nil
+}
+
+// orderNotifications renders event for v in the customer's locale and
+// returns the outbox messages to enqueue with it: an email, and an SMS when
+// the customer has a phone number and the event an SMS template. They are
+// delivered by the outbox dispatcher once the enqueuing transaction commits,
+// subject to the customer's notification preferences at that time.
+func (r *Resolver) orderNotifications(event templates.Event, orderID uuid.UUID, cust *db.Customer, v templates.OrderView) ([]*db.OutboxMessage, error) {
+	content, err := r.Templates.Render(event, cust.Locale, v)
+	if err != nil {
+		return nil, fmt.Errorf("render %s notification: %w", event, err)
+	}
+	msgs := []*db.OutboxMessage{{
+		ID:         uuid.New(),
+		OrderID:    &orderID,
+		CustomerID: &cust.ID,
+		Event:      string(event),
+		Channel:    db.ChannelEmail,
+		Recipient:  cust.Email,
+		Subject:    content.Subject,
+		Body:       content.Text,
+		HTMLBody:   content.HTML,
+	}}
+	if cust.Phone != nil && content.SMS != "" {
+		msgs = append(msgs, &db.OutboxMessage{
+			ID:         uuid.New(),
+			OrderID:    &orderID,
+			CustomerID: &cust.ID,
+			Event:      string(event),
+			Channel:    db.ChannelSMS,
+			Recipient:  *cust.Phone,
+			Body:       content.SMS,
+		})
+	}
+	return msgs, nil
+}
+
+// listOrders returns one page of a customer's orders.
+func (r *Resolver) listOrders(ctx context.Context, customerID uuid.UUID, first *int, after *string) (*OrderConnection, error) {
+	page, err := pageRequest(first, after)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := r.OrderRepo.ListByCustomer(ctx, customerID, page)
+	if err != nil {
+		return nil, err
 	}
+	edges, info := paginate(orders, page, orderCursor, func(cursor string, o *db.Order) *OrderEdge {
+		return &OrderEdge{Cursor: cursor, Node: toGQLOrder(o, nil)}
+	})
+	return &OrderConnection{
+		Edges:    edges,
+		PageInfo: info,
+		count: func(ctx context.Context) (int, error) {
+			return r.OrderRepo.CountByCustomer(ctx, customerID)
+		},
+	}, nil
 }
@@ -0,0 +1,67 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/auth"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/dbtest"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+)
+
+// newTestResolver returns a Resolver over a fresh in-memory backend, and the
+// repositories for seeding and inspecting it.
+func newTestResolver(t *testing.T) (*Resolver, dbtest.Repositories) {
+	t.Helper()
+	repos := dbtest.Memory(t)
+	r := NewResolver(repos.Categories, repos.Products, repos.Orders, repos.Customers,
+		repos.Inventory, repos.Outbox, repos.Webhooks, repos.Preferences)
+	return r, repos
+}
+
+// customerCtx returns a context authenticated as a customer with a verified
+// email, who is registered on first use.
+func customerCtx(uid, email string) context.Context {
+	return auth.NewContext(context.Background(), &auth.Claims{
+		UID:           uid,
+		Email:         email,
+		EmailVerified: true,
+		Roles:         []string{"customer"},
+	})
+}
+
+// seedProduct stores a product with stock in a new category.
+func seedProduct(t *testing.T, repos dbtest.Repositories, price string, stock int) *db.Product {
+	t.Helper()
+	ctx := context.Background()
+	cat := &db.Category{ID: uuid.New(), Name: "cat"}
+	if err := repos.Categories.Create(ctx, cat); err != nil {
+		t.Fatal(err)
+	}
+	p := &db.Product{
+		ID:         uuid.New(),
+		Name:       "product",
+		Price:      money.MustParse(price, money.DefaultCurrency),
+		CategoryID: cat.ID,
+		Stock:      stock,
+	}
+	if err := repos.Products.Create(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// wantStock fails t unless productID has want units in stock.
+func wantStock(t *testing.T, repos dbtest.Repositories, productID uuid.UUID, want int) {
+	t.Helper()
+	p, err := repos.Products.GetByID(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock != want {
+		t.Errorf("stock = %d, want %d", p.Stock, want)
+	}
+}
@@ -1,54 +1,125 @@
-// internal/graphql/schema.resolvers.go
 package graphql
 
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.75
+
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
 	"github.com/felixojiambo/go-graphql-order-service/internal/auth"
 	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/domain"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification/templates"
+	"github.com/felixojiambo/go-graphql-order-service/internal/webhook"
 	"github.com/google/uuid"
 )
 
+// Parent resolves the full parent of a Category, or null for a root.
+func (r *categoryResolver) Parent(ctx context.Context, obj *Category) (*Category, error) {
+	if obj.Parent == nil {
+		return nil, nil
+	}
+	pid, err := uuid.Parse(obj.Parent.ID)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := r.loadCategory(ctx, pid)
+	if err != nil {
+		return nil, fmt.Errorf("lookup category %s: %w", obj.Parent.ID, err)
+	}
+	return toGQLCategory(parent), nil
+}
+
 // Children resolves immediate subcategories for a Category.
 // This is unprotected (any authenticated user can list the category tree).
+// The children of all categories in a response are loaded in one batch.
 func (r *categoryResolver) Children(ctx context.Context, obj *Category) ([]*Category, error) {
-	// (Optional) You could check a role here if you needed to restrict “viewing” the tree.
-	// e.g. if !auth.HasRole(ctx, "viewer") { return nil, errors.New("unauthorized") }
-
 	cid, err := uuid.Parse(obj.ID)
 	if err != nil {
 		return nil, err
 	}
-	subs, err := r.CategoryRepo.ListChildren(ctx, &cid)
+	subs, err := r.loaders(ctx).CategoryChildren.Load(ctx, cid)
 	if err != nil {
 		return nil, err
 	}
 	out := make([]*Category, len(subs))
 	for i, sub := range subs {
-		out[i] = &Category{
-			ID:     sub.ID.String(),
-			Name:   sub.Name,
-			Parent: &Category{ID: obj.ID},
-		}
+		out[i] = toGQLCategory(sub)
+	}
+	return out, nil
+}
+
+// Ancestors resolves the breadcrumb of a Category, root first. The
+// ancestors of all categories in a response are loaded in one batch.
+func (r *categoryResolver) Ancestors(ctx context.Context, obj *Category) ([]*Category, error) {
+	cid, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	cats, err := r.loaders(ctx).CategoryAncestors.Load(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Category, len(cats))
+	for i, c := range cats {
+		out[i] = toGQLCategory(c)
 	}
 	return out, nil
 }
 
+// ProductCount resolves the number of unarchived products in a Category's
+// subtree, batched like Ancestors.
+func (r *categoryResolver) ProductCount(ctx context.Context, obj *Category) (int, error) {
+	cid, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return 0, err
+	}
+	return r.loaders(ctx).CategoryProductCount.Load(ctx, cid)
+}
+
+// TotalCount runs the connection's count query.
+func (r *categoryConnectionResolver) TotalCount(ctx context.Context, obj *CategoryConnection) (int, error) {
+	return obj.count(ctx)
+}
+
+// NotificationPreferences returns what the customer wants to be notified
+// about; customers who never set preferences get the defaults.
+func (r *customerResolver) NotificationPreferences(ctx context.Context, obj *Customer) (*NotificationPreferences, error) {
+	cid, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !r.canAccessCustomer(ctx, cid) {
+		return nil, apperror.Forbidden("may only read your own notification preferences")
+	}
+	p, err := r.PrefsRepo.Get(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLNotificationPreferences(p), nil
+}
+
+// TotalCount runs the connection's count query.
+func (r *customerConnectionResolver) TotalCount(ctx context.Context, obj *CustomerConnection) (int, error) {
+	return obj.count(ctx)
+}
+
 // CreateCategory persists a new category.
 // Only users with the “admin” role may create a category.
 func (r *mutationResolver) CreateCategory(ctx context.Context, input NewCategory) (*Category, error) {
-	if !auth.HasRole(ctx, "admin") {
-		return nil, errors.New("unauthorized: must have 'admin' role to create categories")
-	}
-
 	var parentID *uuid.UUID
 	if input.ParentID != nil {
 		pid, err := uuid.Parse(*input.ParentID)
 		if err != nil {
-			return nil, errors.New("invalid parentID")
+			return nil, apperror.Validation("invalid parentID")
 		}
 		parentID = &pid
 	}
@@ -62,26 +133,88 @@ func (r *mutationResolver) CreateCategory(ctx context.Context, input NewCategory
 		return nil, err
 	}
 
-	gqlCat := &Category{
-		ID:   dbCat.ID.String(),
-		Name: dbCat.Name,
+	return toGQLCategory(dbCat), nil
+}
+
+// RenameCategory changes a category's name.
+// Only users with the “admin” role may rename categories.
+func (r *mutationResolver) RenameCategory(ctx context.Context, id string, name string) (*Category, error) {
+	cid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid category id")
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		return nil, apperror.Validation("name must not be empty")
+	}
+	c, err := r.CategoryRepo.Rename(ctx, cid, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("category %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLCategory(c), nil
+}
+
+// MoveCategory gives a category a new parent, or makes it a root when
+// newParentID is null. Moving it under itself or a descendant fails.
+// Only users with the “admin” role may move categories.
+func (r *mutationResolver) MoveCategory(ctx context.Context, id string, newParentID *string) (*Category, error) {
+	cid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid category id")
+	}
+	var parentID *uuid.UUID
+	if newParentID != nil {
+		pid, err := uuid.Parse(*newParentID)
+		if err != nil {
+			return nil, apperror.Validation("invalid newParentID")
+		}
+		parentID = &pid
+	}
+	c, err := r.CategoryRepo.Move(ctx, cid, parentID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) && newParentID != nil:
+		return nil, apperror.NotFound("category %s or parent %s not found", id, *newParentID)
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, apperror.NotFound("category %s not found", id)
+	case err != nil:
+		return nil, err
+	}
+	return toGQLCategory(c), nil
+}
+
+// DeleteCategory removes a category, dealing with its subcategories and
+// products as strategy says, and returns its ID.
+// Only users with the “admin” role may delete categories.
+func (r *mutationResolver) DeleteCategory(ctx context.Context, id string, strategy CategoryDeleteStrategy) (string, error) {
+	cid, err := uuid.Parse(id)
+	if err != nil {
+		return "", apperror.Validation("invalid category id")
 	}
-	if parentID != nil {
-		gqlCat.Parent = &Category{ID: parentID.String()}
+	err = r.CategoryRepo.Delete(ctx, cid, db.CategoryDeleteStrategy(strings.ToLower(string(strategy))))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", apperror.NotFound("category %s not found", id)
+	case errors.Is(err, db.ErrCategoryInUse):
+		return "", apperror.Conflict("category %s still has subcategories or products", id)
+	case errors.Is(err, db.ErrProductInUse):
+		return "", apperror.Conflict("category %s has products referenced by orders; archive them instead", id)
+	case err != nil:
+		return "", err
 	}
-	return gqlCat, nil
+	return cid.String(), nil
 }
 
 // CreateProduct persists a new product.
 // Only users with the “admin” role may create products.
 func (r *mutationResolver) CreateProduct(ctx context.Context, input NewProduct) (*Product, error) {
-	if !auth.HasRole(ctx, "admin") {
-		return nil, errors.New("unauthorized: must have 'admin' role to create products")
-	}
-
 	catID, err := uuid.Parse(input.CategoryID)
 	if err != nil {
-		return nil, errors.New("invalid categoryID")
+		return nil, apperror.Validation("invalid categoryID")
+	}
+	if err := validatePrice(input.Price); err != nil {
+		return nil, err
 	}
 
 	prod := &db.Product{
@@ -95,151 +228,1171 @@ func (r *mutationResolver) CreateProduct(ctx context.Context, input NewProduct)
 		return nil, err
 	}
 
-	return &Product{
-		ID:          prod.ID.String(),
-		Name:        prod.Name,
-		Description: prod.Description,
-		Price:       prod.Price,
-		Category:    &Category{ID: prod.CategoryID.String()},
-	}, nil
+	return toGQLProduct(prod), nil
 }
 
-// Categories returns all root categories.
-// Any authenticated user can call this.
-func (r *queryResolver) Categories(ctx context.Context) ([]*Category, error) {
-	// (Optional) you could require a particular role here
-	dbCats, err := r.CategoryRepo.ListChildren(ctx, nil)
+// UpdateProduct changes the given fields of a product.
+// Only users with the “admin” role may update products.
+func (r *mutationResolver) UpdateProduct(ctx context.Context, id string, input UpdateProduct) (*Product, error) {
+	pid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid product id")
+	}
+	p, err := r.ProductRepo.GetByID(ctx, pid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("product %s not found", id)
+	}
 	if err != nil {
 		return nil, err
 	}
-	out := make([]*Category, len(dbCats))
-	for i, c := range dbCats {
-		out[i] = &Category{
-			ID:   c.ID.String(),
-			Name: c.Name,
+
+	if input.Name != nil {
+		if p.Name = strings.TrimSpace(*input.Name); p.Name == "" {
+			return nil, apperror.Validation("name must not be empty")
+		}
+	}
+	if input.Description != nil {
+		p.Description = input.Description
+		if *input.Description == "" {
+			p.Description = nil
 		}
 	}
-	return out, nil
+	if input.Price != nil {
+		if err := validatePrice(*input.Price); err != nil {
+			return nil, err
+		}
+		p.Price = *input.Price
+	}
+	if input.CategoryID != nil {
+		if p.CategoryID, err = uuid.Parse(*input.CategoryID); err != nil {
+			return nil, apperror.Validation("invalid categoryID")
+		}
+	}
+
+	if err := r.ProductRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	return toGQLProduct(p), nil
 }
 
-// ProductsByCategory returns all products in a category subtree.
-// Any authenticated user can call this.
-func (r *queryResolver) ProductsByCategory(ctx context.Context, categoryID string) ([]*Product, error) {
-	// (Optional) require a role if needed
-	cid, err := uuid.Parse(categoryID)
+// ArchiveProduct withdraws a product from sale. It stays readable for the
+// orders that contain it. Only users with the “admin” role may archive.
+func (r *mutationResolver) ArchiveProduct(ctx context.Context, id string) (*Product, error) {
+	pid, err := uuid.Parse(id)
 	if err != nil {
-		return nil, errors.New("invalid categoryID")
+		return nil, apperror.Validation("invalid product id")
+	}
+	p, err := r.ProductRepo.Archive(ctx, pid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("product %s not found", id)
 	}
-	prods, err := r.ProductRepo.ListByCategory(ctx, cid)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]*Product, len(prods))
-	for i, p := range prods {
-		out[i] = &Product{
-			ID:          p.ID.String(),
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-			Category:    &Category{ID: p.CategoryID.String()},
-		}
-	}
-	return out, nil
+	return toGQLProduct(p), nil
 }
 
-// AveragePriceByCategory returns the average price of all products in a category subtree.
-// Any authenticated user can call this (if you want, you could restrict to "analyst" role, etc).
-func (r *queryResolver) AveragePriceByCategory(ctx context.Context, categoryID string) (float64, error) {
-	// (Optional) if !auth.HasRole(ctx, "analyst") { return 0, errors.New("unauthorized") }
-	cid, err := uuid.Parse(categoryID)
+// DeleteProduct removes a product that no order references and returns its
+// ID. Only users with the “admin” role may delete products.
+func (r *mutationResolver) DeleteProduct(ctx context.Context, id string) (string, error) {
+	pid, err := uuid.Parse(id)
 	if err != nil {
-		return 0, errors.New("invalid categoryID")
+		return "", apperror.Validation("invalid product id")
 	}
-	return r.ProductRepo.AveragePriceByCategory(ctx, cid)
+	err = r.ProductRepo.Delete(ctx, pid)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", apperror.NotFound("product %s not found", id)
+	case errors.Is(err, db.ErrProductInUse):
+		return "", apperror.Conflict("product %s is referenced by orders; archive it instead", id)
+	case err != nil:
+		return "", err
+	}
+	return pid.String(), nil
 }
 
 // PlaceOrder is the resolver for the placeOrder field.
-// Only users with the “customer” role may place orders.
+// Users with the “customer” role order for themselves; the customer is taken
+// from their token. Admins may place an order on behalf of input.customerID.
 func (r *mutationResolver) PlaceOrder(ctx context.Context, input OrderInput) (*Order, error) {
-	if !auth.HasRole(ctx, "customer") {
-		return nil, errors.New("unauthorized: must have 'customer' role to place orders")
+	// 1) resolve the ordering customer
+	var cust *db.Customer
+	if input.CustomerID != nil {
+		if !auth.HasRole(ctx, "admin") {
+			return nil, apperror.Forbidden("only admins may order on behalf of a customer")
+		}
+		id, err := uuid.Parse(*input.CustomerID)
+		if err != nil {
+			return nil, apperror.Validation("invalid customerID")
+		}
+		cust, err = r.CustomerRepo.GetByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("customer %s not found", *input.CustomerID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if !auth.HasRole(ctx, "customer") {
+			return nil, apperror.Forbidden("admins must set customerID to place an order")
+		}
+		var err error
+		if cust, err = r.currentCustomer(ctx); err != nil {
+			return nil, err
+		}
 	}
+	custID := cust.ID
 
-	// 1) parse & validate customerID
-	custID, err := uuid.Parse(input.CustomerID)
+	// 2) an idempotency key makes retries return the original order, even
+	//    once its products have changed
+	key, err := idempotencyKey(ctx, input.IdempotencyKey)
 	if err != nil {
-		return nil, errors.New("invalid customerID")
+		return nil, err
+	}
+	var idem *db.IdempotencyKey
+	if key != "" {
+		hash, err := orderRequestHash(custID, input.Items)
+		if err != nil {
+			return nil, err
+		}
+		held, err := r.OrderRepo.GetIdempotencyKey(ctx, custID, key)
+		switch {
+		case err == nil:
+			return r.replayOrder(ctx, key, hash, held.OrderID, held.RequestHash)
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		}
+		idem = &db.IdempotencyKey{
+			CustomerID:  custID,
+			Key:         key,
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(r.IdempotencyTTL),
+		}
 	}
 
-	// 2) build domain Order + OrderItems
+	// 3) build domain Order + OrderItems
 	order := &db.Order{
 		ID:         uuid.New(),
 		CustomerID: custID,
+		Status:     string(domain.OrderPending),
 		CreatedAt:  time.Now(),
 	}
+	if len(input.Items) == 0 {
+		return nil, apperror.Validation("order must contain at least one item")
+	}
 	var items []*db.OrderItem
-	var total float64
+	products := make(map[uuid.UUID]*db.Product, len(input.Items))
+	total := money.Zero(money.DefaultCurrency)
 
 	for _, in := range input.Items {
 		pid, err := uuid.Parse(in.ProductID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid productID %q", in.ProductID)
+			return nil, apperror.Validation("invalid productID %q", in.ProductID)
+		}
+		if in.Quantity <= 0 {
+			return nil, apperror.Validation("quantity for product %s must be greater than zero", in.ProductID)
+		}
+
+		// snapshot the current catalogue price; clients never supply prices
+		prod, ok := products[pid]
+		if !ok {
+			prod, err = r.ProductRepo.GetByID(ctx, pid)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, apperror.NotFound("product %s not found", in.ProductID)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("lookup product %s: %w", in.ProductID, err)
+			}
+			if prod.ArchivedAt != nil {
+				return nil, apperror.Validation("product %s is no longer available", in.ProductID)
+			}
+			products[pid] = prod
 		}
-		// TODO: lookup real price from DB
-		price := 0.0
 
 		oi := &db.OrderItem{
 			ID:        uuid.New(),
 			OrderID:   order.ID,
 			ProductID: pid,
 			Quantity:  in.Quantity,
-			UnitPrice: price, // ← was Price
+			UnitPrice: prod.Price,
+		}
+		subtotal, err := prod.Price.Mul(in.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("product %s: %w", in.ProductID, err)
+		}
+		if total, err = total.Add(subtotal); err != nil {
+			return nil, fmt.Errorf("product %s: %w", in.ProductID, err)
 		}
-		total += price * float64(in.Quantity)
 		items = append(items, oi)
 	}
 	order.Total = total
 
-	// 3) persist in a transaction
-	if err := r.OrderRepo.CreateOrder(ctx, order, items); err != nil {
+	// 4) persist, reserve stock and enqueue the confirmation and webhooks in a
+	//    transaction
+	view := templates.NewOrderView(order, items, products, cust)
+	confirmation, err := r.orderNotifications(templates.OrderPlaced, order.ID, cust, view)
+	if err != nil {
+		return nil, err
+	}
+	placed, err := webhook.NewOrderEvent(db.WebhookOrderPlaced, order, items, "")
+	if err != nil {
 		return nil, err
 	}
+	if err := r.OrderRepo.CreateOrder(ctx, order, items, idem, confirmation, []*db.WebhookEvent{placed}); err != nil {
+		// insufficient stock is reported by the error presenter
+		var replay *db.IdempotencyReplayError
+		if !errors.As(err, &replay) {
+			return nil, err
+		}
+		// a concurrent request with the same key won
+		return r.replayOrder(ctx, key, idem.RequestHash, replay.OrderID, replay.RequestHash)
+	}
 
-	// 4) fire‐and‐forget notifications
-	smsMsg := fmt.Sprintf("Your order %s has been placed. Total: %.2f", order.ID, order.Total)
-	go r.NotificationSvc.SendOrderSMS(ctx, "<customer‐phone>", smsMsg)
+	// 5) map back to GraphQL types
+	return toGQLOrder(order, items), nil
+}
 
-	emailBody := fmt.Sprintf(
-		"Dear customer,\n\nYour order %s for %.2f was successful!",
-		order.ID, order.Total,
-	)
-	go r.NotificationSvc.SendOrderEmail(ctx, "<customer‐email>", "Order Confirmation", emailBody)
+// UpdateOrderStatus moves an order to a new lifecycle state.
+// Only users with the “admin” role may change order status; the transition
+// must be allowed by domain.Transition. Cancelling goes through CancelOrder,
+// which also releases stock, stores the reason and notifies the customer.
+func (r *mutationResolver) UpdateOrderStatus(ctx context.Context, id string, status OrderStatus) (*Order, error) {
+	claims, _ := auth.FromContext(ctx)
 
-	// 5) map back to GraphQL types
-	gqlItems := make([]*OrderItem, len(items))
-	for i, it := range items {
-		gqlItems[i] = &OrderItem{
-			ID:       it.ID.String(),
-			Product:  &Product{ID: it.ProductID.String()},
-			Quantity: it.Quantity,
-			Price:    it.UnitPrice, // ← pull from UnitPrice
-		}
-	}
-
-	return &Order{
-		ID:         order.ID.String(),
-		CustomerID: order.CustomerID.String(),
-		Items:      gqlItems,
-		Total:      order.Total,
-		CreatedAt:  order.CreatedAt, // time.Time matches your Time scalar
-	}, nil
+	oid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid order id")
+	}
+	if status == OrderStatusCancelled {
+		return nil, apperror.Validation("use cancelOrder to cancel an order")
+	}
+	o, items, err := r.OrderRepo.GetByID(ctx, oid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("order %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := domain.OrderStatus(o.Status), toDomainOrderStatus(status)
+	if err := domain.Transition(from, to); err != nil {
+		return nil, err
+	}
+	o.Status = string(to)
+	changed, err := webhook.NewOrderEvent(db.WebhookOrderStatusChanged, o, items, string(from))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.OrderRepo.UpdateStatus(ctx, oid, string(from), string(to), claims.UID, []*db.WebhookEvent{changed}); err != nil {
+		return nil, err
+	}
+	return toGQLOrder(o, items), nil
 }
 
-// Schema glue — do not edit:
-func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
-func (r *Resolver) Query() QueryResolver       { return &queryResolver{r} }
-func (r *Resolver) Category() CategoryResolver { return &categoryResolver{r} }
+// CancelOrder cancels an order that has not progressed past a cancellable
+// state. Only the owning customer or an admin may cancel; anyone else gets
+// the same "not found" error as for a missing order.
+func (r *mutationResolver) CancelOrder(ctx context.Context, id string, reason CancelReason, note *string) (*Order, error) {
+	claims, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, apperror.Unauthenticated()
+	}
 
-type mutationResolver struct{ *Resolver }
-type queryResolver struct{ *Resolver }
-type categoryResolver struct{ *Resolver }
+	oid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid order id")
+	}
+	o, items, err := r.OrderRepo.GetByID(ctx, oid)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !r.canAccessCustomer(ctx, o.CustomerID)) {
+		return nil, apperror.NotFound("order %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var noteText string
+	if note != nil {
+		noteText = *note
+	}
+	from, code := domain.OrderStatus(o.Status), toDomainCancelReason(reason)
+	if err := domain.ValidateCancellation(from, code, noteText); err != nil {
+		return nil, err
+	}
+	cust, err := r.CustomerRepo.GetByID(ctx, o.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("lookup customer %s: %w", o.CustomerID, err)
+	}
+	now := time.Now()
+	reasonText := string(code)
+	cancelled := *o
+	cancelled.Status = string(domain.OrderCancelled)
+	cancelled.CancelReason, cancelled.CancelNote, cancelled.CancelledAt = &reasonText, note, &now
+	view, err := r.orderView(ctx, &cancelled, items, cust)
+	if err != nil {
+		return nil, err
+	}
+	notice, err := r.orderNotifications(templates.OrderCancelled, o.ID, cust, view)
+	if err != nil {
+		return nil, err
+	}
+	event, err := webhook.NewOrderEvent(db.WebhookOrderCancelled, &cancelled, items, string(from))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.OrderRepo.CancelOrder(ctx, oid, string(from), claims.UID, string(code), note, notice, []*db.WebhookEvent{event}); err != nil {
+		return nil, err
+	}
+	return toGQLOrder(&cancelled, items), nil
+}
+
+// RetryNotification requeues a dead notification for immediate delivery
+// with a fresh attempt budget. Only users with the “admin” role may retry.
+func (r *mutationResolver) RetryNotification(ctx context.Context, id string) (*Notification, error) {
+	nid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid notification id")
+	}
+	m, err := r.OutboxRepo.Requeue(ctx, nid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("no dead notification %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLNotification(m), nil
+}
+
+// CreateWebhookSubscription subscribes an endpoint to order events.
+// Only users with the “admin” role may manage webhooks.
+func (r *mutationResolver) CreateWebhookSubscription(ctx context.Context, input NewWebhookSubscription) (*WebhookSubscription, error) {
+	u, types, err := normalizeWebhook(input.URL, input.Secret, input.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	sub := &db.WebhookSubscription{
+		ID:         uuid.New(),
+		URL:        u,
+		Secret:     input.Secret,
+		EventTypes: types,
+		Active:     true,
+	}
+	if err := r.WebhookRepo.CreateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return toGQLWebhookSubscription(sub), nil
+}
+
+// UpdateWebhookSubscription changes a webhook subscription; omitted fields
+// keep their value. Deliveries already created keep going to the new URL
+// and are signed with the new secret. Only users with the “admin” role may
+// manage webhooks.
+func (r *mutationResolver) UpdateWebhookSubscription(ctx context.Context, id string, input UpdateWebhookSubscription) (*WebhookSubscription, error) {
+	sid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid webhook subscription id")
+	}
+	sub, err := r.WebhookRepo.GetSubscription(ctx, sid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("webhook subscription %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if input.URL != nil {
+		sub.URL = *input.URL
+	}
+	if input.Secret != nil {
+		sub.Secret = *input.Secret
+	}
+	types := make([]WebhookEventType, len(sub.EventTypes))
+	for i, t := range sub.EventTypes {
+		types[i] = toGQLWebhookEventType(t)
+	}
+	if input.EventTypes != nil {
+		types = input.EventTypes
+	}
+	if sub.URL, sub.EventTypes, err = normalizeWebhook(sub.URL, sub.Secret, types); err != nil {
+		return nil, err
+	}
+	if input.Active != nil {
+		sub.Active = *input.Active
+	}
+
+	err = r.WebhookRepo.UpdateSubscription(ctx, sub)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("webhook subscription %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLWebhookSubscription(sub), nil
+}
+
+// DeleteWebhookSubscription removes a webhook subscription with its
+// deliveries and returns its ID. Only users with the “admin” role may
+// manage webhooks.
+func (r *mutationResolver) DeleteWebhookSubscription(ctx context.Context, id string) (string, error) {
+	sid, err := uuid.Parse(id)
+	if err != nil {
+		return "", apperror.Validation("invalid webhook subscription id")
+	}
+	err = r.WebhookRepo.DeleteSubscription(ctx, sid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", apperror.NotFound("webhook subscription %s not found", id)
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// RedeliverWebhook requeues a dead delivery for immediate delivery with a
+// fresh attempt budget. Only users with the “admin” role may redeliver.
+func (r *mutationResolver) RedeliverWebhook(ctx context.Context, id string) (*WebhookDelivery, error) {
+	did, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid webhook delivery id")
+	}
+	d, err := r.WebhookRepo.Redeliver(ctx, did)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("no dead webhook delivery %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLWebhookDelivery(d), nil
+}
+
+// RegisterMe links the caller's Firebase identity to a customer record,
+// creating one if needed. Calling it again returns the linked customer.
+func (r *mutationResolver) RegisterMe(ctx context.Context, name *string) (*Customer, error) {
+	var n string
+	if name != nil {
+		n = *name
+	}
+	c, err := r.registerCustomer(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLCustomer(c), nil
+}
+
+// CreateCustomer persists a new customer.
+// Only users with the “admin” role may create customers.
+func (r *mutationResolver) CreateCustomer(ctx context.Context, input NewCustomer) (*Customer, error) {
+	name, email, err := normalizeCustomer(input.Name, input.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &db.Customer{
+		ID:     uuid.New(),
+		Name:   name,
+		Email:  email,
+		Locale: templates.DefaultLocale,
+	}
+	if input.Phone != nil {
+		phone, err := normalizePhone(*input.Phone)
+		if err != nil {
+			return nil, err
+		}
+		c.Phone = &phone
+	}
+	if input.Locale != nil {
+		if c.Locale, err = normalizeLocale(*input.Locale); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.CustomerRepo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	return toGQLCustomer(c), nil
+}
+
+// UpdateCustomer changes the name, email, phone or locale of a customer.
+// Admins may update any customer; customers only themselves.
+func (r *mutationResolver) UpdateCustomer(ctx context.Context, id string, input UpdateCustomer) (*Customer, error) {
+	cid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid customer id")
+	}
+	if !r.canAccessCustomer(ctx, cid) {
+		return nil, apperror.Forbidden("may only update your own customer record")
+	}
+
+	c, err := r.CustomerRepo.GetByID(ctx, cid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("customer %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		c.Name = *input.Name
+	}
+	if input.Email != nil {
+		c.Email = *input.Email
+	}
+	if c.Name, c.Email, err = normalizeCustomer(c.Name, c.Email); err != nil {
+		return nil, err
+	}
+	if input.Phone != nil {
+		if *input.Phone == "" {
+			c.Phone = nil
+		} else {
+			phone, err := normalizePhone(*input.Phone)
+			if err != nil {
+				return nil, err
+			}
+			c.Phone = &phone
+		}
+	}
+	if input.Locale != nil {
+		if c.Locale, err = normalizeLocale(*input.Locale); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.CustomerRepo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return toGQLCustomer(c), nil
+}
+
+// SetNotificationPreferences replaces the channels, event opt-outs and
+// quiet hours of a customer. They apply to notifications sent from then
+// on, including those already waiting in the outbox.
+// Admins may change any customer; customers only themselves.
+func (r *mutationResolver) SetNotificationPreferences(ctx context.Context, customerID string, input NotificationPreferencesInput) (*NotificationPreferences, error) {
+	cid, err := uuid.Parse(customerID)
+	if err != nil {
+		return nil, apperror.Validation("invalid customer id")
+	}
+	if !r.canAccessCustomer(ctx, cid) {
+		return nil, apperror.Forbidden("may only change your own notification preferences")
+	}
+	p, err := normalizeNotificationPreferences(cid, input)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.CustomerRepo.GetByID(ctx, cid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("customer %s not found", customerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.PrefsRepo.Save(ctx, p); err != nil {
+		return nil, err
+	}
+	return toGQLNotificationPreferences(p), nil
+}
+
+// AdjustStock adds or removes stock for a product and records who did it.
+// Only users with the “admin” role may adjust stock.
+func (r *mutationResolver) AdjustStock(ctx context.Context, input StockAdjustmentInput) (*StockLevel, error) {
+	claims, _ := auth.FromContext(ctx)
+
+	pid, err := uuid.Parse(input.ProductID)
+	if err != nil {
+		return nil, apperror.Validation("invalid productID")
+	}
+	if input.Delta == 0 {
+		return nil, apperror.Validation("delta must not be zero")
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" {
+		return nil, apperror.Validation("reason must not be empty")
+	}
+
+	adj, err := r.InventoryRepo.AdjustStock(ctx, pid, input.Delta, reason, claims.UID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("product %s not found", input.ProductID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := r.ProductRepo.GetByID(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	return &StockLevel{Product: toGQLProduct(p), Quantity: adj.QuantityAfter}, nil
+}
+
+// Items resolves the line items of an Order, loading them when the parent
+// resolver did not already fetch them.
+func (r *orderResolver) Items(ctx context.Context, obj *Order) ([]*OrderItem, error) {
+	if obj.Items != nil {
+		return obj.Items, nil
+	}
+	oid, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, items, err := r.OrderRepo.GetByID(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLOrderItems(items), nil
+}
+
+// StatusHistory resolves the recorded status transitions of an Order.
+func (r *orderResolver) StatusHistory(ctx context.Context, obj *Order) ([]*OrderStatusChange, error) {
+	oid, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := r.OrderRepo.ListStatusHistory(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*OrderStatusChange, len(changes))
+	for i, c := range changes {
+		out[i] = toGQLOrderStatusChange(c)
+	}
+	return out, nil
+}
+
+// Notifications resolves the outbox messages about an Order and their
+// delivery state. Only users with the “admin” role may read them.
+func (r *orderResolver) Notifications(ctx context.Context, obj *Order) ([]*Notification, error) {
+	oid, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := r.OutboxRepo.ListByOrder(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLNotifications(msgs), nil
+}
+
+// TotalCount runs the connection's count query.
+func (r *orderConnectionResolver) TotalCount(ctx context.Context, obj *OrderConnection) (int, error) {
+	return obj.count(ctx)
+}
+
+// Product resolves the full product behind an OrderItem.
+// The item keeps the price it was ordered at, regardless of later price changes.
+func (r *orderItemResolver) Product(ctx context.Context, obj *OrderItem) (*Product, error) {
+	pid, err := uuid.Parse(obj.Product.ID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.loadProduct(ctx, pid)
+	if err != nil {
+		return nil, fmt.Errorf("lookup product %s: %w", obj.Product.ID, err)
+	}
+	return toGQLProduct(p), nil
+}
+
+// Category resolves the full category of a Product. The categories of all
+// products in a response are loaded in one batch.
+func (r *productResolver) Category(ctx context.Context, obj *Product) (*Category, error) {
+	cid, err := uuid.Parse(obj.Category.ID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.loadCategory(ctx, cid)
+	if err != nil {
+		return nil, fmt.Errorf("lookup category %s: %w", obj.Category.ID, err)
+	}
+	return toGQLCategory(c), nil
+}
+
+// TotalCount runs the connection's count query.
+func (r *productConnectionResolver) TotalCount(ctx context.Context, obj *ProductConnection) (int, error) {
+	return obj.count(ctx)
+}
+
+// Categories returns one page of root categories.
+// Any authenticated user can call this.
+func (r *queryResolver) Categories(ctx context.Context, first *int, after *string) (*CategoryConnection, error) {
+	page, err := pageRequest(first, after)
+	if err != nil {
+		return nil, err
+	}
+	dbCats, err := r.CategoryRepo.ListChildren(ctx, nil, page)
+	if err != nil {
+		return nil, err
+	}
+	edges, info := paginate(dbCats, page, categoryCursor, func(cursor string, c *db.Category) *CategoryEdge {
+		return &CategoryEdge{Cursor: cursor, Node: toGQLCategory(c)}
+	})
+	return &CategoryConnection{
+		Edges:    edges,
+		PageInfo: info,
+		count: func(ctx context.Context) (int, error) {
+			return r.CategoryRepo.CountChildren(ctx, nil)
+		},
+	}, nil
+}
+
+// CategoryTree returns a category subtree, or every tree when rootID is
+// null, flattened depth first. Any authenticated user can call this.
+func (r *queryResolver) CategoryTree(ctx context.Context, rootID *string, maxDepth *int) ([]*CategoryTreeNode, error) {
+	var root *uuid.UUID
+	if rootID != nil {
+		id, err := uuid.Parse(*rootID)
+		if err != nil {
+			return nil, apperror.Validation("invalid rootID")
+		}
+		root = &id
+	}
+	depth := -1
+	if maxDepth != nil {
+		if *maxDepth < 0 {
+			return nil, apperror.Validation("maxDepth must not be negative")
+		}
+		depth = *maxDepth
+	}
+
+	nodes, err := r.CategoryRepo.Tree(ctx, root, depth)
+	if err != nil {
+		return nil, err
+	}
+	if root != nil && len(nodes) == 0 {
+		return nil, apperror.NotFound("category %s not found", *rootID)
+	}
+	out := make([]*CategoryTreeNode, len(nodes))
+	for i, n := range nodes {
+		path := make([]string, len(n.Path))
+		for j, id := range n.Path {
+			path[j] = id.String()
+		}
+		out[i] = &CategoryTreeNode{Category: toGQLCategory(&n.Category), Depth: n.Depth, Path: path}
+	}
+	return out, nil
+}
+
+// ProductsByCategory returns one page of the products in a category subtree.
+// Any authenticated user can call this.
+func (r *queryResolver) ProductsByCategory(ctx context.Context, categoryID string, first *int, after *string) (*ProductConnection, error) {
+	cid, err := uuid.Parse(categoryID)
+	if err != nil {
+		return nil, apperror.Validation("invalid categoryID")
+	}
+	page, err := pageRequest(first, after)
+	if err != nil {
+		return nil, err
+	}
+	prods, err := r.ProductRepo.ListByCategory(ctx, cid, page)
+	if err != nil {
+		return nil, err
+	}
+	edges, info := paginate(prods, page, productCursor, func(cursor string, p *db.Product) *ProductEdge {
+		return &ProductEdge{Cursor: cursor, Node: toGQLProduct(p)}
+	})
+	return &ProductConnection{
+		Edges:    edges,
+		PageInfo: info,
+		count: func(ctx context.Context) (int, error) {
+			return r.ProductRepo.CountByCategory(ctx, cid)
+		},
+	}, nil
+}
+
+// AveragePriceByCategory returns the average price of all products in a category subtree.
+// Any authenticated user can call this (if you want, you could restrict to "analyst" role, etc).
+func (r *queryResolver) AveragePriceByCategory(ctx context.Context, categoryID string) (*money.Money, error) {
+	cid, err := uuid.Parse(categoryID)
+	if err != nil {
+		return nil, apperror.Validation("invalid categoryID")
+	}
+	avg, err := r.ProductRepo.AveragePriceByCategory(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	return &avg, nil
+}
+
+// CategoryPriceStats summarizes the prices in a category, or in its whole
+// subtree when includeSubcategories is true (the default).
+// Any authenticated user can call this.
+func (r *queryResolver) CategoryPriceStats(ctx context.Context, categoryID string, includeSubcategories *bool, buckets *int) (*PriceStats, error) {
+	cid, err := uuid.Parse(categoryID)
+	if err != nil {
+		return nil, apperror.Validation("invalid categoryID")
+	}
+	subtree := includeSubcategories == nil || *includeSubcategories
+	n := 10
+	if buckets != nil {
+		n = *buckets
+	}
+	if n < 1 || n > 100 {
+		return nil, apperror.Validation("buckets must be between 1 and 100")
+	}
+
+	stats, err := r.ProductRepo.PriceStatsByCategory(ctx, cid, subtree, n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("category %s not found", categoryID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLPriceStats(stats), nil
+}
+
+// Order returns a single order with its items.
+// Customers only see their own orders; anything else resolves to null.
+func (r *queryResolver) Order(ctx context.Context, id string) (*Order, error) {
+	oid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid order id")
+	}
+	o, items, err := r.OrderRepo.GetByID(ctx, oid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !r.canAccessCustomer(ctx, o.CustomerID) {
+		return nil, nil
+	}
+	return toGQLOrder(o, items), nil
+}
+
+// MyOrders returns one page of the authenticated customer's orders.
+func (r *queryResolver) MyOrders(ctx context.Context, first *int, after *string) (*OrderConnection, error) {
+	cust, err := r.currentCustomer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.listOrders(ctx, cust.ID, first, after)
+}
+
+// OrdersByCustomer returns one page of any customer's orders.
+// Only users with the “admin” role may call this.
+func (r *queryResolver) OrdersByCustomer(ctx context.Context, customerID string, first *int, after *string) (*OrderConnection, error) {
+	custID, err := uuid.Parse(customerID)
+	if err != nil {
+		return nil, apperror.Validation("invalid customerID")
+	}
+	return r.listOrders(ctx, custID, first, after)
+}
+
+// Notifications lists outbox messages in one delivery state, e.g. the dead
+// letters. Only users with the “admin” role may list them.
+func (r *queryResolver) Notifications(ctx context.Context, status NotificationStatus, limit *int) ([]*Notification, error) {
+	l := 50
+	if limit != nil {
+		l = *limit
+	}
+	if l < 1 || l > 500 {
+		return nil, apperror.Validation("limit must be between 1 and 500")
+	}
+	msgs, err := r.OutboxRepo.ListByStatus(ctx, strings.ToLower(string(status)), l)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLNotifications(msgs), nil
+}
+
+// NotificationPreview renders the notification an order event sends,
+// without enqueuing it. locale defaults to the customer's. Only users with
+// the “admin” role may preview notifications.
+func (r *queryResolver) NotificationPreview(ctx context.Context, orderID string, event NotificationEvent, locale *string) (*NotificationPreview, error) {
+	oid, err := uuid.Parse(orderID)
+	if err != nil {
+		return nil, apperror.Validation("invalid order id")
+	}
+	o, items, err := r.OrderRepo.GetByID(ctx, oid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.NotFound("order %s not found", orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cust, err := r.CustomerRepo.GetByID(ctx, o.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("lookup customer %s: %w", o.CustomerID, err)
+	}
+	loc := cust.Locale
+	if locale != nil {
+		if loc, err = normalizeLocale(*locale); err != nil {
+			return nil, err
+		}
+	}
+	view, err := r.orderView(ctx, o, items, cust)
+	if err != nil {
+		return nil, err
+	}
+	content, err := r.Templates.Render(templates.Event(strings.ToLower(string(event))), loc, view)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLNotificationPreview(content), nil
+}
+
+// WebhookSubscriptions lists every webhook subscription. Only users with
+// the “admin” role may list them.
+func (r *queryResolver) WebhookSubscriptions(ctx context.Context) ([]*WebhookSubscription, error) {
+	subs, err := r.WebhookRepo.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*WebhookSubscription, len(subs))
+	for i, s := range subs {
+		out[i] = toGQLWebhookSubscription(s)
+	}
+	return out, nil
+}
+
+// WebhookSubscription returns one webhook subscription, or null.
+// Only users with the “admin” role may read it.
+func (r *queryResolver) WebhookSubscription(ctx context.Context, id string) (*WebhookSubscription, error) {
+	sid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid webhook subscription id")
+	}
+	sub, err := r.WebhookRepo.GetSubscription(ctx, sid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLWebhookSubscription(sub), nil
+}
+
+// WebhookDelivery returns one webhook delivery, or null.
+// Only users with the “admin” role may read it.
+func (r *queryResolver) WebhookDelivery(ctx context.Context, id string) (*WebhookDelivery, error) {
+	did, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid webhook delivery id")
+	}
+	d, err := r.WebhookRepo.GetDelivery(ctx, did)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLWebhookDelivery(d), nil
+}
+
+// Me returns the customer linked to the caller's identity, or null if the
+// caller has not registered yet.
+func (r *queryResolver) Me(ctx context.Context) (*Customer, error) {
+	c, err := r.linkedCustomer(ctx)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return toGQLCustomer(c), nil
+}
+
+// Customer returns one customer by ID.
+// Admins may read any customer; customers only themselves.
+func (r *queryResolver) Customer(ctx context.Context, id string) (*Customer, error) {
+	cid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperror.Validation("invalid customer id")
+	}
+	if !r.canAccessCustomer(ctx, cid) {
+		return nil, apperror.Forbidden("may only read your own customer record")
+	}
+	c, err := r.CustomerRepo.GetByID(ctx, cid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLCustomer(c), nil
+}
+
+// CustomerByEmail looks a customer up by email address (case-insensitive).
+// Only users with the “admin” role may call this.
+func (r *queryResolver) CustomerByEmail(ctx context.Context, email string) (*Customer, error) {
+	c, err := r.CustomerRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLCustomer(c), nil
+}
+
+// Customers returns one page of customers.
+// Only users with the “admin” role may list customers.
+func (r *queryResolver) Customers(ctx context.Context, first *int, after *string) (*CustomerConnection, error) {
+	page, err := pageRequest(first, after)
+	if err != nil {
+		return nil, err
+	}
+	custs, err := r.CustomerRepo.List(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	edges, info := paginate(custs, page, customerCursor, func(cursor string, c *db.Customer) *CustomerEdge {
+		return &CustomerEdge{Cursor: cursor, Node: toGQLCustomer(c)}
+	})
+	return &CustomerConnection{
+		Edges:    edges,
+		PageInfo: info,
+		count:    r.CustomerRepo.Count,
+	}, nil
+}
+
+// StockLevels returns current stock for the given products, or for all
+// products when productIDs is omitted.
+// Only users with the “admin” role may read stock levels.
+func (r *queryResolver) StockLevels(ctx context.Context, productIDs []string) ([]*StockLevel, error) {
+	ids := make([]uuid.UUID, len(productIDs))
+	for i, s := range productIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, apperror.Validation("invalid productID %q", s)
+		}
+		ids[i] = id
+	}
+
+	prods, err := r.InventoryRepo.StockLevels(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*StockLevel, len(prods))
+	for i, p := range prods {
+		out[i] = &StockLevel{Product: toGQLProduct(p), Quantity: p.Stock}
+	}
+	return out, nil
+}
+
+// StockAdjustments returns the audit trail of a product's stock.
+// Only users with the “admin” role may read it.
+func (r *queryResolver) StockAdjustments(ctx context.Context, productID string, limit *int) ([]*StockAdjustment, error) {
+	pid, err := uuid.Parse(productID)
+	if err != nil {
+		return nil, apperror.Validation("invalid productID")
+	}
+	l := 50
+	if limit != nil {
+		l = *limit
+	}
+	if l < 1 || l > 500 {
+		return nil, apperror.Validation("limit must be between 1 and 500")
+	}
+
+	adjs, err := r.InventoryRepo.ListAdjustments(ctx, pid, l)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*StockAdjustment, len(adjs))
+	for i, a := range adjs {
+		out[i] = toGQLStockAdjustment(a)
+	}
+	return out, nil
+}
+
+// AttemptLog resolves the logged attempts of a WebhookDelivery.
+func (r *webhookDeliveryResolver) AttemptLog(ctx context.Context, obj *WebhookDelivery) ([]*WebhookAttempt, error) {
+	did, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	as, err := r.WebhookRepo.ListAttempts(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*WebhookAttempt, len(as))
+	for i, a := range as {
+		out[i] = toGQLWebhookAttempt(a)
+	}
+	return out, nil
+}
+
+// Deliveries resolves the deliveries of a WebhookSubscription, newest first.
+func (r *webhookSubscriptionResolver) Deliveries(ctx context.Context, obj *WebhookSubscription, status *WebhookDeliveryStatus, limit *int) ([]*WebhookDelivery, error) {
+	l := 50
+	if limit != nil {
+		l = *limit
+	}
+	if l < 1 || l > 500 {
+		return nil, apperror.Validation("limit must be between 1 and 500")
+	}
+	sid, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	var st *string
+	if status != nil {
+		s := strings.ToLower(string(*status))
+		st = &s
+	}
+	ds, err := r.WebhookRepo.ListDeliveries(ctx, sid, st, l)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*WebhookDelivery, len(ds))
+	for i, d := range ds {
+		out[i] = toGQLWebhookDelivery(d)
+	}
+	return out, nil
+}
+
+// Category returns CategoryResolver implementation.
+func (r *Resolver) Category() CategoryResolver { return &categoryResolver{r} }
+
+// CategoryConnection returns CategoryConnectionResolver implementation.
+func (r *Resolver) CategoryConnection() CategoryConnectionResolver {
+	return &categoryConnectionResolver{r}
+}
+
+// Customer returns CustomerResolver implementation.
+func (r *Resolver) Customer() CustomerResolver { return &customerResolver{r} }
+
+// CustomerConnection returns CustomerConnectionResolver implementation.
+func (r *Resolver) CustomerConnection() CustomerConnectionResolver {
+	return &customerConnectionResolver{r}
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Order returns OrderResolver implementation.
+func (r *Resolver) Order() OrderResolver { return &orderResolver{r} }
+
+// OrderConnection returns OrderConnectionResolver implementation.
+func (r *Resolver) OrderConnection() OrderConnectionResolver { return &orderConnectionResolver{r} }
+
+// OrderItem returns OrderItemResolver implementation.
+func (r *Resolver) OrderItem() OrderItemResolver { return &orderItemResolver{r} }
+
+// Product returns ProductResolver implementation.
+func (r *Resolver) Product() ProductResolver { return &productResolver{r} }
+
+// ProductConnection returns ProductConnectionResolver implementation.
+func (r *Resolver) ProductConnection() ProductConnectionResolver {
+	return &productConnectionResolver{r}
+}
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// WebhookDelivery returns WebhookDeliveryResolver implementation.
+func (r *Resolver) WebhookDelivery() WebhookDeliveryResolver { return &webhookDeliveryResolver{r} }
+
+// WebhookSubscription returns WebhookSubscriptionResolver implementation.
+func (r *Resolver) WebhookSubscription() WebhookSubscriptionResolver {
+	return &webhookSubscriptionResolver{r}
+}
+
+type categoryResolver struct{ *Resolver }
+type categoryConnectionResolver struct{ *Resolver }
+type customerResolver struct{ *Resolver }
+type customerConnectionResolver struct{ *Resolver }
+type mutationResolver struct{ *Resolver }
+type orderResolver struct{ *Resolver }
+type orderConnectionResolver struct{ *Resolver }
+type orderItemResolver struct{ *Resolver }
+type productResolver struct{ *Resolver }
+type productConnectionResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type webhookDeliveryResolver struct{ *Resolver }
+type webhookSubscriptionResolver struct{ *Resolver }
@@ -0,0 +1,160 @@
+package graphql
+
+import (
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/apperror"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification/templates"
+)
+
+// normalizeCustomer trims name and email, lower-cases the email and checks
+// that both are usable.
+func normalizeCustomer(name, email string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", apperror.Validation("name must not be empty")
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return "", "", apperror.Validation("invalid email address")
+	}
+	return name, email, nil
+}
+
+var (
+	phonePattern  = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+)
+
+// normalizePhone strips the spaces, dashes, dots and parentheses people
+// write phone numbers with and requires what is left to be E.164.
+func normalizePhone(phone string) (string, error) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+	if !phonePattern.MatchString(phone) {
+		return "", apperror.Validation("phone must be in international format, e.g. +4915112345678")
+	}
+	return phone, nil
+}
+
+// normalizeLocale lower-cases a BCP 47 tag such as "de_AT" to "de-at" and
+// checks its shape. Locales without templates fall back when rendering.
+func normalizeLocale(locale string) (string, error) {
+	locale = templates.NormalizeLocale(locale)
+	if !localePattern.MatchString(locale) {
+		return "", apperror.Validation("invalid locale %q", locale)
+	}
+	return locale, nil
+}
+
+// minWebhookSecret is the shortest secret a webhook subscription accepts.
+const minWebhookSecret = 16
+
+// normalizeWebhook checks the URL, secret and event types of a webhook
+// subscription and returns the event types in their database form, without
+// duplicates.
+func normalizeWebhook(rawURL, secret string, types []WebhookEventType) (string, []string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", nil, apperror.Validation("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return "", nil, apperror.Validation("url must not contain credentials")
+	}
+	if len(secret) < minWebhookSecret {
+		return "", nil, apperror.Validation("secret must be at least %d characters", minWebhookSecret)
+	}
+	if len(types) == 0 {
+		return "", nil, apperror.Validation("eventTypes must not be empty")
+	}
+	var out []string
+	seen := make(map[WebhookEventType]bool)
+	for _, t := range types {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, toDBWebhookEventType(t))
+		}
+	}
+	return rawURL, out, nil
+}
+
+// normalizeNotificationPreferences checks in and returns it as the stored
+// preferences of customerID, with channels and events in their database
+// form and without duplicates.
+func normalizeNotificationPreferences(customerID uuid.UUID, in NotificationPreferencesInput) (*db.NotificationPreferences, error) {
+	p := &db.NotificationPreferences{
+		CustomerID:     customerID,
+		Channels:       []string{},
+		OptedOutEvents: []string{},
+		TimeZone:       "UTC",
+	}
+	seen := make(map[string]bool)
+	for _, ch := range in.Channels {
+		if v := strings.ToLower(string(ch)); !seen[v] {
+			seen[v] = true
+			p.Channels = append(p.Channels, v)
+		}
+	}
+	seen = make(map[string]bool)
+	for _, e := range in.OptedOutEvents {
+		if v := strings.ToLower(string(e)); !seen[v] {
+			seen[v] = true
+			p.OptedOutEvents = append(p.OptedOutEvents, v)
+		}
+	}
+
+	if q := in.QuietHours; q != nil {
+		start, err := parseClock("quietHours.start", q.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock("quietHours.end", q.End)
+		if err != nil {
+			return nil, err
+		}
+		if start == end {
+			return nil, apperror.Validation("quietHours.start and quietHours.end must differ")
+		}
+		tz := strings.TrimSpace(q.TimeZone)
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+			return nil, apperror.Validation("unknown time zone %q", q.TimeZone)
+		}
+		p.QuietStart, p.QuietEnd, p.TimeZone = &start, &end, tz
+	}
+	return p, nil
+}
+
+// parseClock turns "HH:MM" into minutes after midnight.
+func parseClock(field, s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, apperror.Validation("%s must be a time of day like \"22:00\"", field)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validatePrice rejects negative prices and currencies other than the one
+// the catalogue is stored in.
+func validatePrice(p money.Money) error {
+	if p.IsNegative() {
+		return apperror.Validation("price must not be negative")
+	}
+	if p.Currency != money.DefaultCurrency {
+		return apperror.Validation("price must be in %s", money.DefaultCurrency)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MarshalGQL implements the gqlgen Marshaler for the Money scalar:
+//
+//	{"amount": "12.34", "currency": "USD"}
+//
+// The amount is a string so clients never round-trip it through a float.
+func (m Money) MarshalGQL(w io.Writer) {
+	cur := m.Currency
+	if cur == "" {
+		cur = DefaultCurrency
+	}
+	fmt.Fprintf(w, `{"amount":%s,"currency":%s}`, strconv.Quote(m.Decimal()), strconv.Quote(cur))
+}
+
+// UnmarshalGQL implements the gqlgen Unmarshaler for the Money scalar. It
+// accepts a decimal string ("12.34"), a number, or an object
+// {"amount": "12.34", "currency": "EUR"}; the currency defaults to
+// DefaultCurrency.
+func (m *Money) UnmarshalGQL(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		cur := DefaultCurrency
+		if c, ok := v["currency"]; ok {
+			s, ok := c.(string)
+			if !ok || len(s) != 3 {
+				return fmt.Errorf("%w: currency must be a 3-letter ISO 4217 code", ErrInvalidAmount)
+			}
+			cur = strings.ToUpper(s)
+		}
+		amount, ok := v["amount"]
+		if !ok {
+			return fmt.Errorf("%w: amount is required", ErrInvalidAmount)
+		}
+		parsed, err := parseScalar(amount, cur)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	default:
+		parsed, err := parseScalar(v, DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+}
+
+func parseScalar(v interface{}, currency string) (Money, error) {
+	switch v := v.(type) {
+	case string:
+		return Parse(v, currency)
+	case json.Number:
+		return Parse(v.String(), currency)
+	case int:
+		return Money{Amount: int64(v) * unit, Currency: currency}, nil
+	case int64:
+		return Money{Amount: v * unit, Currency: currency}, nil
+	case float64:
+		// shortest representation that round-trips, e.g. 0.1 → "0.1"
+		return Parse(strconv.FormatFloat(v, 'f', -1, 64), currency)
+	}
+	return Money{}, fmt.Errorf("%w: cannot use %T as an amount", ErrInvalidAmount, v)
+}
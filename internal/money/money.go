@@ -0,0 +1,238 @@
+// Package money implements an exact decimal amount tied to a currency.
+//
+// Amounts are held as an integer number of minor units (cents), matching the
+// NUMERIC(…,2) columns in Postgres, so sums and products never pick up
+// floating point rounding errors.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the ISO 4217 code assumed when a value carries none.
+// The database stores amounts only, all in this currency.
+const DefaultCurrency = "USD"
+
+// Scale is the number of decimal places kept, i.e. 100 minor units per unit.
+const Scale = 2
+
+const unit = 100 // 10^Scale
+
+// ErrCurrencyMismatch is returned when combining amounts in different currencies.
+var ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+// ErrInvalidAmount is wrapped by every error parsing an amount.
+var ErrInvalidAmount = errors.New("money: invalid amount")
+
+// ErrOverflow is returned when arithmetic leaves the range of an int64 of
+// minor units.
+var ErrOverflow = errors.New("money: amount out of range")
+
+// Money is an amount of minor units in a currency.
+type Money struct {
+	Amount   int64  // minor units, e.g. cents
+	Currency string // ISO 4217 code
+}
+
+// New returns minor units of currency.
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// Zero returns a zero amount in currency.
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Parse reads a decimal string such as "12.34", "-0.5" or "7" as an amount in
+// currency. More than Scale decimal places is an error rather than a silent
+// rounding.
+func Parse(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, s)
+	}
+	for _, part := range []string{whole, frac} {
+		if strings.TrimLeft(part, "0123456789") != "" {
+			return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, s)
+		}
+	}
+	if len(frac) > Scale {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, Scale)
+	}
+	frac += strings.Repeat("0", Scale-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w %q: %v", ErrInvalidAmount, s, err)
+	}
+	f, _ := strconv.ParseInt(frac, 10, 64)
+	minor := w*unit + f
+	if minor/unit != w {
+		return Money{}, fmt.Errorf("%w: %q out of range", ErrInvalidAmount, s)
+	}
+	if neg {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// MustParse is like Parse but panics on error. It is meant for constants.
+func MustParse(s, currency string) Money {
+	m, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Decimal formats the amount without currency, e.g. "12.34".
+func (m Money) Decimal() string {
+	a := m.Amount
+	sign := ""
+	if a < 0 {
+		sign, a = "-", -a
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, a/unit, Scale, a%unit)
+}
+
+// String formats the amount with its currency, e.g. "12.34 USD".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// Add returns m + o. An empty currency on either side adopts the other's.
+func (m Money) Add(o Money) (Money, error) {
+	cur, err := common(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m.Decimal(), o.Decimal())
+	}
+	return Money{Amount: sum, Currency: cur}, nil
+}
+
+// Sub returns m - o. An empty currency on either side adopts the other's.
+func (m Money) Sub(o Money) (Money, error) {
+	cur, err := common(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	diff := m.Amount - o.Amount
+	if (o.Amount > 0 && diff > m.Amount) || (o.Amount < 0 && diff < m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m.Decimal(), o.Decimal())
+	}
+	return Money{Amount: diff, Currency: cur}, nil
+}
+
+// Mul returns m multiplied by a whole quantity.
+func (m Money) Mul(qty int) (Money, error) {
+	q := int64(qty)
+	product := m.Amount * q
+	if q != 0 && (product/q != m.Amount || (q == -1 && m.Amount == math.MinInt64)) {
+		return Money{}, fmt.Errorf("%w: %s × %d", ErrOverflow, m.Decimal(), qty)
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Cmp compares amounts and returns -1, 0 or +1. Currencies are not checked.
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+func common(a, b Money) (string, error) {
+	switch {
+	case a.Currency == "":
+		return b.Currency, nil
+	case b.Currency == "" || a.Currency == b.Currency:
+		return a.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s vs %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+}
+
+// Scan implements sql.Scanner for NUMERIC columns. The scanned value is in
+// DefaultCurrency.
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		if v > math.MaxInt64/unit || v < math.MinInt64/unit {
+			return fmt.Errorf("%w: %d", ErrOverflow, v)
+		}
+		*m = Money{Amount: v * unit, Currency: DefaultCurrency}
+		return nil
+	case nil:
+		return errors.New("money: cannot scan NULL; use NullMoney")
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	parsed, err := Parse(s, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, writing the exact decimal string.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// NullMoney is a Money that may be NULL, e.g. the result of an aggregate
+// over no rows.
+type NullMoney struct {
+	Money Money
+	Valid bool
+}
+
+// Scan implements sql.Scanner.
+func (n *NullMoney) Scan(src interface{}) error {
+	if src == nil {
+		*n = NullMoney{}
+		return nil
+	}
+	n.Valid = true
+	return n.Money.Scan(src)
+}
+
+// Value implements driver.Valuer.
+func (n NullMoney) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Money.Value()
+}
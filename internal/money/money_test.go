@@ -0,0 +1,79 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestArithmeticOverflow(t *testing.T) {
+	maxM, minM := New(math.MaxInt64, "USD"), New(math.MinInt64, "USD")
+	one := New(1, "USD")
+
+	tests := []struct {
+		name string
+		op   func() (Money, error)
+		want int64 // ignored when overflow
+		over bool
+	}{
+		{"add", func() (Money, error) { return New(150, "USD").Add(New(-50, "USD")) }, 100, false},
+		{"add max", func() (Money, error) { return maxM.Add(New(-1, "USD")) }, math.MaxInt64 - 1, false},
+		{"add over", func() (Money, error) { return maxM.Add(one) }, 0, true},
+		{"add under", func() (Money, error) { return minM.Add(New(-1, "USD")) }, 0, true},
+		{"sub", func() (Money, error) { return New(150, "USD").Sub(New(200, "USD")) }, -50, false},
+		{"sub over", func() (Money, error) { return maxM.Sub(New(-1, "USD")) }, 0, true},
+		{"sub under", func() (Money, error) { return minM.Sub(one) }, 0, true},
+		{"mul", func() (Money, error) { return New(250, "USD").Mul(3) }, 750, false},
+		{"mul zero", func() (Money, error) { return maxM.Mul(0) }, 0, false},
+		{"mul negative", func() (Money, error) { return maxM.Mul(-1) }, -math.MaxInt64, false},
+		{"mul over", func() (Money, error) { return New(math.MaxInt64/2+1, "USD").Mul(2) }, 0, true},
+		{"mul large quantity", func() (Money, error) { return New(999_999_99, "USD").Mul(math.MaxInt32 * 100_000) }, 0, true},
+		{"mul min by -1", func() (Money, error) { return minM.Mul(-1) }, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.op()
+		switch {
+		case tt.over && !errors.Is(err, ErrOverflow):
+			t.Errorf("%s = %v, %v; want ErrOverflow", tt.name, got, err)
+		case !tt.over && err != nil:
+			t.Errorf("%s error = %v", tt.name, err)
+		case !tt.over && got.Amount != tt.want:
+			t.Errorf("%s = %d, want %d", tt.name, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+		want int64 // ignored when err is set
+		err  error
+	}{
+		{"numeric text", []byte("12.34"), 1234, nil},
+		{"string", "-0.50", -50, nil},
+		{"integer", int64(12), 1200, nil},
+		{"largest integer", int64(math.MaxInt64 / 100), math.MaxInt64 / 100 * 100, nil},
+		{"smallest integer", int64(math.MinInt64 / 100), math.MinInt64 / 100 * 100, nil},
+		{"integer over", int64(math.MaxInt64/100 + 1), 0, ErrOverflow},
+		{"integer under", int64(math.MinInt64/100 - 1), 0, ErrOverflow},
+		{"max int64", int64(math.MaxInt64), 0, ErrOverflow},
+		{"bad text", "12.345", 0, ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := got.Scan(tt.src)
+		switch {
+		case tt.err != nil && !errors.Is(err, tt.err):
+			t.Errorf("%s: Scan(%v) = %v, %v; want %v", tt.name, tt.src, got, err, tt.err)
+		case tt.err == nil && err != nil:
+			t.Errorf("%s: Scan(%v) error = %v", tt.name, tt.src, err)
+		case tt.err == nil && (got.Amount != tt.want || got.Currency != DefaultCurrency):
+			t.Errorf("%s: Scan(%v) = %d %s, want %d %s", tt.name, tt.src, got.Amount, got.Currency, tt.want, DefaultCurrency)
+		}
+	}
+	var m Money
+	if err := m.Scan(nil); err == nil {
+		t.Error("Scan(nil) succeeded")
+	}
+}
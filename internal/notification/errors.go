@@ -0,0 +1,40 @@
+package notification
+
+import (
+	"errors"
+	"net/textproto"
+)
+
+// PermanentError marks a delivery failure that retrying cannot fix, such as
+// a recipient the server refuses. Other errors are assumed to be transient.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent wraps err in a *PermanentError; nil stays nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err wraps a *PermanentError.
+func IsPermanent(err error) bool {
+	var p *PermanentError
+	return errors.As(err, &p)
+}
+
+// rejected makes a 5xx SMTP reply permanent. 4xx replies and network errors
+// are left as they are, since the server expects them to be retried.
+func rejected(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
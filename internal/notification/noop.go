@@ -11,6 +11,6 @@ func (n *NoopNotificationService) SendOrderSMS(ctx context.Context, phone, msg s
 }
 
 // SendOrderEmail is a no-op.
-func (n *NoopNotificationService) SendOrderEmail(ctx context.Context, email, subject, body string) error {
+func (n *NoopNotificationService) SendOrderEmail(ctx context.Context, email, subject, body, htmlBody string) error {
 	return nil
 }
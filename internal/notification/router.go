@@ -0,0 +1,221 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// Notifier delivers an event to a recipient on whichever channels apply.
+type Notifier interface {
+	Notify(ctx context.Context, to Recipient, e Event) error
+}
+
+// Recipient is who an event is delivered to.
+type Recipient struct {
+	// CustomerID selects the notification preferences that apply; nil
+	// applies none.
+	CustomerID *uuid.UUID
+	Email      string
+	Phone      string // E.164
+}
+
+func (r Recipient) address(channel string) string {
+	switch channel {
+	case db.ChannelEmail:
+		return r.Email
+	case db.ChannelSMS:
+		return r.Phone
+	}
+	return ""
+}
+
+// Event is something to tell a recipient, rendered for each channel it may
+// go out on.
+type Event struct {
+	Type     string             // e.g. "order_placed"; what customers opt out of
+	Messages map[string]Message // by channel, e.g. db.ChannelEmail
+}
+
+// Message is the content of an event on one channel.
+type Message struct {
+	Subject string // email only
+	Text    string
+	HTML    string // email only; derived from Text when empty
+}
+
+// Driver sends messages over one channel.
+type Driver interface {
+	Send(ctx context.Context, to string, m Message) error
+}
+
+// DriverFunc adapts a function to Driver.
+type DriverFunc func(ctx context.Context, to string, m Message) error
+
+// Send calls f.
+func (f DriverFunc) Send(ctx context.Context, to string, m Message) error {
+	return f(ctx, to, m)
+}
+
+// EmailDriver sends email through svc.
+func EmailDriver(svc NotificationService) Driver {
+	return DriverFunc(func(ctx context.Context, to string, m Message) error {
+		return svc.SendOrderEmail(ctx, to, m.Subject, m.Text, m.HTML)
+	})
+}
+
+// SMSDriver sends SMS through svc.
+func SMSDriver(svc NotificationService) Driver {
+	return DriverFunc(func(ctx context.Context, to string, m Message) error {
+		return svc.SendOrderSMS(ctx, to, m.Text)
+	})
+}
+
+// SuppressedError reports a message the recipient's preferences held back.
+// It is not a failure: the message was deliberately not sent, and retrying
+// before Until would hold it back again.
+type SuppressedError struct {
+	Channel string
+	Reason  string
+	// Until is when quiet hours end; zero when the message must not be
+	// sent at all.
+	Until time.Time
+}
+
+func (e *SuppressedError) Error() string {
+	return fmt.Sprintf("notification: %s suppressed: %s", e.Channel, e.Reason)
+}
+
+// Router implements Notifier by applying the recipient's notification
+// preferences and handing each remaining message to the driver of its
+// channel. Every message it holds back is logged with the reason.
+type Router struct {
+	Prefs   db.NotificationPreferenceRepository // nil applies no preferences
+	Drivers map[string]Driver                   // by channel
+}
+
+// NewRouter returns a Router that sends email through svc. No service
+// delivers SMS yet, so SMS messages are suppressed until an SMSDriver is
+// added to Drivers.
+func NewRouter(prefs db.NotificationPreferenceRepository, svc NotificationService) *Router {
+	return &Router{
+		Prefs: prefs,
+		Drivers: map[string]Driver{
+			db.ChannelEmail: EmailDriver(svc),
+		},
+	}
+}
+
+// Notify sends e to the recipient on each channel it has a message for and
+// the recipient's preferences allow. It returns the errors of all channels
+// joined; a channel that was held back, or that has no driver, contributes
+// a *SuppressedError.
+func (r *Router) Notify(ctx context.Context, to Recipient, e Event) error {
+	var prefs *db.NotificationPreferences
+	if r.Prefs != nil && to.CustomerID != nil {
+		var err error
+		if prefs, err = r.Prefs.Get(ctx, *to.CustomerID); err != nil {
+			return fmt.Errorf("notification: load preferences of %s: %w", *to.CustomerID, err)
+		}
+	}
+
+	channels := make([]string, 0, len(e.Messages))
+	for ch := range e.Messages {
+		channels = append(channels, ch)
+	}
+	sort.Strings(channels)
+
+	now := time.Now()
+	var errs []error
+	for _, ch := range channels {
+		addr := to.address(ch)
+		if s := suppress(prefs, e.Type, ch, addr, now); s != nil {
+			log.Printf("notification: suppressed %s %s to %q: %s", e.Type, ch, addr, s.Reason)
+			errs = append(errs, s)
+			continue
+		}
+		d, ok := r.Drivers[ch]
+		if !ok {
+			s := &SuppressedError{Channel: ch, Reason: "no " + ch + " driver"}
+			log.Printf("notification: suppressed %s %s to %q: %s", e.Type, ch, addr, s.Reason)
+			errs = append(errs, s)
+			continue
+		}
+		if err := d.Send(ctx, addr, e.Messages[ch]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// suppress returns why a message of event on channel to addr must not be
+// sent at now, or nil when it may be. prefs may be nil.
+func suppress(prefs *db.NotificationPreferences, event, channel, addr string, now time.Time) *SuppressedError {
+	s := &SuppressedError{Channel: channel}
+	if addr == "" {
+		s.Reason = "no " + channel + " address"
+		return s
+	}
+	if prefs == nil {
+		return nil
+	}
+	if !contains(prefs.Channels, channel) {
+		s.Reason = "customer disabled " + channel
+		return s
+	}
+	if contains(prefs.OptedOutEvents, event) {
+		s.Reason = "customer opted out of " + event
+		return s
+	}
+	if until, ok := quietUntil(prefs, now); ok {
+		s.Reason = fmt.Sprintf("quiet hours until %s %s", until.Format("15:04"), until.Location())
+		s.Until = until
+		return s
+	}
+	return nil
+}
+
+// quietUntil reports whether now falls in the quiet hours of prefs and, if
+// so, when they end. An unknown time zone is treated as UTC.
+func quietUntil(prefs *db.NotificationPreferences, now time.Time) (time.Time, bool) {
+	if prefs.QuietStart == nil || prefs.QuietEnd == nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(prefs.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	start, end := *prefs.QuietStart, *prefs.QuietEnd
+
+	quiet := minute >= start && minute < end
+	if start > end { // wraps past midnight
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+	y, m, d := local.Date()
+	until := time.Date(y, m, d, end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = time.Date(y, m, d+1, end/60, end%60, 0, 0, loc)
+	}
+	return until, true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+func minutes(h, m int) *int {
+	v := h*60 + m
+	return &v
+}
+
+func TestQuietUntil(t *testing.T) {
+	utc := func(month time.Month, day, h, m int) time.Time {
+		return time.Date(2026, month, day, h, m, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		zone       string
+		start, end *int
+		now        time.Time
+		want       time.Time // zero when not quiet
+	}{
+		{"no quiet hours", "UTC", nil, nil, utc(1, 15, 3, 0), time.Time{}},
+		{"only a start", "UTC", minutes(22, 0), nil, utc(1, 15, 23, 0), time.Time{}},
+
+		// same-day window
+		{"before window", "Europe/Berlin", minutes(12, 0), minutes(14, 0), utc(1, 15, 10, 59), time.Time{}},
+		{"window starts", "Europe/Berlin", minutes(12, 0), minutes(14, 0), utc(1, 15, 11, 0), utc(1, 15, 13, 0)},
+		{"window ends", "Europe/Berlin", minutes(12, 0), minutes(14, 0), utc(1, 15, 13, 0), time.Time{}},
+		{"summer time", "Europe/Berlin", minutes(12, 0), minutes(14, 0), utc(7, 15, 11, 30), utc(7, 15, 12, 0)},
+
+		// windows that wrap past midnight
+		{"before wrapping window", "UTC", minutes(22, 0), minutes(7, 0), utc(1, 15, 21, 59), time.Time{}},
+		{"wrapping window starts", "UTC", minutes(22, 0), minutes(7, 0), utc(1, 15, 22, 0), utc(1, 16, 7, 0)},
+		{"after midnight", "UTC", minutes(22, 0), minutes(7, 0), utc(1, 16, 3, 0), utc(1, 16, 7, 0)},
+		{"last quiet minute", "UTC", minutes(22, 0), minutes(7, 0), utc(1, 16, 6, 59), utc(1, 16, 7, 0)},
+		{"wrapping window ends", "UTC", minutes(22, 0), minutes(7, 0), utc(1, 16, 7, 0), time.Time{}},
+		{"new year", "UTC", minutes(22, 0), minutes(7, 0), utc(12, 31, 23, 0), time.Date(2027, 1, 1, 7, 0, 0, 0, time.UTC)},
+
+		// the local date differs from the UTC date
+		{"west of UTC before window", "America/New_York", minutes(22, 0), minutes(6, 0), utc(1, 16, 2, 59), time.Time{}},
+		{"west of UTC window starts", "America/New_York", minutes(22, 0), minutes(6, 0), utc(1, 16, 3, 0), utc(1, 16, 11, 0)},
+		{"west of UTC last minute", "America/New_York", minutes(22, 0), minutes(6, 0), utc(1, 16, 10, 59), utc(1, 16, 11, 0)},
+		{"east of UTC window starts", "Asia/Tokyo", minutes(23, 0), minutes(8, 0), utc(1, 15, 14, 0), utc(1, 15, 23, 0)},
+		{"east of UTC after midnight", "Asia/Tokyo", minutes(23, 0), minutes(8, 0), utc(1, 15, 16, 0), utc(1, 15, 23, 0)},
+		{"east of UTC window ends", "Asia/Tokyo", minutes(23, 0), minutes(8, 0), utc(1, 15, 23, 0), time.Time{}},
+		{"half-hour offset", "Asia/Kolkata", minutes(21, 0), minutes(9, 0), utc(1, 15, 15, 30), utc(1, 16, 3, 30)},
+
+		// quiet hours ending on the morning clocks go forward
+		{"spring forward", "America/New_York", minutes(22, 0), minutes(7, 0), utc(3, 8, 3, 0), utc(3, 8, 11, 0)},
+
+		{"unknown zone is UTC", "Mars/Olympus_Mons", minutes(22, 0), minutes(7, 0), utc(1, 15, 23, 0), utc(1, 16, 7, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := &db.NotificationPreferences{QuietStart: tt.start, QuietEnd: tt.end, TimeZone: tt.zone}
+			got, quiet := quietUntil(prefs, tt.now)
+			if quiet != !tt.want.IsZero() || !got.Equal(tt.want) {
+				t.Errorf("quietUntil(%s) = %v, %v; want %v", tt.now, got.UTC(), quiet, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	now := time.Date(2026, 1, 15, 23, 0, 0, 0, time.UTC)
+	prefs := &db.NotificationPreferences{
+		Channels:       []string{db.ChannelEmail},
+		OptedOutEvents: []string{"order_cancelled"},
+		QuietStart:     minutes(22, 0),
+		QuietEnd:       minutes(7, 0),
+		TimeZone:       "UTC",
+	}
+	tests := []struct {
+		name           string
+		prefs          *db.NotificationPreferences
+		event, channel string
+		addr           string
+		wantReason     string // empty when sent
+		wantUntil      time.Time
+	}{
+		{"no preferences", nil, "order_placed", db.ChannelSMS, "+15550100", "", time.Time{}},
+		{"no address", nil, "order_placed", db.ChannelSMS, "", "no sms address", time.Time{}},
+		{"disabled channel", prefs, "order_placed", db.ChannelSMS, "+15550100", "customer disabled sms", time.Time{}},
+		{"opted out", prefs, "order_cancelled", db.ChannelEmail, "a@example.com", "customer opted out of order_cancelled", time.Time{}},
+		{"quiet hours", prefs, "order_placed", db.ChannelEmail, "a@example.com", "quiet hours until 07:00 UTC",
+			time.Date(2026, 1, 16, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := suppress(tt.prefs, tt.event, tt.channel, tt.addr, now)
+			switch {
+			case tt.wantReason == "" && s != nil:
+				t.Errorf("suppress = %v, want nil", s)
+			case tt.wantReason == "":
+			case s == nil:
+				t.Errorf("suppress = nil, want %q", tt.wantReason)
+			case s.Reason != tt.wantReason || s.Channel != tt.channel || !s.Until.Equal(tt.wantUntil):
+				t.Errorf("suppress = %+v, want %q until %v", s, tt.wantReason, tt.wantUntil)
+			}
+		})
+	}
+}
+
+type prefsRepo map[uuid.UUID]*db.NotificationPreferences
+
+func (r prefsRepo) Get(ctx context.Context, customerID uuid.UUID) (*db.NotificationPreferences, error) {
+	if p, ok := r[customerID]; ok {
+		return p, nil
+	}
+	return db.DefaultNotificationPreferences(customerID), nil
+}
+
+func (r prefsRepo) Save(ctx context.Context, p *db.NotificationPreferences) error {
+	r[p.CustomerID] = p
+	return nil
+}
+
+func TestRouterOptOut(t *testing.T) {
+	optedOut, other := uuid.New(), uuid.New()
+	prefs := prefsRepo{optedOut: {
+		CustomerID:     optedOut,
+		Channels:       []string{db.ChannelEmail, db.ChannelSMS},
+		OptedOutEvents: []string{"order_cancelled"},
+		TimeZone:       "UTC",
+	}}
+	sent := map[string]int{}
+	driver := func(channel string) Driver {
+		return DriverFunc(func(context.Context, string, Message) error {
+			sent[channel]++
+			return nil
+		})
+	}
+	r := &Router{Prefs: prefs, Drivers: map[string]Driver{
+		db.ChannelEmail: driver(db.ChannelEmail),
+		db.ChannelSMS:   driver(db.ChannelSMS),
+	}}
+	event := func(eventType string) Event {
+		return Event{Type: eventType, Messages: map[string]Message{
+			db.ChannelEmail: {Subject: "Order", Text: "text"},
+			db.ChannelSMS:   {Text: "text"},
+		}}
+	}
+	to := func(customerID uuid.UUID) Recipient {
+		return Recipient{CustomerID: &customerID, Email: "a@example.com", Phone: "+15550100"}
+	}
+	ctx := context.Background()
+
+	err := r.Notify(ctx, to(optedOut), event("order_cancelled"))
+	var held *SuppressedError
+	if !errors.As(err, &held) || !held.Until.IsZero() {
+		t.Errorf("Notify(opted out) error = %v, want a *SuppressedError for good", err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("opted out event was sent: %v", sent)
+	}
+
+	if err := r.Notify(ctx, to(optedOut), event("order_placed")); err != nil {
+		t.Errorf("Notify(other event) error = %v", err)
+	}
+	if err := r.Notify(ctx, to(other), event("order_cancelled")); err != nil {
+		t.Errorf("Notify(other customer) error = %v", err)
+	}
+	if sent[db.ChannelEmail] != 2 || sent[db.ChannelSMS] != 2 {
+		t.Errorf("sent = %v, want 2 per channel", sent)
+	}
+
+	// without a driver the channel is held back for good
+	delete(r.Drivers, db.ChannelSMS)
+	err = r.Notify(ctx, to(other), event("order_placed"))
+	if !errors.As(err, &held) || held.Channel != db.ChannelSMS || !held.Until.IsZero() {
+		t.Errorf("Notify without an SMS driver error = %v, want an SMS *SuppressedError for good", err)
+	}
+	if sent[db.ChannelEmail] != 3 || sent[db.ChannelSMS] != 2 {
+		t.Errorf("sent = %v, want only the email sent", sent)
+	}
+}
+
+func TestNewRouterSuppressesSMS(t *testing.T) {
+	svc := &fakeService{}
+	err := NewRouter(nil, svc).Notify(context.Background(),
+		Recipient{Email: "a@example.com", Phone: "+15550100"},
+		Event{Type: "order_placed", Messages: map[string]Message{
+			db.ChannelEmail: {Subject: "Order", Text: "text"},
+			db.ChannelSMS:   {Text: "text"},
+		}})
+	var held *SuppressedError
+	if !errors.As(err, &held) || held.Channel != db.ChannelSMS || !held.Until.IsZero() {
+		t.Errorf("Notify error = %v, want an SMS *SuppressedError for good", err)
+	}
+	if svc.emails != 1 || svc.sms != 0 {
+		t.Errorf("sent %d emails and %d SMS, want only the email", svc.emails, svc.sms)
+	}
+}
+
+// fakeService counts what is sent through it.
+type fakeService struct {
+	emails, sms int
+}
+
+func (s *fakeService) SendOrderSMS(ctx context.Context, phone, msg string) error {
+	s.sms++
+	return nil
+}
+
+func (s *fakeService) SendOrderEmail(ctx context.Context, email, subject, body, htmlBody string) error {
+	s.emails++
+	return nil
+}
@@ -4,5 +4,7 @@ import "context"
 
 type NotificationService interface {
 	SendOrderSMS(ctx context.Context, phone, msg string) error
-	SendOrderEmail(ctx context.Context, email, subject, body string) error
+	// SendOrderEmail sends body as plain text with htmlBody as its HTML
+	// alternative; an empty htmlBody is derived from body.
+	SendOrderEmail(ctx context.Context, email, subject, body, htmlBody string) error
 }
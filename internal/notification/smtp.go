@@ -0,0 +1,282 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLSMode selects how SMTPService secures its connection.
+type TLSMode string
+
+const (
+	// TLSStartTLS connects in plain text and upgrades with STARTTLS before
+	// authenticating. The server must offer STARTTLS.
+	TLSStartTLS TLSMode = "starttls"
+	// TLSImplicit speaks TLS from the first byte, as on port 465.
+	TLSImplicit TLSMode = "implicit"
+	// TLSNone never encrypts. PLAIN auth is then refused unless the server
+	// is on localhost, so this is only for local relays and tests.
+	TLSNone TLSMode = "none"
+)
+
+// DefaultSMTPTimeout bounds a whole delivery when SMTPConfig.Timeout is zero.
+const DefaultSMTPTimeout = 30 * time.Second
+
+// SMTPConfig configures SMTPService.
+type SMTPConfig struct {
+	Host     string // server host name; also checked against its certificate
+	Port     int    // defaults to 465 for TLSImplicit and 587 otherwise
+	Username string // PLAIN auth is used when set
+	Password string
+	From     string  // sender, e.g. "Shop <orders@example.com>"
+	TLS      TLSMode // defaults to TLSStartTLS
+
+	// Timeout bounds connecting and sending one message; the context's
+	// deadline applies when it is earlier. Defaults to DefaultSMTPTimeout.
+	Timeout time.Duration
+
+	// TLSConfig overrides the TLS settings, e.g. to trust a private CA.
+	// ServerName defaults to Host.
+	TLSConfig *tls.Config
+}
+
+// ErrSMSUnsupported is returned by SMTPService.SendOrderSMS. It is a
+// *PermanentError.
+var ErrSMSUnsupported = Permanent(errors.New("notification: SMS cannot be sent over SMTP"))
+
+// SMTPService delivers email through an SMTP server. Each message opens its
+// own connection, so the service is safe for concurrent use.
+type SMTPService struct {
+	cfg  SMTPConfig
+	from *mail.Address
+}
+
+// NewSMTPService checks cfg and fills in its defaults.
+func NewSMTPService(cfg SMTPConfig) (*SMTPService, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("notification: SMTP host is required")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("notification: invalid SMTP sender %q: %w", cfg.From, err)
+	}
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = TLSStartTLS
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("notification: unknown SMTP TLS mode %q (want starttls, implicit or none)", cfg.TLS)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == TLSImplicit {
+			cfg.Port = 465
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultSMTPTimeout
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{}
+	} else {
+		cfg.TLSConfig = cfg.TLSConfig.Clone()
+	}
+	if cfg.TLSConfig.ServerName == "" {
+		cfg.TLSConfig.ServerName = cfg.Host
+	}
+	return &SMTPService{cfg: cfg, from: from}, nil
+}
+
+// SendOrderSMS always fails with ErrSMSUnsupported.
+func (s *SMTPService) SendOrderSMS(ctx context.Context, phone, msg string) error {
+	return ErrSMSUnsupported
+}
+
+// SendOrderEmail sends body to email as a multipart/alternative message
+// with a plain text part and htmlBody, or an HTML rendering of body when
+// htmlBody is empty. An invalid
+// address, or a recipient or message the server rejects with a 5xx reply,
+// yields a *PermanentError.
+func (s *SMTPService) SendOrderEmail(ctx context.Context, email, subject, body, htmlBody string) error {
+	to, err := mail.ParseAddress(email)
+	if err != nil {
+		return Permanent(fmt.Errorf("notification: invalid recipient %q: %w", email, err))
+	}
+	if htmlBody == "" {
+		htmlBody = textToHTML(body)
+	}
+	msg, err := s.compose(to, subject, body, htmlBody, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := s.send(ctx, to.Address, msg); err != nil {
+		return fmt.Errorf("notification: send email to %s: %w", to.Address, err)
+	}
+	return nil
+}
+
+// send delivers msg in one SMTP session.
+func (s *SMTPService) send(ctx context.Context, rcpt string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// net/smtp has no context support; the deadline bounds every exchange
+	// and closing the connection aborts it on cancellation
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if s.cfg.TLS == TLSImplicit {
+		conn = tls.Client(conn, s.cfg.TLSConfig)
+	}
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if s.cfg.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(s.cfg.TLSConfig); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(rcpt); err != nil {
+		return rejected(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return rejected(err)
+	}
+	// the server has accepted the message; failing now would make the
+	// outbox send it again
+	c.Quit()
+	return nil
+}
+
+// compose builds an RFC 5322 message. Both parts are quoted-printable UTF-8
+// and every line ends in CRLF.
+func (s *SMTPService) compose(to *mail.Address, subject, body, htmlBody string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	// a subject is one header line; line breaks would start new headers
+	subject = strings.Join(strings.Fields(subject), " ")
+	id, err := messageID(s.from.Address)
+	if err != nil {
+		return nil, err
+	}
+	headers := []struct{ key, value string }{
+		{"From", s.from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", id},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()})},
+	}
+	var head bytes.Buffer
+	for _, h := range headers {
+		fmt.Fprintf(&head, "%s: %s\r\n", h.key, h.value)
+	}
+	head.WriteString("\r\n")
+
+	// in multipart/alternative the last part is the preferred one
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", body},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(crlf(part.content))); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// messageID returns a unique Message-ID in the sender's domain.
+func messageID(from string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	_, domain, _ := strings.Cut(from, "@")
+	return "<" + hex.EncodeToString(b[:]) + "@" + domain + ">", nil
+}
+
+// textToHTML renders plain text as escaped HTML paragraphs, keeping single
+// line breaks.
+func textToHTML(text string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><body>\n")
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		lines := strings.Split(para, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// crlf normalizes line endings to CRLF.
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
@@ -0,0 +1,230 @@
+package notification_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification/smtptest"
+)
+
+const testSender = "Shop <orders@example.com>"
+
+func newSMTPServer(t *testing.T, mode notification.TLSMode) *smtptest.Server {
+	t.Helper()
+	srv, err := smtptest.NewServer(mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func newSMTPService(t *testing.T, cfg notification.SMTPConfig) *notification.SMTPService {
+	t.Helper()
+	svc, err := notification.NewSMTPService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestSMTPServiceTLSModes(t *testing.T) {
+	for _, mode := range []notification.TLSMode{notification.TLSStartTLS, notification.TLSImplicit, notification.TLSNone} {
+		t.Run(string(mode), func(t *testing.T) {
+			srv := newSMTPServer(t, mode)
+			srv.RequireAuth("user", "secret")
+			svc := newSMTPService(t, srv.Config(testSender))
+
+			if err := svc.SendOrderEmail(context.Background(), "alice@example.com", "Hi", "Hello", ""); err != nil {
+				t.Fatalf("SendOrderEmail: %v", err)
+			}
+			msgs := srv.Messages()
+			if len(msgs) != 1 {
+				t.Fatalf("server accepted %d messages, want 1", len(msgs))
+			}
+			m := msgs[0]
+			if m.From != "orders@example.com" || len(m.To) != 1 || m.To[0] != "alice@example.com" {
+				t.Errorf("envelope = %s → %v", m.From, m.To)
+			}
+			if m.User != "user" {
+				t.Errorf("authenticated as %q, want user", m.User)
+			}
+			if wantTLS := mode != notification.TLSNone; m.TLS != wantTLS {
+				t.Errorf("session TLS = %v, want %v", m.TLS, wantTLS)
+			}
+		})
+	}
+}
+
+func TestSMTPServiceRequiresStartTLS(t *testing.T) {
+	srv := newSMTPServer(t, notification.TLSNone)
+	cfg := srv.Config(testSender)
+	cfg.TLS = notification.TLSStartTLS
+	svc := newSMTPService(t, cfg)
+
+	err := svc.SendOrderEmail(context.Background(), "alice@example.com", "Hi", "Hello", "")
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("SendOrderEmail without STARTTLS error = %v", err)
+	}
+	if len(srv.Messages()) != 0 {
+		t.Error("message was sent in plain text")
+	}
+}
+
+func TestSMTPServiceWrongPassword(t *testing.T) {
+	srv := newSMTPServer(t, notification.TLSStartTLS)
+	srv.RequireAuth("user", "secret")
+	cfg := srv.Config(testSender)
+	cfg.Password = "wrong"
+	svc := newSMTPService(t, cfg)
+
+	if err := svc.SendOrderEmail(context.Background(), "alice@example.com", "Hi", "Hello", ""); err == nil {
+		t.Error("SendOrderEmail with a wrong password succeeded")
+	}
+	if len(srv.Messages()) != 0 {
+		t.Error("message was accepted without authentication")
+	}
+}
+
+func TestSMTPServicePermanentErrors(t *testing.T) {
+	srv := newSMTPServer(t, notification.TLSStartTLS)
+	srv.RejectRecipient("gone@example.com")
+	svc := newSMTPService(t, srv.Config(testSender))
+	ctx := context.Background()
+
+	err := svc.SendOrderEmail(ctx, "gone@example.com", "Hi", "Hello", "")
+	if !notification.IsPermanent(err) {
+		t.Errorf("rejected recipient error = %v, want a *PermanentError", err)
+	}
+	if err := svc.SendOrderEmail(ctx, "not an address", "Hi", "Hello", ""); !notification.IsPermanent(err) {
+		t.Errorf("invalid address error = %v, want a *PermanentError", err)
+	}
+	if err := svc.SendOrderSMS(ctx, "+15550100", "Hello"); !notification.IsPermanent(err) {
+		t.Errorf("SendOrderSMS error = %v, want a *PermanentError", err)
+	}
+
+	// a network failure is transient
+	srv.Close()
+	if err := svc.SendOrderEmail(ctx, "alice@example.com", "Hi", "Hello", ""); err == nil || notification.IsPermanent(err) {
+		t.Errorf("closed server error = %v, want a transient error", err)
+	}
+}
+
+func TestSMTPServiceIgnoresQuitFailure(t *testing.T) {
+	srv := newSMTPServer(t, notification.TLSStartTLS)
+	srv.DropOnQuit()
+	svc := newSMTPService(t, srv.Config(testSender))
+
+	if err := svc.SendOrderEmail(context.Background(), "alice@example.com", "Hi", "Hello", ""); err != nil {
+		t.Errorf("SendOrderEmail after an accepted DATA error = %v, want nil", err)
+	}
+	if n := len(srv.Messages()); n != 1 {
+		t.Errorf("server accepted %d messages, want 1", n)
+	}
+}
+
+func TestSMTPServiceMultipartMessage(t *testing.T) {
+	tests := []struct {
+		name, body, html string
+		wantText         string
+		wantHTML         []string
+	}{
+		{
+			name:     "explicit HTML",
+			body:     "Order 1234 confirmed.\nTotal: 12.50 €",
+			html:     "<p>Order <b>1234</b> confirmed.</p>",
+			wantText: "Order 1234 confirmed.\r\nTotal: 12.50 €",
+			wantHTML: []string{"<p>Order <b>1234</b> confirmed.</p>"},
+		},
+		{
+			name:     "derived HTML",
+			body:     "Dear <Ada>,\nthanks!\n\nBye",
+			wantText: "Dear <Ada>,\r\nthanks!\r\n\r\nBye",
+			wantHTML: []string{"<p>Dear &lt;Ada&gt;,<br>\r\nthanks!</p>", "<p>Bye</p>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSMTPServer(t, notification.TLSStartTLS)
+			svc := newSMTPService(t, srv.Config(testSender))
+			subject := "Bestellung bestätigt\r\nBcc: evil@example.com"
+			if err := svc.SendOrderEmail(context.Background(), "Ada <ada@example.com>", subject, tt.body, tt.html); err != nil {
+				t.Fatalf("SendOrderEmail: %v", err)
+			}
+			msgs := srv.Messages()
+			if len(msgs) != 1 {
+				t.Fatalf("server accepted %d messages, want 1", len(msgs))
+			}
+			m, err := msgs[0].Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dec := new(mime.WordDecoder)
+			gotSubject, err := dec.DecodeHeader(m.Header.Get("Subject"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotSubject != "Bestellung bestätigt Bcc: evil@example.com" {
+				t.Errorf("Subject = %q, want one line", gotSubject)
+			}
+			if m.Header.Get("Bcc") != "" {
+				t.Error("subject injected a Bcc header")
+			}
+			if got := m.Header.Get("From"); got != `"Shop" <orders@example.com>` {
+				t.Errorf("From = %q", got)
+			}
+			if got := m.Header.Get("To"); got != `"Ada" <ada@example.com>` {
+				t.Errorf("To = %q", got)
+			}
+			if !strings.HasSuffix(m.Header.Get("Message-ID"), "@example.com>") || m.Header.Get("Date") == "" {
+				t.Errorf("Message-ID = %q, Date = %q", m.Header.Get("Message-ID"), m.Header.Get("Date"))
+			}
+			if got := m.Header.Get("MIME-Version"); got != "1.0" {
+				t.Errorf("MIME-Version = %q", got)
+			}
+
+			mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/alternative" || params["boundary"] == "" {
+				t.Fatalf("Content-Type = %q, want multipart/alternative with a boundary", m.Header.Get("Content-Type"))
+			}
+			mr := multipart.NewReader(m.Body, params["boundary"])
+			var parts []string
+			for i, want := range []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"} {
+				p, err := mr.NextRawPart()
+				if err != nil {
+					t.Fatalf("part %d: %v", i, err)
+				}
+				if got := p.Header.Get("Content-Type"); got != want {
+					t.Errorf("part %d Content-Type = %q, want %q", i, got, want)
+				}
+				if got := p.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+					t.Errorf("part %d Content-Transfer-Encoding = %q", i, got)
+				}
+				content, err := io.ReadAll(quotedprintable.NewReader(p))
+				if err != nil {
+					t.Fatal(err)
+				}
+				parts = append(parts, string(content))
+			}
+			if _, err := mr.NextPart(); err != io.EOF {
+				t.Errorf("extra part after text and HTML: %v", err)
+			}
+
+			if parts[0] != tt.wantText {
+				t.Errorf("text part = %q, want %q", parts[0], tt.wantText)
+			}
+			for _, want := range tt.wantHTML {
+				if !strings.Contains(parts[1], want) {
+					t.Errorf("HTML part = %q, want it to contain %q", parts[1], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// Package smtptest runs an in-process SMTP server that records every message
+// it accepts, so notification.SMTPService can be exercised without a real
+// mail server.
+//
+//	srv, _ := smtptest.NewServer(notification.TLSStartTLS)
+//	defer srv.Close()
+//	srv.RequireAuth("user", "secret")
+//	svc, _ := notification.NewSMTPService(srv.Config("Shop <orders@example.com>"))
+//	_ = svc.SendOrderEmail(ctx, "alice@example.com", "Hi", "Hello", "")
+//	msgs := srv.Messages()
+//
+// The server speaks just enough SMTP for net/smtp: EHLO/HELO, STARTTLS,
+// AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT. TLS uses a
+// self-signed certificate for 127.0.0.1 that Config trusts.
+package smtptest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification"
+)
+
+// Host is the address the server listens on and names in its certificate.
+const Host = "127.0.0.1"
+
+// Message is one accepted message.
+type Message struct {
+	From string   // MAIL FROM address
+	To   []string // RCPT TO addresses
+	Data []byte   // raw message as sent after DATA, dot-unstuffed
+	User string   // authenticated user name, if any
+	TLS  bool     // whether the session was encrypted
+}
+
+// Parse reads the message headers and body.
+func (m Message) Parse() (*mail.Message, error) {
+	return mail.ReadMessage(bytes.NewReader(m.Data))
+}
+
+// Server is a running SMTP stand-in.
+type Server struct {
+	mode notification.TLSMode
+	ln   net.Listener
+	tls  *tls.Config
+	pool *x509.CertPool
+
+	mu       sync.Mutex
+	messages []Message
+	user     string
+	pass     string
+	reject   string // RCPT address to refuse, if set
+	dropQuit bool   // hang up on QUIT instead of replying
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a free port of Host. With TLSImplicit every
+// connection is TLS from the start; with TLSStartTLS the server offers
+// STARTTLS; with TLSNone it offers neither.
+func NewServer(mode notification.TLSMode) (*Server, error) {
+	cert, pool, err := selfSigned()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		mode: mode,
+		tls:  &tls.Config{Certificates: []tls.Certificate{cert}},
+		pool: pool,
+	}
+	s.ln, err = net.Listen("tcp", net.JoinHostPort(Host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	if mode == notification.TLSImplicit {
+		s.ln = tls.NewListener(s.ln, s.tls)
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the listening address.
+func (s *Server) Addr() *net.TCPAddr {
+	return s.ln.Addr().(*net.TCPAddr)
+}
+
+// Config returns an SMTPConfig that delivers to s as from, trusting its
+// certificate and using the credentials set by RequireAuth.
+func (s *Server) Config(from string) notification.SMTPConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return notification.SMTPConfig{
+		Host:      Host,
+		Port:      s.Addr().Port,
+		Username:  s.user,
+		Password:  s.pass,
+		From:      from,
+		TLS:       s.mode,
+		Timeout:   5 * time.Second,
+		TLSConfig: &tls.Config{RootCAs: s.pool},
+	}
+}
+
+// RequireAuth makes the server demand AUTH PLAIN with user and pass before
+// MAIL.
+func (s *Server) RequireAuth(user, pass string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user, s.pass = user, pass
+}
+
+// RejectRecipient makes the server refuse RCPT TO addr with a 550 reply.
+func (s *Server) RejectRecipient(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = addr
+}
+
+// DropOnQuit makes the server close the connection on QUIT without
+// replying, after any message has already been accepted.
+func (s *Server) DropOnQuit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropQuit = true
+}
+
+// Messages returns the messages accepted so far, oldest first.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops the server and waits for open sessions to end.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(time.Minute))
+			s.session(conn)
+		}()
+	}
+}
+
+// session runs one SMTP conversation until QUIT or a broken connection.
+func (s *Server) session(conn net.Conn) {
+	s.mu.Lock()
+	user, pass, reject, dropQuit := s.user, s.pass, s.reject, s.dropQuit
+	s.mu.Unlock()
+
+	_, encrypted := conn.(*tls.Conn)
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) { tp.PrintfLine("%d %s", code, msg) }
+
+	var msg Message
+	authed := user == ""
+	reply(220, Host+" smtptest ready")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ext := []string{"250-" + Host, "250-8BITMIME"}
+			if s.mode == notification.TLSStartTLS && !encrypted {
+				ext = append(ext, "250-STARTTLS")
+			}
+			if user != "" {
+				ext = append(ext, "250-AUTH PLAIN")
+			}
+			ext = append(ext, "250 SMTPUTF8")
+			for _, l := range ext {
+				tp.PrintfLine("%s", l)
+			}
+		case "HELO":
+			reply(250, Host)
+		case "STARTTLS":
+			if s.mode != notification.TLSStartTLS || encrypted {
+				reply(502, "STARTTLS not available")
+				continue
+			}
+			reply(220, "ready to start TLS")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, encrypted = tc, true
+			tp = textproto.NewConn(conn)
+			msg = Message{}
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mech, "PLAIN") || user == "" {
+				reply(504, "unsupported authentication mechanism")
+				continue
+			}
+			if initial == "" {
+				reply(334, "")
+				if initial, err = tp.ReadLine(); err != nil {
+					return
+				}
+			}
+			id, ok := checkPlain(initial, user, pass)
+			if !ok {
+				reply(535, "authentication failed")
+				continue
+			}
+			authed, msg.User = true, id
+			reply(235, "authenticated")
+		case "MAIL":
+			if !authed {
+				reply(530, "authentication required")
+				continue
+			}
+			msg.From, msg.To = addrArg(arg, "FROM:"), nil
+			reply(250, "OK")
+		case "RCPT":
+			to := addrArg(arg, "TO:")
+			if reject != "" && strings.EqualFold(to, reject) {
+				reply(550, "mailbox unavailable")
+				continue
+			}
+			msg.To = append(msg.To, to)
+			reply(250, "OK")
+		case "DATA":
+			if msg.From == "" || len(msg.To) == 0 {
+				reply(503, "need MAIL and RCPT first")
+				continue
+			}
+			reply(354, "end data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data, msg.TLS = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n")), encrypted
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = Message{User: msg.User}
+			reply(250, "OK queued")
+		case "RSET":
+			msg = Message{User: msg.User}
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			if !dropQuit {
+				reply(221, "bye")
+			}
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+// addrArg extracts the address from "FROM:<a@b> SIZE=…" style arguments.
+func addrArg(arg, prefix string) string {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return ""
+	}
+	addr, _, _ := strings.Cut(strings.TrimSpace(arg[len(prefix):]), " ")
+	return strings.Trim(addr, "<>")
+}
+
+// checkPlain validates an AUTH PLAIN response ("authzid\0user\0pass").
+func checkPlain(b64, user, pass string) (string, bool) {
+	raw, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(string(raw), "\x00")
+	if len(parts) != 3 || parts[1] != user || parts[2] != pass {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// selfSigned makes a certificate for Host and a pool that trusts it.
+func selfSigned() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtptest"},
+		IPAddresses:  []net.IP{net.ParseIP(Host)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
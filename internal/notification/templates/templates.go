@@ -0,0 +1,271 @@
+// Package templates renders notification content from text/template and
+// html/template files, keyed by event and locale.
+//
+// Each event and locale has its own directory of templates:
+//
+//	order_placed/en/subject.txt   required, one line
+//	order_placed/en/body.txt      required, plain text email body
+//	order_placed/en/body.html     optional, HTML email body
+//	order_placed/en/sms.txt       optional; no SMS is sent without it
+//
+// The defaults are embedded in the binary. Load layers further directories
+// on top, so a file on disk replaces the embedded file at the same path and
+// new locale directories add languages. Every template is executed against
+// a sample OrderView when loaded, so mistakes fail at startup rather than
+// when an order is placed.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Event names something a customer is notified about.
+type Event string
+
+const (
+	OrderPlaced    Event = "order_placed"
+	OrderCancelled Event = "order_cancelled"
+)
+
+// Events lists every Event; each needs templates for DefaultLocale.
+var Events = []Event{OrderPlaced, OrderCancelled}
+
+// DefaultLocale is used when no templates match the requested locale.
+const DefaultLocale = "en"
+
+// The template files of one event and locale.
+const (
+	subjectFile = "subject.txt"
+	textFile    = "body.txt"
+	htmlFile    = "body.html"
+	smsFile     = "sms.txt"
+)
+
+//go:embed defaults
+var embedded embed.FS
+
+// Embedded holds the default templates.
+var Embedded, _ = fs.Sub(embedded, "defaults")
+
+// Rendered is the content of one notification.
+type Rendered struct {
+	Event   Event
+	Locale  string // locale whose templates were used, after fallback
+	Subject string
+	Text    string
+	HTML    string // empty without a body.html template
+	SMS     string // empty without an sms.txt template
+}
+
+// Registry holds parsed templates. It is safe for concurrent use.
+type Registry struct {
+	sets map[Event]map[string]*set
+}
+
+type set struct {
+	subject, text, sms *texttemplate.Template
+	html               *htmltemplate.Template
+}
+
+// Default returns a Registry of the embedded templates. It panics if they
+// do not load, which a build with broken templates should not survive.
+func Default() *Registry {
+	r, err := Load(Embedded)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Load parses the templates in layers, later layers replacing files of
+// earlier ones.
+func Load(layers ...fs.FS) (*Registry, error) {
+	files := make(map[string][]byte)
+	for _, layer := range layers {
+		err := fs.WalkDir(layer, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// skip editor and VCS droppings such as .git or .DS_Store
+			if p != "." && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			b, err := fs.ReadFile(layer, p)
+			if err != nil {
+				return err
+			}
+			files[p] = b
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("templates: %w", err)
+		}
+	}
+
+	r := &Registry{sets: make(map[Event]map[string]*set)}
+	for p, b := range files {
+		if err := r.add(p, string(b)); err != nil {
+			return nil, fmt.Errorf("templates: %s: %w", p, err)
+		}
+	}
+	if err := r.check(); err != nil {
+		return nil, fmt.Errorf("templates: %w", err)
+	}
+	return r, nil
+}
+
+// add parses the file at p, which must be event/locale/file.
+func (r *Registry) add(p, src string) error {
+	parts := strings.Split(p, "/")
+	if len(parts) != 3 {
+		return errors.New("want event/locale/file")
+	}
+	event, locale, file := Event(parts[0]), NormalizeLocale(parts[1]), parts[2]
+	if !knownEvent(event) {
+		return fmt.Errorf("unknown event %q", event)
+	}
+	if locale != parts[1] {
+		return fmt.Errorf("locale directory must be a lower-case tag such as %q", locale)
+	}
+	if r.sets[event] == nil {
+		r.sets[event] = make(map[string]*set)
+	}
+	s := r.sets[event][locale]
+	if s == nil {
+		s = &set{}
+		r.sets[event][locale] = s
+	}
+
+	funcs := funcMap(locale)
+	var err error
+	switch file {
+	case subjectFile:
+		s.subject, err = texttemplate.New(p).Funcs(funcs).Parse(src)
+	case textFile:
+		s.text, err = texttemplate.New(p).Funcs(funcs).Parse(src)
+	case smsFile:
+		s.sms, err = texttemplate.New(p).Funcs(funcs).Parse(src)
+	case htmlFile:
+		s.html, err = htmltemplate.New(p).Funcs(htmltemplate.FuncMap(funcs)).Parse(src)
+	default:
+		return fmt.Errorf("unknown template file %q", file)
+	}
+	return err
+}
+
+// check requires a subject and text body in every set and the default
+// locale for every event, and renders every set with sample data.
+func (r *Registry) check() error {
+	for _, event := range Events {
+		if r.sets[event][DefaultLocale] == nil {
+			return fmt.Errorf("%s has no %s templates", event, DefaultLocale)
+		}
+	}
+	for event, locales := range r.sets {
+		for locale, s := range locales {
+			if s.subject == nil || s.text == nil {
+				return fmt.Errorf("%s/%s needs %s and %s", event, locale, subjectFile, textFile)
+			}
+			if _, err := s.render(event, locale, sampleView); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Render renders event for v in locale, falling back to less specific tags
+// ("de-at" to "de") and finally to DefaultLocale.
+func (r *Registry) Render(event Event, locale string, v OrderView) (*Rendered, error) {
+	locales, ok := r.sets[event]
+	if !ok {
+		return nil, fmt.Errorf("templates: unknown event %q", event)
+	}
+	tag := NormalizeLocale(locale)
+	for tag != "" && locales[tag] == nil {
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			tag = ""
+			break
+		}
+		tag = tag[:i]
+	}
+	if tag == "" {
+		tag = DefaultLocale
+	}
+	return locales[tag].render(event, tag, v)
+}
+
+// Locales returns the locales with templates for event, sorted.
+func (r *Registry) Locales(event Event) []string {
+	var out []string
+	for locale := range r.sets[event] {
+		out = append(out, locale)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *set) render(event Event, locale string, v OrderView) (*Rendered, error) {
+	out := &Rendered{Event: event, Locale: locale}
+	var err error
+	if out.Subject, err = execute(s.subject, v); err != nil {
+		return nil, err
+	}
+	// a subject is one header line
+	out.Subject = strings.Join(strings.Fields(out.Subject), " ")
+	if out.Text, err = execute(s.text, v); err != nil {
+		return nil, err
+	}
+	out.Text += "\n"
+	if s.sms != nil {
+		if out.SMS, err = execute(s.sms, v); err != nil {
+			return nil, err
+		}
+	}
+	if s.html != nil {
+		var buf bytes.Buffer
+		if err := s.html.Execute(&buf, v); err != nil {
+			return nil, err
+		}
+		out.HTML = buf.String()
+	}
+	return out, nil
+}
+
+func execute(t *texttemplate.Template, v OrderView) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, v); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// NormalizeLocale lower-cases a BCP 47 tag and uses "-" as its separator,
+// so "de_AT" becomes "de-at".
+func NormalizeLocale(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+func knownEvent(e Event) bool {
+	for _, known := range Events {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
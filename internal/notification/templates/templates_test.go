@@ -0,0 +1,120 @@
+package templates
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func file(src string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(src)}
+}
+
+func TestRenderFallsBackToLessSpecificLocales(t *testing.T) {
+	r := Default()
+	tests := []struct {
+		locale, want string
+	}{
+		{"de", "de"},
+		{"de-at", "de"},
+		{"de_AT", "de"},
+		{"DE-CH-1996", "de"},
+		{"en-gb", "en"},
+		{"fr", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			got, err := r.Render(OrderPlaced, tt.locale, sampleView)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Locale != tt.want {
+				t.Errorf("Render(%q) used %q templates, want %q", tt.locale, got.Locale, tt.want)
+			}
+		})
+	}
+
+	de, err := r.Render(OrderPlaced, "de-at", sampleView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if de.Subject != "Bestellung 6f1c2d3e bestätigt" || de.HTML == "" || de.SMS == "" {
+		t.Errorf("Render(de-at) = %+v, want the German subject, HTML and SMS", de)
+	}
+	if _, err := r.Render("order_shipped", "en", sampleView); err == nil {
+		t.Error("Render of an unknown event succeeded")
+	}
+}
+
+func TestLoadLayersOverEmbedded(t *testing.T) {
+	r, err := Load(Embedded, fstest.MapFS{
+		"order_placed/en/subject.txt": file("Thanks for order {{.Reference}}"),
+		"order_placed/fr/subject.txt": file("Commande {{.Reference}} confirmée"),
+		"order_placed/fr/body.txt":    file("Merci, {{.Customer.Name}}."),
+		".git/config":                 file("ignored"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	en, err := r.Render(OrderPlaced, "en", sampleView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if en.Subject != "Thanks for order 6f1c2d3e" {
+		t.Errorf("en subject = %q, want the one on disk", en.Subject)
+	}
+	if !strings.Contains(en.Text, "Analytical Engine") || en.HTML == "" || en.SMS == "" {
+		t.Errorf("en = %+v, want the embedded bodies and SMS kept", en)
+	}
+
+	fr, err := r.Render(OrderPlaced, "fr-ca", sampleView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.Locale != "fr" || fr.Subject != "Commande 6f1c2d3e confirmée" || fr.Text != "Merci, Ada Lovelace.\n" || fr.HTML != "" || fr.SMS != "" {
+		t.Errorf("fr = %+v, want only the files on disk", fr)
+	}
+	if got, want := r.Locales(OrderPlaced), []string{"de", "en", "fr"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Locales = %v, want %v", got, want)
+	}
+	if got, want := r.Locales(OrderCancelled), []string{"de", "en"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Locales(order_cancelled) = %v, want %v", got, want)
+	}
+}
+
+func TestLoadRejectsBadTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr string
+	}{
+		{"missing subject", fstest.MapFS{"order_placed/fr/body.txt": file("Merci.")}, "needs subject.txt and body.txt"},
+		{"missing body", fstest.MapFS{"order_placed/fr/subject.txt": file("Commande")}, "needs subject.txt and body.txt"},
+		{"unknown event", fstest.MapFS{"order_shipped/en/subject.txt": file("Shipped")}, `unknown event "order_shipped"`},
+		{"unknown file", fstest.MapFS{"order_placed/en/footer.txt": file("Bye")}, `unknown template file "footer.txt"`},
+		{"too deep", fstest.MapFS{"order_placed/en/extra/subject.txt": file("x")}, "want event/locale/file"},
+		{"upper-case locale", fstest.MapFS{"order_placed/de-AT/subject.txt": file("x")}, `such as "de-at"`},
+		{"parse error", fstest.MapFS{"order_placed/en/subject.txt": file("{{.Reference")}, "subject.txt"},
+		{"execution error", fstest.MapFS{"order_placed/en/subject.txt": file("{{.Missing}}")}, "Missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(Embedded, tt.files)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// every event needs the default locale
+	_, err := Load(fstest.MapFS{
+		"order_placed/de/subject.txt": file("Bestellung"),
+		"order_placed/de/body.txt":    file("Danke."),
+	})
+	if err == nil || !strings.Contains(err.Error(), "has no en templates") {
+		t.Errorf("Load without %s templates error = %v", DefaultLocale, err)
+	}
+}
@@ -0,0 +1,139 @@
+package templates
+
+import (
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+)
+
+// OrderView is the data order templates are rendered with.
+type OrderView struct {
+	ID        string
+	Reference string // first eight characters of ID, for subjects and SMS
+	Status    string // e.g. "pending"
+	PlacedAt  time.Time
+	Customer  CustomerView
+	Items     []LineItem
+	Quantity  int // units over all items
+	Total     money.Money
+
+	// Set once the order is cancelled.
+	CancelReason string // reason code, e.g. "ordered_by_mistake"
+	CancelNote   string
+}
+
+// CustomerView is the customer an order belongs to.
+type CustomerView struct {
+	Name  string
+	Email string
+	Phone string // empty when unknown
+}
+
+// LineItem is one item of an order.
+type LineItem struct {
+	ProductID   string
+	ProductName string
+	Quantity    int
+	UnitPrice   money.Money // price when the order was placed
+	Subtotal    money.Money // UnitPrice times Quantity
+}
+
+// NewOrderView builds the view of o. products supplies the item names; an
+// item whose product is missing is named by its product ID.
+func NewOrderView(o *db.Order, items []*db.OrderItem, products map[uuid.UUID]*db.Product, cust *db.Customer) OrderView {
+	v := OrderView{
+		ID:        o.ID.String(),
+		Reference: o.ID.String()[:8],
+		Status:    o.Status,
+		PlacedAt:  o.CreatedAt,
+		Customer:  CustomerView{Name: cust.Name, Email: cust.Email},
+		Total:     o.Total,
+	}
+	if cust.Phone != nil {
+		v.Customer.Phone = *cust.Phone
+	}
+	if o.CancelReason != nil {
+		v.CancelReason = *o.CancelReason
+	}
+	if o.CancelNote != nil {
+		v.CancelNote = *o.CancelNote
+	}
+	for _, it := range items {
+		name := it.ProductID.String()
+		if p, ok := products[it.ProductID]; ok {
+			name = p.Name
+		}
+		// placeOrder already checked that every line fits
+		subtotal, _ := it.UnitPrice.Mul(it.Quantity)
+		v.Items = append(v.Items, LineItem{
+			ProductID:   it.ProductID.String(),
+			ProductName: name,
+			Quantity:    it.Quantity,
+			UnitPrice:   it.UnitPrice,
+			Subtotal:    subtotal,
+		})
+		v.Quantity += it.Quantity
+	}
+	return v
+}
+
+// sampleView is rendered by every template when it is loaded.
+var sampleView = OrderView{
+	ID:        "6f1c2d3e-4b5a-4c7d-8e9f-a0b1c2d3e4f5",
+	Reference: "6f1c2d3e",
+	Status:    "cancelled",
+	PlacedAt:  time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC),
+	Customer:  CustomerView{Name: "Ada Lovelace", Email: "ada@example.com", Phone: "+15550100"},
+	Items: []LineItem{{
+		ProductID:   "0d9c8b7a-6f5e-4d3c-2b1a-0f9e8d7c6b5a",
+		ProductName: "Analytical Engine",
+		Quantity:    2,
+		UnitPrice:   money.MustParse("1234.50", money.DefaultCurrency),
+		Subtotal:    money.MustParse("2469.00", money.DefaultCurrency),
+	}},
+	Quantity:     2,
+	Total:        money.MustParse("2469.00", money.DefaultCurrency),
+	CancelReason: "other",
+	CancelNote:   "sample note",
+}
+
+// Number and date conventions by language. Other languages get a decimal
+// point and ISO dates.
+var formats = map[string]struct {
+	decimal string
+	date    string
+}{
+	"en": {".", "Jan 2, 2006"},
+	"de": {",", "02.01.2006"},
+	"fr": {",", "02/01/2006"},
+	"es": {",", "02/01/2006"},
+}
+
+// funcMap returns the template functions for locale:
+//
+//	money  formats an amount with the locale's decimal separator, "12,34 EUR"
+//	date   formats a time in UTC as the locale writes dates
+func funcMap(locale string) texttemplate.FuncMap {
+	lang, _, _ := strings.Cut(locale, "-")
+	f, ok := formats[lang]
+	if !ok {
+		f.decimal, f.date = ".", "2006-01-02"
+	}
+	return texttemplate.FuncMap{
+		"money": func(m money.Money) string {
+			s := m.String()
+			if f.decimal != "." {
+				s = strings.Replace(s, ".", f.decimal, 1)
+			}
+			return s
+		},
+		"date": func(t time.Time) string {
+			return t.UTC().Format(f.date)
+		},
+	}
+}
@@ -0,0 +1,185 @@
+// Package outbox delivers the notifications stored in the transactional
+// outbox. Writers enqueue messages through db.OutboxRepository in the same
+// transaction as the change they announce; a Dispatcher claims due messages,
+// hands them to a notification.Notifier and records the outcome. Messages
+// the customer's preferences rule out are marked suppressed, and those due
+// in quiet hours wait for them to end. Delivery is at least once: a
+// dispatcher that dies mid-send leaves its claim to expire, and the message
+// is sent again.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification"
+)
+
+// Defaults set by NewDispatcher.
+const (
+	DefaultBatchSize    = 20
+	DefaultPollInterval = 5 * time.Second
+	DefaultLease        = 2 * time.Minute
+	DefaultMaxAttempts  = 8
+	DefaultMinBackoff   = 30 * time.Second
+	DefaultMaxBackoff   = time.Hour
+)
+
+// releaseTimeout bounds handing back unsent messages on shutdown.
+const releaseTimeout = 5 * time.Second
+
+// Dispatcher moves messages from the outbox to their channel. Several
+// dispatchers, in one process or many, may share an outbox; claims keep them
+// from sending the same message twice while its lease lasts.
+type Dispatcher struct {
+	Repo     db.OutboxRepository
+	Notifier notification.Notifier
+
+	BatchSize    int           // messages claimed per round
+	PollInterval time.Duration // pause after a round that drained the outbox
+	Lease        time.Duration // time a round has to send its batch
+
+	// A failed message is retried after MinBackoff, doubling per attempt up
+	// to MaxBackoff. After MaxAttempts attempts, or on a
+	// notification.PermanentError, it is dead.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// NewDispatcher returns a Dispatcher with the default settings.
+func NewDispatcher(repo db.OutboxRepository, notifier notification.Notifier) *Dispatcher {
+	return &Dispatcher{
+		Repo:         repo,
+		Notifier:     notifier,
+		BatchSize:    DefaultBatchSize,
+		PollInterval: DefaultPollInterval,
+		Lease:        DefaultLease,
+		MaxAttempts:  DefaultMaxAttempts,
+		MinBackoff:   DefaultMinBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+	}
+}
+
+// Run dispatches until ctx is cancelled, then returns ctx.Err(). Full
+// batches are followed by another round right away; otherwise Run waits
+// PollInterval. Errors are logged and retried on the next round.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("outbox: %v", err)
+		}
+		if err == nil && n == d.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.PollInterval):
+		}
+	}
+}
+
+// DispatchOnce claims one batch, sends it and records every outcome. It
+// returns the number of messages claimed. Messages it does not get to
+// before the lease ends or ctx is cancelled are released without counting
+// the attempt.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	leaseEnd := time.Now().Add(d.Lease)
+	msgs, err := d.Repo.Claim(ctx, d.BatchSize, d.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	sendCtx, cancel := context.WithDeadline(ctx, leaseEnd)
+	defer cancel()
+	for i, m := range msgs {
+		if sendCtx.Err() != nil {
+			d.release(ctx, msgs[i:])
+			break
+		}
+		sendErr := d.send(sendCtx, m)
+		if ctx.Err() != nil {
+			// shutting down: the outcome of this send is unknown, so leave
+			// the message to be claimed again
+			d.release(ctx, msgs[i+1:])
+			return len(msgs), ctx.Err()
+		}
+		if err := d.record(ctx, m, sendErr); err != nil {
+			log.Printf("outbox: record outcome of %s: %v", m.ID, err)
+		}
+	}
+	return len(msgs), nil
+}
+
+// release hands back claimed messages that were never sent. A message
+// another dispatcher has claimed since is left alone.
+func (d *Dispatcher) release(ctx context.Context, msgs []*db.OutboxMessage) {
+	// ctx may be cancelled already when shutting down
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+	for _, m := range msgs {
+		if m.LockedUntil == nil {
+			continue
+		}
+		err := d.Repo.Release(ctx, m.ID, *m.LockedUntil)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("outbox: release %s: %v", m.ID, err)
+		}
+	}
+}
+
+// Backoff returns the delay before the attempt after the given one:
+// MinBackoff after the first, doubling up to MaxBackoff.
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	delay := d.MinBackoff
+	for i := 1; i < attempt && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+func (d *Dispatcher) send(ctx context.Context, m *db.OutboxMessage) error {
+	to := notification.Recipient{CustomerID: m.CustomerID}
+	switch m.Channel {
+	case db.ChannelEmail:
+		to.Email = m.Recipient
+	case db.ChannelSMS:
+		to.Phone = m.Recipient
+	}
+	return d.Notifier.Notify(ctx, to, notification.Event{
+		Type: m.Event,
+		Messages: map[string]notification.Message{
+			m.Channel: {Subject: m.Subject, Text: m.Body, HTML: m.HTMLBody},
+		},
+	})
+}
+
+// record stores the outcome of sending m, whose Attempts already counts
+// this attempt.
+func (d *Dispatcher) record(ctx context.Context, m *db.OutboxMessage, sendErr error) error {
+	if sendErr == nil {
+		return d.Repo.MarkSent(ctx, m.ID)
+	}
+	var held *notification.SuppressedError
+	if errors.As(sendErr, &held) {
+		if !held.Until.IsZero() {
+			return d.Repo.Postpone(ctx, m.ID, held.Until)
+		}
+		return d.Repo.MarkSuppressed(ctx, m.ID, held.Reason)
+	}
+	if notification.IsPermanent(sendErr) || m.Attempts >= d.MaxAttempts {
+		log.Printf("outbox: %s %s to %s is dead after %d attempts: %v", m.Channel, m.ID, m.Recipient, m.Attempts, sendErr)
+		return d.Repo.MarkFailed(ctx, m.ID, sendErr.Error(), nil)
+	}
+	retryAt := time.Now().Add(d.Backoff(m.Attempts))
+	return d.Repo.MarkFailed(ctx, m.ID, sendErr.Error(), &retryAt)
+}
@@ -0,0 +1,190 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/dbtest"
+	"github.com/felixojiambo/go-graphql-order-service/internal/notification"
+	"github.com/felixojiambo/go-graphql-order-service/internal/outbox"
+)
+
+type notifierFunc func(ctx context.Context, to notification.Recipient, e notification.Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, to notification.Recipient, e notification.Event) error {
+	return f(ctx, to, e)
+}
+
+// enqueue stores n pending emails and returns their IDs in claim order.
+func enqueue(t *testing.T, repo db.OutboxRepository, n int) []uuid.UUID {
+	t.Helper()
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		m := &db.OutboxMessage{
+			Channel: db.ChannelEmail, Recipient: "buyer@example.com",
+			Subject: "subject", Body: "body",
+			// distinct due times fix the claim order
+			NextAttemptAt: time.Now().Add(time.Duration(i-n) * time.Second),
+		}
+		if err := repo.Enqueue(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+// pending returns the pending messages by ID.
+func pending(t *testing.T, repo db.OutboxRepository) map[uuid.UUID]*db.OutboxMessage {
+	t.Helper()
+	msgs, err := repo.ListByStatus(context.Background(), db.OutboxPending, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[uuid.UUID]*db.OutboxMessage, len(msgs))
+	for _, m := range msgs {
+		byID[m.ID] = m
+	}
+	return byID
+}
+
+// wantReleased fails t unless the messages are pending, unleased and have
+// no attempts counted.
+func wantReleased(t *testing.T, repo db.OutboxRepository, ids ...uuid.UUID) {
+	t.Helper()
+	msgs := pending(t, repo)
+	for _, id := range ids {
+		m, ok := msgs[id]
+		switch {
+		case !ok:
+			t.Errorf("message %s is not pending", id)
+		case m.Attempts != 0 || m.LockedUntil != nil:
+			t.Errorf("message %s has %d attempts, locked until %v; want released", id, m.Attempts, m.LockedUntil)
+		}
+	}
+}
+
+func TestDispatchOnceReleasesUnsentAtLeaseEnd(t *testing.T) {
+	repo := dbtest.Memory(t).Outbox
+	ids := enqueue(t, repo, 3)
+
+	sends := 0
+	d := outbox.NewDispatcher(repo, notifierFunc(func(ctx context.Context, _ notification.Recipient, _ notification.Event) error {
+		sends++
+		<-ctx.Done() // the first send outlasts the lease
+		return ctx.Err()
+	}))
+	d.Lease = 20 * time.Millisecond
+
+	n, err := d.DispatchOnce(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("DispatchOnce = %d, %v; want 3 claimed", n, err)
+	}
+	if sends != 1 {
+		t.Errorf("sent %d messages, want 1", sends)
+	}
+	first := pending(t, repo)[ids[0]]
+	if first == nil || first.Attempts != 1 || first.LastError == nil {
+		t.Errorf("timed out message = %+v, want a failed 1st attempt", first)
+	}
+	wantReleased(t, repo, ids[1:]...)
+}
+
+func TestDispatchOnceReleasesUnsentOnShutdown(t *testing.T) {
+	repo := dbtest.Memory(t).Outbox
+	ids := enqueue(t, repo, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := outbox.NewDispatcher(repo, notifierFunc(func(context.Context, notification.Recipient, notification.Event) error {
+		cancel()
+		return nil
+	}))
+
+	if _, err := d.DispatchOnce(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("DispatchOnce error = %v, want context.Canceled", err)
+	}
+	// the outcome of the interrupted send is unknown, so it stays claimed
+	if m := pending(t, repo)[ids[0]]; m == nil || m.Attempts != 1 || m.LockedUntil == nil {
+		t.Errorf("interrupted message = %+v, want still claimed", m)
+	}
+	wantReleased(t, repo, ids[1:]...)
+}
+
+func TestDispatchOncePostponesQuietHours(t *testing.T) {
+	repo := dbtest.Memory(t).Outbox
+	ids := enqueue(t, repo, 1)
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	d := outbox.NewDispatcher(repo, notifierFunc(func(context.Context, notification.Recipient, notification.Event) error {
+		return &notification.SuppressedError{Channel: db.ChannelEmail, Reason: "quiet hours", Until: until}
+	}))
+	if n, err := d.DispatchOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("DispatchOnce = %d, %v; want 1 claimed", n, err)
+	}
+	m := pending(t, repo)[ids[0]]
+	switch {
+	case m == nil:
+		t.Fatal("postponed message is not pending")
+	case m.Attempts != 0 || m.LockedUntil != nil:
+		t.Errorf("postponed message has %d attempts, locked until %v; want the attempt handed back", m.Attempts, m.LockedUntil)
+	case !m.NextAttemptAt.Equal(until):
+		t.Errorf("postponed message is due at %v, want %v", m.NextAttemptAt, until)
+	}
+	if n, err := d.DispatchOnce(context.Background()); err != nil || n != 0 {
+		t.Errorf("DispatchOnce during quiet hours = %d, %v; want none claimed", n, err)
+	}
+}
+
+func TestDispatchOncePostponingDoesNotUseAttempts(t *testing.T) {
+	repo := dbtest.Memory(t).Outbox
+	ids := enqueue(t, repo, 1)
+
+	held := 0
+	d := outbox.NewDispatcher(repo, notifierFunc(func(context.Context, notification.Recipient, notification.Event) error {
+		if held < 3 {
+			held++
+			// quiet hours that are already over leave the message due
+			return &notification.SuppressedError{Channel: db.ChannelEmail, Reason: "quiet hours", Until: time.Now()}
+		}
+		return nil
+	}))
+	d.MaxAttempts = 1
+	for i := 0; i < 4; i++ {
+		if n, err := d.DispatchOnce(context.Background()); err != nil || n != 1 {
+			t.Fatalf("round %d: DispatchOnce = %d, %v; want 1 claimed", i+1, n, err)
+		}
+	}
+	sent, err := repo.ListByStatus(context.Background(), db.OutboxSent, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].ID != ids[0] || sent[0].Attempts != 1 {
+		t.Errorf("sent = %+v, want the message sent on its 1st counted attempt", sent)
+	}
+}
+
+func TestDispatchOnceSuppressesForGood(t *testing.T) {
+	repo := dbtest.Memory(t).Outbox
+	ids := enqueue(t, repo, 1)
+
+	d := outbox.NewDispatcher(repo, notifierFunc(func(context.Context, notification.Recipient, notification.Event) error {
+		return &notification.SuppressedError{Channel: db.ChannelEmail, Reason: "customer opted out of order_placed"}
+	}))
+	if _, err := d.DispatchOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := repo.ListByStatus(context.Background(), db.OutboxSuppressed, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != ids[0] || msgs[0].SuppressedReason == nil ||
+		*msgs[0].SuppressedReason != "customer opted out of order_placed" {
+		t.Errorf("suppressed = %+v, want the message with its reason", msgs)
+	}
+}
@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+)
+
+// Defaults set by NewDispatcher.
+const (
+	DefaultBatchSize    = 20
+	DefaultPollInterval = 5 * time.Second
+	DefaultLease        = 5 * time.Minute
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxAttempts  = 10
+	DefaultMinBackoff   = 30 * time.Second
+	DefaultMaxBackoff   = 6 * time.Hour
+)
+
+// UserAgent is sent with every request.
+const UserAgent = "go-graphql-order-service-webhooks/1"
+
+// errBodyLimit caps how much of an error response is kept in the log.
+const errBodyLimit = 512
+
+// releaseTimeout bounds handing back unsent deliveries on shutdown.
+const releaseTimeout = 5 * time.Second
+
+// Dispatcher claims due deliveries and POSTs them to their subscription.
+// Like the notification outbox, several dispatchers may share the table.
+type Dispatcher struct {
+	Repo   db.WebhookRepository
+	Client *http.Client
+
+	BatchSize    int           // deliveries claimed per round
+	PollInterval time.Duration // pause after a round that drained the queue
+	Lease        time.Duration // time a round has to send its batch
+	Timeout      time.Duration // per request, including reading the response
+
+	// A failed delivery is retried after MinBackoff, doubling per attempt
+	// up to MaxBackoff, and is dead after MaxAttempts attempts. A 410 Gone
+	// response kills it at once and deactivates the subscription.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// NewDispatcher returns a Dispatcher with the default settings and its own
+// http.Client, which does not follow redirects: a 3xx answer is a failed
+// attempt.
+func NewDispatcher(repo db.WebhookRepository) *Dispatcher {
+	return &Dispatcher{
+		Repo: repo,
+		Client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		BatchSize:    DefaultBatchSize,
+		PollInterval: DefaultPollInterval,
+		Lease:        DefaultLease,
+		Timeout:      DefaultTimeout,
+		MaxAttempts:  DefaultMaxAttempts,
+		MinBackoff:   DefaultMinBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+	}
+}
+
+// Run dispatches until ctx is cancelled, then returns ctx.Err(). Full
+// batches are followed by another round right away; otherwise Run waits
+// PollInterval. Errors are logged and retried on the next round.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("webhook: %v", err)
+		}
+		if err == nil && n == d.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.PollInterval):
+		}
+	}
+}
+
+// DispatchOnce claims one batch, sends it and logs every attempt. It
+// returns the number of deliveries claimed. Deliveries it does not get to
+// before the lease ends or ctx is cancelled, or whose subscription fails to
+// load, are released without counting the attempt.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	leaseEnd := time.Now().Add(d.Lease)
+	batch, err := d.Repo.Claim(ctx, d.BatchSize, d.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	sendCtx, cancel := context.WithDeadline(ctx, leaseEnd)
+	defer cancel()
+	subs := make(map[uuid.UUID]*db.WebhookSubscription)
+	for i, del := range batch {
+		if sendCtx.Err() != nil {
+			d.release(ctx, batch[i:])
+			break
+		}
+		sub, ok := subs[del.SubscriptionID]
+		if !ok {
+			if sub, err = d.Repo.GetSubscription(ctx, del.SubscriptionID); err != nil {
+				log.Printf("webhook: load subscription of %s: %v", del.ID, err)
+				d.release(ctx, batch[i:i+1])
+				continue
+			}
+			subs[del.SubscriptionID] = sub
+		}
+
+		a, retry := d.send(sendCtx, sub, del)
+		if ctx.Err() != nil {
+			// shutting down: the outcome of del is unknown, so it waits for
+			// its lease to end; the rest were never sent
+			d.release(ctx, batch[i+1:])
+			return len(batch), ctx.Err()
+		}
+		if err := d.record(ctx, del, a, retry); err != nil {
+			log.Printf("webhook: record attempt of %s: %v", del.ID, err)
+		}
+		if a.StatusCode != nil && *a.StatusCode == http.StatusGone && sub.Active {
+			// the endpoint is gone for good; its other deliveries die
+			// unsent and no new ones are fanned out to it
+			log.Printf("webhook: %s answered 410 Gone; deactivating subscription %s", sub.URL, sub.ID)
+			if err := d.Repo.DeactivateSubscription(ctx, sub.ID); err != nil {
+				log.Printf("webhook: deactivate subscription %s: %v", sub.ID, err)
+			}
+			sub.Active = false
+		}
+	}
+	return len(batch), nil
+}
+
+// release hands back claimed deliveries that were never sent. A delivery
+// another dispatcher has claimed since is left alone.
+func (d *Dispatcher) release(ctx context.Context, batch []*db.WebhookDelivery) {
+	// ctx may be cancelled already when shutting down
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+	for _, del := range batch {
+		if del.LockedUntil == nil {
+			continue
+		}
+		err := d.Repo.Release(ctx, del.ID, *del.LockedUntil)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("webhook: release %s: %v", del.ID, err)
+		}
+	}
+}
+
+// Backoff returns the delay before the attempt after the given one:
+// MinBackoff after the first, doubling up to MaxBackoff.
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	delay := d.MinBackoff
+	for i := 1; i < attempt && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+// send POSTs del to sub and returns the attempt, with Error set on failure,
+// and whether a failure may be retried.
+func (d *Dispatcher) send(ctx context.Context, sub *db.WebhookSubscription, del *db.WebhookDelivery) (*db.WebhookAttempt, bool) {
+	a := &db.WebhookAttempt{DeliveryID: del.ID}
+	fail := func(msg string) {
+		a.Error = &msg
+	}
+	if !sub.Active {
+		fail("subscription is inactive")
+		return a, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		fail(err.Error())
+		return a, false
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set(HeaderEvent, del.EventType)
+	req.Header.Set(HeaderID, del.EventID.String())
+	req.Header.Set(HeaderDelivery, del.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, now.Unix(), del.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		a.DurationMS = int(time.Since(now).Milliseconds())
+		fail(err.Error())
+		return a, true
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, errBodyLimit))
+	io.Copy(io.Discard, resp.Body) // let the connection be reused
+	a.DurationMS = int(time.Since(now).Milliseconds())
+	a.StatusCode = &resp.StatusCode
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return a, false
+	}
+	msg := resp.Status
+	if s := strings.TrimSpace(string(snippet)); s != "" {
+		msg += ": " + s
+	}
+	fail(msg)
+	return a, resp.StatusCode != http.StatusGone
+}
+
+// record stores the outcome of a, the attempt of del, whose Attempts
+// already counts it.
+func (d *Dispatcher) record(ctx context.Context, del *db.WebhookDelivery, a *db.WebhookAttempt, retry bool) error {
+	if a.Error == nil {
+		return d.Repo.MarkDelivered(ctx, a)
+	}
+	if !retry || del.Attempts >= d.MaxAttempts {
+		log.Printf("webhook: %s %s is dead after %d attempts: %s", del.EventType, del.ID, del.Attempts, *a.Error)
+		return d.Repo.MarkFailed(ctx, a, nil)
+	}
+	retryAt := time.Now().Add(d.Backoff(del.Attempts))
+	return d.Repo.MarkFailed(ctx, a, &retryAt)
+}
@@ -0,0 +1,412 @@
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/db/dbtest"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+	"github.com/felixojiambo/go-graphql-order-service/internal/webhook"
+	"github.com/felixojiambo/go-graphql-order-service/internal/webhook/webhooktest"
+)
+
+const testSecret = "s3cret-s3cret-s3cret"
+
+// newReceiver starts a receiver and subscribes it to every event type.
+func newReceiver(t *testing.T, repos dbtest.Repositories) (*webhooktest.Receiver, *db.WebhookSubscription) {
+	t.Helper()
+	rcv := webhooktest.NewReceiver(testSecret)
+	t.Cleanup(rcv.Close)
+	sub := &db.WebhookSubscription{
+		ID: uuid.New(), URL: rcv.URL(), Secret: testSecret,
+		EventTypes: db.WebhookEventTypes, Active: true,
+	}
+	if err := repos.Webhooks.CreateSubscription(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	return rcv, sub
+}
+
+// placeOrder stores an order for a new customer and product, with its
+// order.placed event.
+func placeOrder(t *testing.T, repos dbtest.Repositories) *db.WebhookEvent {
+	t.Helper()
+	ctx := context.Background()
+	cust := &db.Customer{ID: uuid.New(), Name: "buyer", Email: uuid.NewString() + "@example.com"}
+	cat := &db.Category{ID: uuid.New(), Name: "cat"}
+	p := &db.Product{
+		ID: uuid.New(), Name: "product", CategoryID: cat.ID, Stock: 1,
+		Price: money.MustParse("2.50", money.DefaultCurrency),
+	}
+	o := &db.Order{ID: uuid.New(), CustomerID: cust.ID, Status: "pending", Total: p.Price}
+	items := []*db.OrderItem{{ID: uuid.New(), OrderID: o.ID, ProductID: p.ID, Quantity: 1, UnitPrice: p.Price}}
+	if err := repos.Customers.Create(ctx, cust); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Categories.Create(ctx, cat); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Products.Create(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	e, err := webhook.NewOrderEvent(db.WebhookOrderPlaced, o, items, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Orders.CreateOrder(ctx, o, items, nil, nil, []*db.WebhookEvent{e}); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func deliveries(t *testing.T, repos dbtest.Repositories, sub *db.WebhookSubscription) []*db.WebhookDelivery {
+	t.Helper()
+	ds, err := repos.Webhooks.ListDeliveries(context.Background(), sub.ID, nil, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func attempts(t *testing.T, repos dbtest.Repositories, deliveryID uuid.UUID) []*db.WebhookAttempt {
+	t.Helper()
+	as, err := repos.Webhooks.ListAttempts(context.Background(), deliveryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return as
+}
+
+func dispatch(t *testing.T, d *webhook.Dispatcher, want int) {
+	t.Helper()
+	if n, err := d.DispatchOnce(context.Background()); err != nil || n != want {
+		t.Fatalf("DispatchOnce = %d, %v; want %d claimed", n, err, want)
+	}
+}
+
+func TestDispatcherSignsRequests(t *testing.T) {
+	repos := dbtest.Memory(t)
+	rcv, sub := newReceiver(t, repos)
+	e := placeOrder(t, repos)
+
+	before := time.Now()
+	dispatch(t, webhook.NewDispatcher(repos.Webhooks), 1)
+
+	reqs := rcv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(reqs))
+	}
+	req := reqs[0]
+	if req.VerifyErr != nil || req.Status != http.StatusNoContent {
+		t.Fatalf("request verified with %v, answered %d", req.VerifyErr, req.Status)
+	}
+	ds := deliveries(t, repos, sub)
+	if len(ds) != 1 {
+		t.Fatalf("subscription has %d deliveries, want 1", len(ds))
+	}
+	for header, want := range map[string]string{
+		webhook.HeaderEvent:    db.WebhookOrderPlaced,
+		webhook.HeaderID:       e.ID.String(),
+		webhook.HeaderDelivery: ds[0].ID.String(),
+		"Content-Type":         "application/json",
+		"User-Agent":           webhook.UserAgent,
+	} {
+		if got := req.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	ts, err := strconv.ParseInt(req.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	if err != nil || ts < before.Unix() || ts > time.Now().Unix() {
+		t.Errorf("%s = %q, want the time of sending", webhook.HeaderTimestamp, req.Header.Get(webhook.HeaderTimestamp))
+	}
+	if got, want := req.Header.Get(webhook.HeaderSignature), webhook.Sign(testSecret, ts, req.Body); got != want {
+		t.Errorf("%s = %q, want %q", webhook.HeaderSignature, got, want)
+	}
+	if err := webhook.Verify("another-secret-entirely", req.Header, req.Body, 0, time.Now()); err != webhook.ErrBadSignature {
+		t.Errorf("Verify with another secret error = %v, want ErrBadSignature", err)
+	}
+	if got, err := req.Event(); err != nil || got.ID != e.ID.String() || got.Type != db.WebhookOrderPlaced {
+		t.Errorf("body = %+v, %v; want event %s", got, err, e.ID)
+	}
+
+	if ds[0].Status != db.DeliveryDelivered {
+		t.Errorf("delivery status = %s, want %s", ds[0].Status, db.DeliveryDelivered)
+	}
+	as := attempts(t, repos, ds[0].ID)
+	if len(as) != 1 || as[0].StatusCode == nil || *as[0].StatusCode != http.StatusNoContent || as[0].Error != nil {
+		t.Errorf("attempts = %+v, want one successful", as)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	repos := dbtest.Memory(t)
+	rcv, sub := newReceiver(t, repos)
+	placeOrder(t, repos)
+	rcv.Fail(2, http.StatusServiceUnavailable)
+
+	d := webhook.NewDispatcher(repos.Webhooks)
+	d.MinBackoff = 50 * time.Millisecond
+	for attempt, backoff := range []time.Duration{50 * time.Millisecond, 100 * time.Millisecond} {
+		before := time.Now()
+		dispatch(t, d, 1)
+		after := time.Now()
+
+		del := deliveries(t, repos, sub)[0]
+		if del.Status != db.DeliveryPending || del.Attempts != attempt+1 || del.LastError == nil {
+			t.Fatalf("delivery after failed attempt %d = %+v, want pending", attempt+1, del)
+		}
+		if next := del.NextAttemptAt; next.Before(before.Add(backoff)) || next.After(after.Add(backoff)) {
+			t.Errorf("attempt %d retried %v after it, want %v", attempt+1, next.Sub(before), backoff)
+		}
+		// not due again before the backoff ends
+		dispatch(t, d, 0)
+		time.Sleep(time.Until(del.NextAttemptAt))
+	}
+	dispatch(t, d, 1)
+
+	if n := len(rcv.Requests()); n != 3 {
+		t.Errorf("receiver got %d requests, want 3", n)
+	}
+	del := deliveries(t, repos, sub)[0]
+	if del.Status != db.DeliveryDelivered || del.Attempts != 3 {
+		t.Errorf("delivery = %+v, want delivered on the 3rd attempt", del)
+	}
+	as := attempts(t, repos, del.ID)
+	if len(as) != 3 {
+		t.Fatalf("logged %d attempts, want one per request", len(as))
+	}
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusNoContent} {
+		if as[i].StatusCode == nil || *as[i].StatusCode != want || (as[i].Error == nil) != (want == http.StatusNoContent) {
+			t.Errorf("attempt %d = %+v, want status %d", i+1, as[i], want)
+		}
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	repos := dbtest.Memory(t)
+	rcv, sub := newReceiver(t, repos)
+	placeOrder(t, repos)
+	rcv.Fail(3, http.StatusInternalServerError)
+
+	d := webhook.NewDispatcher(repos.Webhooks)
+	d.MaxAttempts, d.MinBackoff = 2, 0
+	dispatch(t, d, 1)
+	dispatch(t, d, 1)
+	dispatch(t, d, 0)
+
+	del := deliveries(t, repos, sub)[0]
+	if del.Status != db.DeliveryDead || del.Attempts != 2 {
+		t.Errorf("delivery = %+v, want dead after 2 attempts", del)
+	}
+	if n := len(attempts(t, repos, del.ID)); n != 2 {
+		t.Errorf("logged %d attempts, want 2", n)
+	}
+}
+
+func TestDispatcherGoneDeactivatesSubscription(t *testing.T) {
+	repos := dbtest.Memory(t)
+	rcv, sub := newReceiver(t, repos)
+	placeOrder(t, repos)
+	placeOrder(t, repos)
+	rcv.Fail(1, http.StatusGone)
+
+	dispatch(t, webhook.NewDispatcher(repos.Webhooks), 2)
+
+	if n := len(rcv.Requests()); n != 1 {
+		t.Errorf("receiver got %d requests, want only the one answered 410", n)
+	}
+	stored, err := repos.Webhooks.GetSubscription(context.Background(), sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Active {
+		t.Error("subscription is still active after 410 Gone")
+	}
+	ds := deliveries(t, repos, sub)
+	for _, del := range ds {
+		if del.Status != db.DeliveryDead || del.Attempts != 1 {
+			t.Errorf("delivery = %+v, want dead after 1 attempt", del)
+		}
+		if n := len(attempts(t, repos, del.ID)); n != 1 {
+			t.Errorf("delivery %s logged %d attempts, want 1", del.ID, n)
+		}
+	}
+
+	// nothing new is fanned out to it
+	placeOrder(t, repos)
+	if n := len(deliveries(t, repos, sub)); n != len(ds) {
+		t.Errorf("subscription has %d deliveries after another order, want %d", n, len(ds))
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// wantReleased fails t unless the deliveries are pending, unleased and have
+// no attempts counted or logged.
+func wantReleased(t *testing.T, repos dbtest.Repositories, ids ...uuid.UUID) {
+	t.Helper()
+	for _, id := range ids {
+		del, err := repos.Webhooks.GetDelivery(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if del.Status != db.DeliveryPending || del.Attempts != 0 || del.LockedUntil != nil {
+			t.Errorf("delivery %s = %+v, want released", id, del)
+		}
+		if n := len(attempts(t, repos, id)); n != 0 {
+			t.Errorf("delivery %s logged %d attempts, want none", id, n)
+		}
+	}
+}
+
+// claimOrder returns the deliveries of sub in the order Claim hands them out.
+func claimOrder(t *testing.T, repos dbtest.Repositories, sub *db.WebhookSubscription) []uuid.UUID {
+	t.Helper()
+	ds := deliveries(t, repos, sub)
+	sort.Slice(ds, func(i, j int) bool {
+		if !ds[i].NextAttemptAt.Equal(ds[j].NextAttemptAt) {
+			return ds[i].NextAttemptAt.Before(ds[j].NextAttemptAt)
+		}
+		return ds[i].ID.String() < ds[j].ID.String()
+	})
+	ids := make([]uuid.UUID, len(ds))
+	for i, del := range ds {
+		ids[i] = del.ID
+	}
+	return ids
+}
+
+func TestDispatchOnceReleasesUnsentAtLeaseEnd(t *testing.T) {
+	repos := dbtest.Memory(t)
+	_, sub := newReceiver(t, repos)
+	for i := 0; i < 3; i++ {
+		placeOrder(t, repos)
+	}
+	ids := claimOrder(t, repos, sub)
+
+	sends := 0
+	d := webhook.NewDispatcher(repos.Webhooks)
+	d.Client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sends++
+		<-req.Context().Done() // the first request outlasts the lease
+		return nil, req.Context().Err()
+	})
+	d.Lease = 20 * time.Millisecond
+	dispatch(t, d, 3)
+
+	if sends != 1 {
+		t.Errorf("sent %d requests, want 1", sends)
+	}
+	first, err := repos.Webhooks.GetDelivery(context.Background(), ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != db.DeliveryPending || first.Attempts != 1 || first.LastError == nil {
+		t.Errorf("timed out delivery = %+v, want a failed 1st attempt", first)
+	}
+	wantReleased(t, repos, ids[1:]...)
+}
+
+func TestDispatchOnceReleasesUnsentOnShutdown(t *testing.T) {
+	repos := dbtest.Memory(t)
+	_, sub := newReceiver(t, repos)
+	for i := 0; i < 3; i++ {
+		placeOrder(t, repos)
+	}
+	ids := claimOrder(t, repos, sub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := webhook.NewDispatcher(repos.Webhooks)
+	d.Client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		cancel()
+		return nil, req.Context().Err()
+	})
+
+	if _, err := d.DispatchOnce(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("DispatchOnce error = %v, want context.Canceled", err)
+	}
+	// the outcome of the interrupted request is unknown, so it stays claimed
+	if del, err := repos.Webhooks.GetDelivery(context.Background(), ids[0]); err != nil || del.Attempts != 1 || del.LockedUntil == nil {
+		t.Errorf("interrupted delivery = %+v, %v; want still claimed", del, err)
+	}
+	wantReleased(t, repos, ids[1:]...)
+}
+
+// failingSubscriptions fails to load the subscription with ID id.
+type failingSubscriptions struct {
+	db.WebhookRepository
+	id uuid.UUID
+}
+
+func (r failingSubscriptions) GetSubscription(ctx context.Context, id uuid.UUID) (*db.WebhookSubscription, error) {
+	if id == r.id {
+		return nil, errors.New("connection reset")
+	}
+	return r.WebhookRepository.GetSubscription(ctx, id)
+}
+
+func TestDispatchOnceReleasesWhenSubscriptionFails(t *testing.T) {
+	repos := dbtest.Memory(t)
+	_, broken := newReceiver(t, repos)
+	rcv, sub := newReceiver(t, repos)
+	placeOrder(t, repos)
+
+	dispatch(t, webhook.NewDispatcher(failingSubscriptions{repos.Webhooks, broken.ID}), 2)
+
+	if n := len(rcv.Requests()); n != 1 {
+		t.Errorf("receiver got %d requests, want 1", n)
+	}
+	if ds := deliveries(t, repos, sub); len(ds) != 1 || ds[0].Status != db.DeliveryDelivered {
+		t.Errorf("deliveries of the loaded subscription = %+v, want delivered", ds)
+	}
+	ds := deliveries(t, repos, broken)
+	if len(ds) != 1 {
+		t.Fatalf("broken subscription has %d deliveries, want 1", len(ds))
+	}
+	wantReleased(t, repos, ds[0].ID)
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	repos := dbtest.Memory(t)
+	rcv := webhooktest.NewReceiver(testSecret)
+	t.Cleanup(rcv.Close)
+	redirect := httptest.NewServer(http.RedirectHandler(rcv.URL(), http.StatusFound))
+	t.Cleanup(redirect.Close)
+	sub := &db.WebhookSubscription{
+		ID: uuid.New(), URL: redirect.URL, Secret: testSecret,
+		EventTypes: db.WebhookEventTypes, Active: true,
+	}
+	if err := repos.Webhooks.CreateSubscription(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	placeOrder(t, repos)
+
+	dispatch(t, webhook.NewDispatcher(repos.Webhooks), 1)
+
+	if n := len(rcv.Requests()); n != 0 {
+		t.Errorf("redirect target got %d requests, want none", n)
+	}
+	del := deliveries(t, repos, sub)[0]
+	if del.Status != db.DeliveryPending || del.Attempts != 1 || del.LastError == nil {
+		t.Errorf("delivery = %+v, want a failed 1st attempt", del)
+	}
+	as := attempts(t, repos, del.ID)
+	if len(as) != 1 || as[0].StatusCode == nil || *as[0].StatusCode != http.StatusFound || as[0].Error == nil {
+		t.Errorf("attempts = %+v, want one failed with %d", as, http.StatusFound)
+	}
+}
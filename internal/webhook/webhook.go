@@ -0,0 +1,163 @@
+// Package webhook pushes order events to merchant endpoints.
+//
+// Order writes fan each event out to the active subscriptions of its type
+// (see db.WebhookRepository); a Dispatcher then POSTs every delivery as
+// JSON and logs each attempt. Requests carry these headers:
+//
+//	X-Webhook-Event      event type, e.g. "order.placed"
+//	X-Webhook-ID         event ID, the same in every delivery of the event
+//	X-Webhook-Delivery   delivery ID, the same across retries
+//	X-Webhook-Timestamp  Unix time the request was signed
+//	X-Webhook-Signature  "sha256=" and the hex HMAC-SHA256 of
+//	                     timestamp + "." + body, keyed with the secret
+//
+// Delivery is at least once, so receivers should deduplicate on
+// X-Webhook-ID. Verify checks a request on the receiving side.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/db"
+	"github.com/felixojiambo/go-graphql-order-service/internal/money"
+)
+
+// Request headers.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Event is the JSON body of every request.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// OrderData is the Data of order events.
+type OrderData struct {
+	Order          Order  `json:"order"`
+	PreviousStatus string `json:"previousStatus,omitempty"` // empty for order.placed
+}
+
+// Order is an order as webhooks describe it.
+type Order struct {
+	ID           string      `json:"id"`
+	CustomerID   string      `json:"customerID"`
+	Status       string      `json:"status"`
+	Total        Amount      `json:"total"`
+	Items        []OrderItem `json:"items"`
+	CancelReason *string     `json:"cancelReason,omitempty"`
+	CancelNote   *string     `json:"cancelNote,omitempty"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+// OrderItem is one line of an Order.
+type OrderItem struct {
+	ProductID string `json:"productID"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Amount `json:"unitPrice"`
+}
+
+// Amount is a decimal string, so receivers need no float parsing.
+type Amount struct {
+	Amount   string `json:"amount"` // e.g. "12.34"
+	Currency string `json:"currency"`
+}
+
+func toAmount(m money.Money) Amount {
+	return Amount{Amount: m.Decimal(), Currency: m.Currency}
+}
+
+// NewOrderEvent builds an event of type eventType (one of
+// db.WebhookEventTypes) about o in its current state. previous is the
+// status o moved from; it is empty for db.WebhookOrderPlaced.
+func NewOrderEvent(eventType string, o *db.Order, items []*db.OrderItem, previous string) (*db.WebhookEvent, error) {
+	data := OrderData{
+		Order: Order{
+			ID:           o.ID.String(),
+			CustomerID:   o.CustomerID.String(),
+			Status:       o.Status,
+			Total:        toAmount(o.Total),
+			Items:        make([]OrderItem, len(items)),
+			CancelReason: o.CancelReason,
+			CancelNote:   o.CancelNote,
+			CreatedAt:    o.CreatedAt.UTC(),
+		},
+		PreviousStatus: previous,
+	}
+	for i, it := range items {
+		data.Order.Items[i] = OrderItem{
+			ProductID: it.ProductID.String(),
+			Quantity:  it.Quantity,
+			UnitPrice: toAmount(it.UnitPrice),
+		}
+	}
+
+	e := &db.WebhookEvent{ID: uuid.New(), Type: eventType, OrderID: &o.ID}
+	var err error
+	e.Payload, err = json.Marshal(Event{
+		ID:        e.ID.String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Sign returns the X-Webhook-Signature value for body signed at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Errors returned by Verify.
+var (
+	ErrNoSignature  = errors.New("webhook: missing signature or timestamp")
+	ErrBadSignature = errors.New("webhook: signature mismatch")
+	ErrStale        = errors.New("webhook: timestamp outside tolerance")
+)
+
+// Verify checks the signature headers of a request with body against
+// secret. Requests signed more than tolerance before or after now are
+// rejected to limit replays; a zero tolerance skips that check.
+func Verify(secret string, h http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	sig, ts := h.Get(HeaderSignature), h.Get(HeaderTimestamp)
+	if sig == "" || ts == "" {
+		return ErrNoSignature
+	}
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrNoSignature
+	}
+	if !hmac.Equal([]byte(strings.ToLower(sig)), []byte(Sign(secret, t, body))) {
+		return ErrBadSignature
+	}
+	if tolerance > 0 {
+		if d := now.Sub(time.Unix(t, 0)); d > tolerance || d < -tolerance {
+			return ErrStale
+		}
+	}
+	return nil
+}
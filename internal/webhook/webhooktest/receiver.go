@@ -0,0 +1,114 @@
+// Package webhooktest runs an httptest server that stands in for a merchant
+// endpoint, so webhook.Dispatcher can be exercised end to end.
+//
+//	rcv := webhooktest.NewReceiver("s3cret-s3cret-s3cret")
+//	defer rcv.Close()
+//	rcv.Fail(2, http.StatusServiceUnavailable) // first two requests fail
+//	// subscribe rcv.URL() with the same secret, place an order, then
+//	_, _ = webhook.NewDispatcher(repo).DispatchOnce(ctx)
+//	reqs := rcv.Requests()
+//
+// Every request is recorded with the outcome of checking its signature.
+// Requests with a bad signature are answered 401.
+package webhooktest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/felixojiambo/go-graphql-order-service/internal/webhook"
+)
+
+// Request is one received request.
+type Request struct {
+	Header    http.Header
+	Body      []byte
+	VerifyErr error // result of webhook.Verify; nil for a valid signature
+	Status    int   // status the receiver answered with
+}
+
+// Event decodes the body.
+func (r Request) Event() (*webhook.Event, error) {
+	var e webhook.Event
+	if err := json.Unmarshal(r.Body, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Receiver is a running webhook endpoint.
+type Receiver struct {
+	srv    *httptest.Server
+	secret string
+
+	mu       sync.Mutex
+	requests []Request
+	failures int // requests still to fail
+	status   int // status of failed requests
+}
+
+// NewReceiver starts a receiver that verifies signatures with secret.
+func NewReceiver(secret string) *Receiver {
+	r := &Receiver{secret: secret}
+	r.srv = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+// URL returns the endpoint URL to subscribe.
+func (r *Receiver) URL() string {
+	return r.srv.URL
+}
+
+// Fail makes the next n validly signed requests fail with status.
+func (r *Receiver) Fail(n, status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures, r.status = n, status
+}
+
+// Requests returns the requests received so far, oldest first.
+func (r *Receiver) Requests() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+// Close shuts the server down.
+func (r *Receiver) Close() {
+	r.srv.Close()
+}
+
+func (r *Receiver) serve(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec := Request{
+		Header:    req.Header.Clone(),
+		Body:      body,
+		VerifyErr: webhook.Verify(r.secret, req.Header, body, 5*time.Minute, time.Now()),
+		Status:    http.StatusNoContent,
+	}
+
+	r.mu.Lock()
+	switch {
+	case rec.VerifyErr != nil:
+		rec.Status = http.StatusUnauthorized
+	case r.failures > 0:
+		r.failures--
+		rec.Status = r.status
+	}
+	r.requests = append(r.requests, rec)
+	r.mu.Unlock()
+
+	if rec.Status == http.StatusNoContent {
+		w.WriteHeader(rec.Status)
+		return
+	}
+	http.Error(w, http.StatusText(rec.Status), rec.Status)
+}
@@ -0,0 +1,5 @@
+-- migrations/001_create_schema.down.sql
+
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS customers;
@@ -32,25 +32,3 @@ CREATE TABLE products (
                           updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 CREATE INDEX idx_products_category ON products(category_id);
-
--- Orders
-CREATE TABLE orders (
-                        id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-                        customer_id   UUID NOT NULL REFERENCES customers(id),
-                        total_amount  NUMERIC(12,2) NOT NULL CHECK (total_amount >= 0),
-                        status        TEXT NOT NULL DEFAULT 'pending',
-                        created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-                        updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
-);
-CREATE INDEX idx_orders_customer ON orders(customer_id);
-
--- Order Items
-CREATE TABLE order_items (
-                             id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-                             order_id     UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
-                             product_id   UUID NOT NULL REFERENCES products(id),
-                             quantity     INT NOT NULL CHECK (quantity > 0),
-                             unit_price   NUMERIC(10,2) NOT NULL CHECK (unit_price >= 0),
-                             created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
-);
-CREATE INDEX idx_order_items_order ON order_items(order_id);
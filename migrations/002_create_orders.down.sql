@@ -0,0 +1,4 @@
+-- migrations/002_create_orders.down.sql
+
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
@@ -1,16 +1,24 @@
+-- migrations/002_create_orders.up.sql
+
+-- Orders
 CREATE TABLE orders (
-                        id UUID PRIMARY KEY,
-                        customer_id UUID NOT NULL REFERENCES customers(id),
-                        total NUMERIC NOT NULL,
-                        created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+                        id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                        customer_id   UUID NOT NULL REFERENCES customers(id),
+                        total_amount  NUMERIC(12,2) NOT NULL CHECK (total_amount >= 0),
+                        status        TEXT NOT NULL DEFAULT 'pending',
+                        created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
+CREATE INDEX idx_orders_customer ON orders(customer_id);
 
+-- Order Items
 CREATE TABLE order_items (
-                             id UUID PRIMARY KEY,
-                             order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
-                             product_id UUID NOT NULL REFERENCES products(id),
-                             quantity INT NOT NULL CHECK (quantity > 0),
-                             price NUMERIC NOT NULL
+                             id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                             order_id     UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+                             product_id   UUID NOT NULL REFERENCES products(id),
+                             quantity     INT NOT NULL CHECK (quantity > 0),
+                             unit_price   NUMERIC(10,2) NOT NULL CHECK (unit_price >= 0),
+                             created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                             updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
-
 CREATE INDEX idx_order_items_order ON order_items(order_id);
@@ -0,0 +1,4 @@
+-- migrations/003_create_order_status_history.down.sql
+
+DROP TABLE IF EXISTS order_status_history;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
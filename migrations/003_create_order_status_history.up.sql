@@ -0,0 +1,17 @@
+-- migrations/003_create_order_status_history.up.sql
+
+-- Restrict orders.status to the lifecycle states known to internal/domain
+ALTER TABLE orders
+    ADD CONSTRAINT orders_status_check
+        CHECK (status IN ('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded'));
+
+-- Order status history (one row per transition)
+CREATE TABLE order_status_history (
+                                      id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                      order_id     UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+                                      from_status  TEXT NOT NULL,
+                                      to_status    TEXT NOT NULL,
+                                      actor_uid    TEXT NOT NULL,
+                                      created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_order_status_history_order ON order_status_history(order_id, created_at);
@@ -0,0 +1,6 @@
+-- migrations/004_add_order_cancellation.down.sql
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS cancelled_at,
+    DROP COLUMN IF EXISTS cancel_note,
+    DROP COLUMN IF EXISTS cancel_reason;
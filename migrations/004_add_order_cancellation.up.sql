@@ -0,0 +1,7 @@
+-- migrations/004_add_order_cancellation.up.sql
+
+-- Cancellation details, set once when an order moves to 'cancelled'
+ALTER TABLE orders
+    ADD COLUMN cancel_reason TEXT,
+    ADD COLUMN cancel_note   TEXT,
+    ADD COLUMN cancelled_at  TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+-- migrations/005_add_customer_firebase_uid.down.sql
+
+ALTER TABLE customers DROP COLUMN IF EXISTS firebase_uid;
@@ -0,0 +1,4 @@
+-- migrations/005_add_customer_firebase_uid.up.sql
+
+-- Firebase identity bound to the customer (NULL until the user registers)
+ALTER TABLE customers ADD COLUMN firebase_uid TEXT UNIQUE;
@@ -0,0 +1,4 @@
+-- migrations/006_add_inventory.down.sql
+
+DROP TABLE IF EXISTS stock_adjustments;
+ALTER TABLE products DROP COLUMN IF EXISTS stock_quantity;
@@ -0,0 +1,18 @@
+-- migrations/006_add_inventory.up.sql
+
+-- Units available to order; reserved atomically when an order is placed
+ALTER TABLE products
+    ADD COLUMN stock_quantity INT NOT NULL DEFAULT 0 CHECK (stock_quantity >= 0);
+
+-- Audit trail of every stock change (reservations, releases, manual adjustments)
+CREATE TABLE stock_adjustments (
+                                   id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                   product_id      UUID NOT NULL REFERENCES products(id),
+                                   delta           INT NOT NULL,
+                                   quantity_after  INT NOT NULL,
+                                   reason          TEXT NOT NULL,
+                                   order_id        UUID REFERENCES orders(id) ON DELETE SET NULL,
+                                   actor_uid       TEXT,
+                                   created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_stock_adjustments_product ON stock_adjustments(product_id, created_at);
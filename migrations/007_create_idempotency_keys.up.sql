@@ -0,0 +1,13 @@
+-- migrations/007_create_idempotency_keys.up.sql
+
+-- Client-supplied placeOrder keys, scoped per customer and expiring after a TTL
+CREATE TABLE idempotency_keys (
+                                  customer_id   UUID NOT NULL REFERENCES customers(id),
+                                  key           TEXT NOT NULL,
+                                  request_hash  TEXT NOT NULL,
+                                  order_id      UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+                                  created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                  expires_at    TIMESTAMPTZ NOT NULL,
+                                  PRIMARY KEY (customer_id, key)
+);
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires_at);
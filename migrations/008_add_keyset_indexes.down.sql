@@ -0,0 +1,9 @@
+-- migrations/008_add_keyset_indexes.down.sql
+
+CREATE INDEX idx_products_category ON products(category_id);
+CREATE INDEX idx_orders_customer ON orders(customer_id);
+
+DROP INDEX IF EXISTS idx_customers_keyset;
+DROP INDEX IF EXISTS idx_orders_customer_keyset;
+DROP INDEX IF EXISTS idx_products_category_keyset;
+DROP INDEX IF EXISTS idx_categories_parent_keyset;
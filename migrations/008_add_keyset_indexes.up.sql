@@ -0,0 +1,11 @@
+-- migrations/008_add_keyset_indexes.up.sql
+
+-- Paged lists are ordered by (created_at, id) within their filter, so each
+-- page is an index range scan instead of a sort
+CREATE INDEX idx_categories_parent_keyset ON categories(parent_id, created_at, id);
+CREATE INDEX idx_products_category_keyset ON products(category_id, created_at, id);
+CREATE INDEX idx_orders_customer_keyset ON orders(customer_id, created_at, id);
+CREATE INDEX idx_customers_keyset ON customers(created_at, id);
+
+DROP INDEX IF EXISTS idx_products_category;
+DROP INDEX IF EXISTS idx_orders_customer;
@@ -0,0 +1,9 @@
+-- migrations/009_add_product_archiving.down.sql
+
+ALTER TABLE stock_adjustments
+    DROP CONSTRAINT stock_adjustments_product_id_fkey,
+    ADD CONSTRAINT stock_adjustments_product_id_fkey
+        FOREIGN KEY (product_id) REFERENCES products(id);
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS archived_at;
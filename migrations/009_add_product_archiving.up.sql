@@ -0,0 +1,12 @@
+-- migrations/009_add_product_archiving.up.sql
+
+-- Archived products stay referenced by past order items but are no longer listed or sold
+ALTER TABLE products
+    ADD COLUMN archived_at TIMESTAMPTZ;
+
+-- A product's stock history goes with it when it is deleted; order items
+-- still block the delete
+ALTER TABLE stock_adjustments
+    DROP CONSTRAINT stock_adjustments_product_id_fkey,
+    ADD CONSTRAINT stock_adjustments_product_id_fkey
+        FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
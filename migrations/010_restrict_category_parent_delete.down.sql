@@ -0,0 +1,6 @@
+-- migrations/010_restrict_category_parent_delete.down.sql
+
+ALTER TABLE categories
+    DROP CONSTRAINT categories_parent_id_fkey,
+    ADD CONSTRAINT categories_parent_id_fkey
+        FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL;
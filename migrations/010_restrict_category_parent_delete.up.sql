@@ -0,0 +1,8 @@
+-- migrations/010_restrict_category_parent_delete.up.sql
+
+-- Deleting a category no longer turns its children into roots; the
+-- repository reparents or deletes them explicitly
+ALTER TABLE categories
+    DROP CONSTRAINT categories_parent_id_fkey,
+    ADD CONSTRAINT categories_parent_id_fkey
+        FOREIGN KEY (parent_id) REFERENCES categories(id);
@@ -0,0 +1,3 @@
+-- migrations/011_create_notification_outbox.down.sql
+
+DROP TABLE IF EXISTS notification_outbox;
@@ -0,0 +1,25 @@
+-- migrations/011_create_notification_outbox.up.sql
+
+-- Notifications are written here in the same transaction as the change they
+-- announce and delivered by the outbox dispatcher. Rows are claimed with
+-- FOR UPDATE SKIP LOCKED and leased until locked_until; failed sends return
+-- to 'pending' with a later next_attempt_at until they end up 'dead'.
+CREATE TABLE notification_outbox (
+                                     id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                     order_id         UUID REFERENCES orders(id) ON DELETE CASCADE,
+                                     channel          TEXT NOT NULL CHECK (channel IN ('email', 'sms')),
+                                     recipient        TEXT NOT NULL,
+                                     subject          TEXT NOT NULL DEFAULT '',
+                                     body             TEXT NOT NULL,
+                                     status           TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'dead')),
+                                     attempts         INT NOT NULL DEFAULT 0,
+                                     last_error       TEXT,
+                                     next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                     locked_until     TIMESTAMPTZ,
+                                     created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                     updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                     sent_at          TIMESTAMPTZ
+);
+CREATE INDEX idx_notification_outbox_due ON notification_outbox(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_notification_outbox_order ON notification_outbox(order_id, created_at);
+CREATE INDEX idx_notification_outbox_status ON notification_outbox(status, updated_at);
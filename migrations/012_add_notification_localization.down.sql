@@ -0,0 +1,8 @@
+-- migrations/012_add_notification_localization.down.sql
+
+ALTER TABLE notification_outbox
+    DROP COLUMN IF EXISTS html_body;
+
+ALTER TABLE customers
+    DROP COLUMN IF EXISTS phone,
+    DROP COLUMN IF EXISTS locale;
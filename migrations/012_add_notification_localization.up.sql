@@ -0,0 +1,11 @@
+-- migrations/012_add_notification_localization.up.sql
+
+-- Contact details for notifications: SMS goes to phone (E.164) when set, and
+-- templates are rendered in the customer's locale (a BCP 47 tag)
+ALTER TABLE customers
+    ADD COLUMN phone TEXT,
+    ADD COLUMN locale TEXT NOT NULL DEFAULT 'en';
+
+-- Rendered HTML alternative of an email; empty when derived from the text
+ALTER TABLE notification_outbox
+    ADD COLUMN html_body TEXT NOT NULL DEFAULT '';
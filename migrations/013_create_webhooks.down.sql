@@ -0,0 +1,5 @@
+-- migrations/013_create_webhooks.down.sql
+
+DROP TABLE IF EXISTS webhook_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,45 @@
+-- migrations/013_create_webhooks.up.sql
+
+-- Merchants subscribe a URL to order event types. Every matching event is
+-- copied into webhook_deliveries in the same transaction as the order change
+-- and delivered by the webhook dispatcher, which claims rows like the
+-- notification outbox does. Each HTTP attempt is kept in webhook_attempts.
+CREATE TABLE webhook_subscriptions (
+                                       id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                       url          TEXT NOT NULL,
+                                       secret       TEXT NOT NULL,
+                                       event_types  TEXT[] NOT NULL CHECK (cardinality(event_types) > 0),
+                                       active       BOOLEAN NOT NULL DEFAULT TRUE,
+                                       created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                       updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE webhook_deliveries (
+                                    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                    subscription_id  UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+                                    event_id         UUID NOT NULL,
+                                    event_type       TEXT NOT NULL,
+                                    order_id         UUID REFERENCES orders(id) ON DELETE CASCADE,
+                                    payload          JSONB NOT NULL,
+                                    status           TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+                                    attempts         INT NOT NULL DEFAULT 0,
+                                    last_error       TEXT,
+                                    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                    locked_until     TIMESTAMPTZ,
+                                    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                    delivered_at     TIMESTAMPTZ,
+                                    UNIQUE (subscription_id, event_id)
+);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at);
+
+CREATE TABLE webhook_attempts (
+                                  id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                  delivery_id  UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+                                  status_code  INT,
+                                  error        TEXT,
+                                  duration_ms  INT NOT NULL,
+                                  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_webhook_attempts_delivery ON webhook_attempts(delivery_id, created_at);
@@ -0,0 +1,13 @@
+-- migrations/014_create_notification_preferences.down.sql
+
+DELETE FROM notification_outbox WHERE status = 'suppressed';
+
+ALTER TABLE notification_outbox
+    DROP CONSTRAINT notification_outbox_status_check,
+    ADD CONSTRAINT notification_outbox_status_check
+        CHECK (status IN ('pending', 'sent', 'dead')),
+    DROP COLUMN IF EXISTS suppressed_reason,
+    DROP COLUMN IF EXISTS event,
+    DROP COLUMN IF EXISTS customer_id;
+
+DROP TABLE IF EXISTS notification_preferences;
@@ -0,0 +1,28 @@
+-- migrations/014_create_notification_preferences.up.sql
+
+-- What each customer wants to be notified about. Customers without a row get
+-- every channel, every event and no quiet hours. Quiet hours are minutes
+-- after midnight in time_zone and may wrap past midnight, e.g. 1320 to 420
+-- for 22:00-07:00; messages due inside them are held until they end.
+CREATE TABLE notification_preferences (
+                                          customer_id       UUID PRIMARY KEY REFERENCES customers(id) ON DELETE CASCADE,
+                                          channels          TEXT[] NOT NULL DEFAULT '{email,sms}' CHECK (channels <@ '{email,sms}'),
+                                          opted_out_events  TEXT[] NOT NULL DEFAULT '{}',
+                                          quiet_start       INT CHECK (quiet_start BETWEEN 0 AND 1439),
+                                          quiet_end         INT CHECK (quiet_end BETWEEN 0 AND 1439),
+                                          time_zone         TEXT NOT NULL DEFAULT 'UTC',
+                                          updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                          CHECK ((quiet_start IS NULL) = (quiet_end IS NULL)),
+                                          CHECK (quiet_start <> quiet_end)
+);
+
+-- The outbox remembers whose preferences apply and which event a message
+-- announces. Messages the preferences rule out end up 'suppressed' with the
+-- reason instead of being sent.
+ALTER TABLE notification_outbox
+    ADD COLUMN customer_id UUID REFERENCES customers(id) ON DELETE CASCADE,
+    ADD COLUMN event TEXT NOT NULL DEFAULT '',
+    ADD COLUMN suppressed_reason TEXT,
+    DROP CONSTRAINT notification_outbox_status_check,
+    ADD CONSTRAINT notification_outbox_status_check
+        CHECK (status IN ('pending', 'sent', 'dead', 'suppressed'));
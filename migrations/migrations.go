@@ -0,0 +1,13 @@
+// Package migrations embeds the SQL migration files so the binary can apply
+// them without access to the source tree.
+//
+// Files are named NNN_description.up.sql / NNN_description.down.sql and are
+// applied in version order by internal/db/migrate.
+package migrations
+
+import "embed"
+
+// FS holds every *.sql file in this directory.
+//
+//go:embed *.sql
+var FS embed.FS